@@ -0,0 +1,386 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"envirostation/backend/internal/server"
+)
+
+// FileConfig is the single typed document covering every operator-tunable
+// setting this binary reads from the environment. LoadFileConfig seeds it
+// with the same defaults main() used to hardcode inline, then overlays
+// whatever the YAML file at --config/ENVIROSTATION_CONFIG sets; env vars
+// still win over both, since every call site passes the resolved field as
+// envOrDefault's fallback rather than reading it directly.
+type FileConfig struct {
+	Server      ServerFileSection      `yaml:"server"`
+	Postgres    PostgresFileSection    `yaml:"postgres"`
+	Redis       RedisFileSection       `yaml:"redis"`
+	Insights    InsightsFileSection    `yaml:"insights"`
+	Ollama      OllamaFileSection      `yaml:"ollama"`
+	Anthropic   AnthropicFileSection   `yaml:"anthropic"`
+	AzureOpenAI AzureOpenAIFileSection `yaml:"azure_openai"`
+	Rules       RulesFileSection       `yaml:"rules"`
+	Ops         OpsFileSection         `yaml:"ops"`
+	Retention   RetentionFileSection   `yaml:"retention"`
+	CORS        CORSFileSection        `yaml:"cors"`
+	Metrics     MetricsFileSection     `yaml:"metrics"`
+	TLS         TLSFileSection         `yaml:"tls"`
+}
+
+type ServerFileSection struct {
+	Port              string       `yaml:"port"`
+	ListenAddr        string       `yaml:"listen_addr"`
+	LogFormat         string       `yaml:"log_format"`
+	LogLevel          string       `yaml:"log_level"`
+	TrustProxyHeaders bool         `yaml:"trust_proxy_headers"`
+	TrustRequestID    bool         `yaml:"trust_request_id"`
+	ShutdownTimeout   fileDuration `yaml:"shutdown_timeout"`
+
+	// UDPIngestFormat/UDPIngestCoalesceInterval/UDPIngestCoalesceMaxBatch
+	// tune the optional UDP ingest listener (see UDP_INGEST_ADDR), which
+	// stays disabled unless that env var is set.
+	UDPIngestFormat           string       `yaml:"udp_ingest_format"`
+	UDPIngestCoalesceInterval fileDuration `yaml:"udp_ingest_coalesce_interval"`
+	UDPIngestCoalesceMaxBatch int          `yaml:"udp_ingest_coalesce_max_batch"`
+}
+
+// TLSFileSection configures the optional mutual-TLS listener. ClientAuth
+// accepts "none", "request", "verify_if_given", or "require_and_verify"
+// (see parseClientAuth), mirroring the tls.ClientAuthType values
+// server.TLSConfig expects. Leaving CertFile/KeyFile empty with ClientAuth
+// unset or "none" keeps the server on plain HTTP; any other ClientAuth
+// without both files is a startup error (see loadTLSConfig).
+type TLSFileSection struct {
+	CertFile           string   `yaml:"cert_file"`
+	KeyFile            string   `yaml:"key_file"`
+	ClientCAFile       string   `yaml:"client_ca_file"`
+	ClientAuth         string   `yaml:"client_auth"`
+	AllowedCommonNames []string `yaml:"allowed_common_names"`
+	AllowedOUs         []string `yaml:"allowed_ous"`
+}
+
+type PostgresFileSection struct {
+	MaxConns int `yaml:"max_conns"`
+}
+
+type RedisFileSection struct {
+	KeyPrefix    string `yaml:"key_prefix"`
+	MaxReadings  int    `yaml:"max_readings"`
+	Codec        string `yaml:"codec"`
+	MQTTClientID string `yaml:"mqtt_client_id"`
+	MQTTTopic    string `yaml:"mqtt_topic"`
+	MQTTQoS      int    `yaml:"mqtt_qos"`
+}
+
+type InsightsFileSection struct {
+	Model             string       `yaml:"model"`
+	BaseURL           string       `yaml:"base_url"`
+	MaxAlerts         int          `yaml:"max_alerts"`
+	AnalysisLimit     int          `yaml:"analysis_limit"`
+	RefreshInterval   fileDuration `yaml:"refresh_interval"`
+	EventMinInterval  fileDuration `yaml:"event_min_interval"`
+	PM2Trigger        float64      `yaml:"pm2_trigger"`
+	PM10Trigger       float64      `yaml:"pm10_trigger"`
+	PM2DeltaTrigger   float64      `yaml:"pm2_delta_trigger"`
+	PM10DeltaTrigger  float64      `yaml:"pm10_delta_trigger"`
+	AnalyzeTimeout    fileDuration `yaml:"analyze_timeout"`
+	EnrichBackend     string       `yaml:"enrich_backend"`
+	FailoverPrimary   string       `yaml:"failover_primary"`
+	FailoverSecondary string       `yaml:"failover_secondary"`
+}
+
+type OllamaFileSection struct {
+	BaseURL string `yaml:"base_url"`
+	Model   string `yaml:"model"`
+}
+
+type AnthropicFileSection struct {
+	Model   string `yaml:"model"`
+	BaseURL string `yaml:"base_url"`
+}
+
+type AzureOpenAIFileSection struct {
+	Deployment string `yaml:"deployment"`
+	APIVersion string `yaml:"api_version"`
+}
+
+type RulesFileSection struct {
+	HumidityMoldPercent float64 `yaml:"humidity_mold_percent"`
+	HumidityDryPercent  float64 `yaml:"humidity_dry_percent"`
+	TempComfortLowC     float64 `yaml:"temp_comfort_low_c"`
+	TempComfortHighC    float64 `yaml:"temp_comfort_high_c"`
+	MaxAlerts           int     `yaml:"max_alerts"`
+}
+
+type OpsFileSection struct {
+	DeviceOfflineTimeout fileDuration `yaml:"device_offline_timeout"`
+	MonitorInterval      fileDuration `yaml:"monitor_interval"`
+	EventsRetention      fileDuration `yaml:"events_retention"`
+	IngestSessionTTL     fileDuration `yaml:"ingest_session_ttl"`
+}
+
+type RetentionFileSection struct {
+	Enabled   bool         `yaml:"enabled"`
+	Days      int          `yaml:"days"`
+	BatchSize int          `yaml:"batch_size"`
+	Interval  fileDuration `yaml:"interval"`
+}
+
+type CORSFileSection struct {
+	AllowOrigin string `yaml:"allow_origin"`
+}
+
+type MetricsFileSection struct {
+	Addr string `yaml:"addr"`
+}
+
+// fileDuration unmarshals a YAML duration string ("45s", "1h") straight
+// into a time.Duration, so FileConfig fields can be passed directly
+// wherever main() already passes a time.Duration fallback to
+// durationOrDefault.
+type fileDuration time.Duration
+
+func (d *fileDuration) UnmarshalYAML(value *yaml.Node) error {
+	var raw string
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(raw)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w", raw, err)
+	}
+	*d = fileDuration(parsed)
+	return nil
+}
+
+// DefaultFileConfig returns the settings main() used to hardcode as
+// literal *OrDefault fallbacks before this file existed. LoadFileConfig
+// starts from this value so an operator's YAML file only needs to set the
+// knobs it wants to change.
+func DefaultFileConfig() FileConfig {
+	return FileConfig{
+		Server: ServerFileSection{
+			Port:                      "8080",
+			LogFormat:                 "text",
+			LogLevel:                  "info",
+			ShutdownTimeout:           fileDuration(20 * time.Second),
+			UDPIngestFormat:           "json",
+			UDPIngestCoalesceInterval: fileDuration(250 * time.Millisecond),
+			UDPIngestCoalesceMaxBatch: 200,
+		},
+		Postgres: PostgresFileSection{MaxConns: 10},
+		Redis: RedisFileSection{
+			KeyPrefix:    "enviro",
+			MaxReadings:  100000,
+			Codec:        "json",
+			MQTTClientID: "envirostation",
+			MQTTTopic:    "enviro",
+		},
+		Insights: InsightsFileSection{
+			Model:             "gpt-5-mini",
+			BaseURL:           "https://api.openai.com/v1",
+			MaxAlerts:         4,
+			AnalysisLimit:     900,
+			RefreshInterval:   fileDuration(time.Hour),
+			EventMinInterval:  fileDuration(10 * time.Minute),
+			PM2Trigger:        15.0,
+			PM10Trigger:       45.0,
+			PM2DeltaTrigger:   8.0,
+			PM10DeltaTrigger:  15.0,
+			AnalyzeTimeout:    fileDuration(15 * time.Second),
+			EnrichBackend:     "OPENAI",
+			FailoverPrimary:   "OPENAI",
+			FailoverSecondary: "RULES",
+		},
+		Ollama: OllamaFileSection{
+			BaseURL: "http://localhost:11434",
+			Model:   "llama3.1",
+		},
+		Anthropic: AnthropicFileSection{
+			Model:   "claude-3-5-haiku-latest",
+			BaseURL: "https://api.anthropic.com/v1",
+		},
+		AzureOpenAI: AzureOpenAIFileSection{
+			Deployment: "gpt-5-mini",
+			APIVersion: "2024-08-01-preview",
+		},
+		Rules: RulesFileSection{
+			HumidityMoldPercent: 60,
+			HumidityDryPercent:  30,
+			TempComfortLowC:     18,
+			TempComfortHighC:    26,
+			MaxAlerts:           4,
+		},
+		Ops: OpsFileSection{
+			DeviceOfflineTimeout: fileDuration(45 * time.Second),
+			MonitorInterval:      fileDuration(5 * time.Second),
+			EventsRetention:      fileDuration(30 * 24 * time.Hour),
+			IngestSessionTTL:     fileDuration(24 * time.Hour),
+		},
+		Retention: RetentionFileSection{
+			Enabled:   true,
+			Days:      60,
+			BatchSize: 5000,
+			Interval:  fileDuration(24 * time.Hour),
+		},
+		CORS:    CORSFileSection{AllowOrigin: "*"},
+		Metrics: MetricsFileSection{Addr: ":9090"},
+		TLS:     TLSFileSection{ClientAuth: "none"},
+	}
+}
+
+// envRefPattern matches ${env:VAR} references so secrets can live in the
+// environment while the rest of the document is committed to a repo.
+var envRefPattern = regexp.MustCompile(`\$\{env:([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+func interpolateEnvRefs(raw string) string {
+	return envRefPattern.ReplaceAllStringFunc(raw, func(match string) string {
+		name := envRefPattern.FindStringSubmatch(match)[1]
+		return os.Getenv(name)
+	})
+}
+
+// LoadFileConfig returns DefaultFileConfig() unchanged when path is empty,
+// otherwise parses the YAML document at path over it (after interpolating
+// ${env:VAR} references) and validates the result. A non-empty path whose
+// file can't be read or parsed, or whose values fail Validate, is a fatal
+// startup error rather than a silent fallback to defaults.
+func LoadFileConfig(path string) (*FileConfig, error) {
+	cfg := DefaultFileConfig()
+	if path == "" {
+		return &cfg, nil
+	}
+
+	raw, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+
+	if err := yaml.Unmarshal([]byte(interpolateEnvRefs(string(raw))), &cfg); err != nil {
+		return nil, fmt.Errorf("parse config file: %w", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// Validate checks every range-constrained field and reports all
+// violations at once (via errors.Join) instead of stopping at the first,
+// so an operator fixing a bad config file doesn't have to re-run it one
+// error at a time.
+func (cfg *FileConfig) Validate() error {
+	var errs []error
+
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(cfg.Postgres.MaxConns >= 1, "postgres.max_conns must be >= 1, got %d", cfg.Postgres.MaxConns)
+	check(cfg.Redis.MaxReadings >= 1, "redis.max_readings must be >= 1, got %d", cfg.Redis.MaxReadings)
+
+	check(cfg.Insights.MaxAlerts >= 1, "insights.max_alerts must be >= 1, got %d", cfg.Insights.MaxAlerts)
+	check(cfg.Insights.AnalysisLimit >= 1, "insights.analysis_limit must be >= 1, got %d", cfg.Insights.AnalysisLimit)
+	check(cfg.Insights.PM2Trigger > 0, "insights.pm2_trigger must be > 0, got %g", cfg.Insights.PM2Trigger)
+	check(cfg.Insights.PM10Trigger > 0, "insights.pm10_trigger must be > 0, got %g", cfg.Insights.PM10Trigger)
+	check(cfg.Insights.PM2DeltaTrigger > 0, "insights.pm2_delta_trigger must be > 0, got %g", cfg.Insights.PM2DeltaTrigger)
+	check(cfg.Insights.PM10DeltaTrigger > 0, "insights.pm10_delta_trigger must be > 0, got %g", cfg.Insights.PM10DeltaTrigger)
+	check(cfg.Insights.RefreshInterval > 0, "insights.refresh_interval must be > 0, got %s", time.Duration(cfg.Insights.RefreshInterval))
+	check(cfg.Insights.EventMinInterval > 0, "insights.event_min_interval must be > 0, got %s", time.Duration(cfg.Insights.EventMinInterval))
+	check(cfg.Insights.AnalyzeTimeout > 0, "insights.analyze_timeout must be > 0, got %s", time.Duration(cfg.Insights.AnalyzeTimeout))
+
+	check(cfg.Rules.MaxAlerts >= 1, "rules.max_alerts must be >= 1, got %d", cfg.Rules.MaxAlerts)
+	check(cfg.Rules.TempComfortHighC > cfg.Rules.TempComfortLowC,
+		"rules.temp_comfort_high_c (%g) must be greater than rules.temp_comfort_low_c (%g)",
+		cfg.Rules.TempComfortHighC, cfg.Rules.TempComfortLowC)
+
+	check(cfg.Ops.DeviceOfflineTimeout > 0, "ops.device_offline_timeout must be > 0, got %s", time.Duration(cfg.Ops.DeviceOfflineTimeout))
+	check(cfg.Ops.MonitorInterval > 0, "ops.monitor_interval must be > 0, got %s", time.Duration(cfg.Ops.MonitorInterval))
+	check(cfg.Ops.EventsRetention > 0, "ops.events_retention must be > 0, got %s", time.Duration(cfg.Ops.EventsRetention))
+
+	check(cfg.Retention.Days >= 1, "retention.days must be >= 1, got %d", cfg.Retention.Days)
+	check(cfg.Retention.BatchSize >= 1, "retention.batch_size must be >= 1 (sane upper bound aside, zero never deletes), got %d", cfg.Retention.BatchSize)
+	check(cfg.Retention.Interval > 0, "retention.interval must be > 0, got %s", time.Duration(cfg.Retention.Interval))
+
+	check(cfg.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be > 0, got %s", time.Duration(cfg.Server.ShutdownTimeout))
+
+	if _, clientAuthErr := parseClientAuth(cfg.TLS.ClientAuth); clientAuthErr != nil {
+		errs = append(errs, fmt.Errorf("tls.client_auth: %w", clientAuthErr))
+	}
+
+	return errors.Join(errs...)
+}
+
+// parseClientAuth maps a TLSFileSection.ClientAuth string onto the
+// standard tls.ClientAuthType values WithTLS expects.
+func parseClientAuth(name string) (tls.ClientAuthType, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "none":
+		return tls.NoClientCert, nil
+	case "request":
+		return tls.RequestClientCert, nil
+	case "verify_if_given":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require_and_verify":
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return tls.NoClientCert, fmt.Errorf("unknown client_auth %q (want none, request, verify_if_given, or require_and_verify)", name)
+	}
+}
+
+// loadTLSConfig builds a server.TLSConfig from cfg, or returns nil when
+// neither a certificate nor client-cert auth has been configured, leaving
+// the server on plain HTTP.
+func loadTLSConfig(cfg TLSFileSection) (*server.TLSConfig, error) {
+	clientAuth, err := parseClientAuth(cfg.ClientAuth)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.CertFile == "" && cfg.KeyFile == "" && clientAuth == tls.NoClientCert {
+		return nil, nil
+	}
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, fmt.Errorf("tls.cert_file and tls.key_file are both required when tls.client_auth is set")
+	}
+
+	certificate, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load tls certificate: %w", err)
+	}
+
+	tlsConfig := &server.TLSConfig{
+		Certificates:       []tls.Certificate{certificate},
+		ClientAuth:         clientAuth,
+		AllowedCommonNames: cfg.AllowedCommonNames,
+		AllowedOUs:         cfg.AllowedOUs,
+	}
+
+	if cfg.ClientCAFile != "" {
+		caBytes, err := os.ReadFile(filepath.Clean(cfg.ClientCAFile))
+		if err != nil {
+			return nil, fmt.Errorf("read tls.client_ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("tls.client_ca_file does not contain any valid PEM certificates")
+		}
+		tlsConfig.ClientCAs = pool
+	}
+
+	return tlsConfig, nil
+}
@@ -3,102 +3,300 @@ package main
 import (
 	"bufio"
 	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/redis/go-redis/v9"
+
 	"envirostation/backend/internal/server"
 )
 
 func main() {
-	loadLocalEnvFiles(".env")
+	loadLocalEnvFiles(false, ".env")
+
+	configPathFlag := flag.String("config", "", "path to a YAML config file covering every operator-tunable setting (see ENVIROSTATION_CONFIG); environment variables still override it")
+	flag.Parse()
+
+	configPath := strings.TrimSpace(*configPathFlag)
+	if configPath == "" {
+		configPath = strings.TrimSpace(os.Getenv("ENVIROSTATION_CONFIG"))
+	}
+	fileConfig, err := LoadFileConfig(configPath)
+	if err != nil {
+		log.Fatalf("load config: %v", err)
+	}
 
-	port := envOrDefault("PORT", "8080")
+	if err := server.ConfigureLogging(envOrDefault("LOG_FORMAT", fileConfig.Server.LogFormat), envOrDefault("LOG_LEVEL", fileConfig.Server.LogLevel)); err != nil {
+		log.Fatalf("configure logging: %v", err)
+	}
+
+	port := envOrDefault("PORT", fileConfig.Server.Port)
 	ingestAPIKey := strings.TrimSpace(os.Getenv("INGEST_API_KEY"))
 	if ingestAPIKey == "" {
 		log.Fatal("INGEST_API_KEY is required")
 	}
 
 	databaseURL := strings.TrimSpace(os.Getenv("DATABASE_URL"))
-	if databaseURL == "" {
-		log.Fatal("DATABASE_URL is required")
+	redisURL := strings.TrimSpace(os.Getenv("REDIS_URL"))
+	if databaseURL == "" && redisURL == "" {
+		log.Fatal("one of DATABASE_URL or REDIS_URL is required")
 	}
 
 	setupCtx, cancelSetup := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancelSetup()
 
-	store, err := server.NewPostgresStore(
-		setupCtx,
-		databaseURL,
-		int32(intOrDefault("PG_MAX_CONNS", 10)),
+	lifecycleCtx, stopLifecycle := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopLifecycle()
+
+	var store server.Store
+	var retentionDone <-chan struct{}
+	retentionSettingsValue := &atomic.Value{}
+	if redisURL != "" {
+		redisStore, err := buildRedisStore(redisURL, fileConfig)
+		if err != nil {
+			log.Fatalf("create redis store: %v", err)
+		}
+		store = redisStore
+		log.Printf("using redis store for reading history and insights snapshots")
+	} else {
+		pgStore, err := server.NewPostgresStore(
+			setupCtx,
+			databaseURL,
+			int32(intOrDefault("PG_MAX_CONNS", fileConfig.Postgres.MaxConns)),
+		)
+		if err != nil {
+			log.Fatalf("create postgres store: %v", err)
+		}
+		store = pgStore
+		retentionSettingsValue.Store(newRetentionSettingsFromEnv(fileConfig))
+		retentionDone = startRetentionWorker(lifecycleCtx, pgStore, retentionSettingsValue)
+	}
+	defer store.Close()
+
+	options := make([]server.APIOption, 0, 2)
+	options = append(options,
+		server.WithTrustProxyIP(boolOrDefault("TRUST_PROXY_HEADERS", fileConfig.Server.TrustProxyHeaders)),
+		server.WithDatabaseURL(databaseURL),
 	)
+
+	if metricsBearerToken := strings.TrimSpace(os.Getenv("METRICS_BEARER_TOKEN")); metricsBearerToken != "" {
+		options = append(options, server.WithMetricsBearerToken(metricsBearerToken))
+	}
+
+	if bootstrapToken := strings.TrimSpace(os.Getenv("DEVICE_BOOTSTRAP_TOKEN")); bootstrapToken != "" {
+		options = append(options, server.WithDeviceBootstrapToken(bootstrapToken))
+	}
+	if adminAPIKey := strings.TrimSpace(os.Getenv("ADMIN_API_KEY")); adminAPIKey != "" {
+		options = append(options, server.WithAdminAPIKey(adminAPIKey))
+	}
+
+	tlsConfig, err := loadTLSConfig(fileConfig.TLS)
 	if err != nil {
-		log.Fatalf("create postgres store: %v", err)
+		log.Fatalf("load tls config: %v", err)
+	}
+	if tlsConfig != nil {
+		options = append(options, server.WithTLS(*tlsConfig))
 	}
-	defer store.Close()
 
-	startRetentionWorker(store)
+	if jwtPublicKey := strings.TrimSpace(os.Getenv("JWT_PUBLIC_KEY")); jwtPublicKey != "" {
+		publicKeyBytes, err := base64.StdEncoding.DecodeString(jwtPublicKey)
+		if err != nil || len(publicKeyBytes) != ed25519.PublicKeySize {
+			log.Fatal("JWT_PUBLIC_KEY must be a base64-encoded ed25519 public key")
+		}
+		options = append(options, server.WithJWTPublicKey(ed25519.PublicKey(publicKeyBytes)))
 
-	options := make([]server.APIOption, 0, 1)
-	options = append(options, server.WithTrustProxyIP(boolOrDefault("TRUST_PROXY_HEADERS", false)))
+		if readScopes := strings.TrimSpace(os.Getenv("AUTH_REQUIRED_SCOPES")); readScopes != "" {
+			options = append(options, server.WithAuthRequired(strings.Fields(readScopes)...))
+		}
+	}
 
 	openAIAPIKey := strings.TrimSpace(os.Getenv("OPENAI_API_KEY"))
-	if openAIAPIKey != "" {
-		insightsModel := envOrDefault("OPENAI_INSIGHTS_MODEL", "gpt-5-mini")
-		insightsBaseURL := envOrDefault("OPENAI_BASE_URL", "https://api.openai.com/v1")
-		insightsMax := intOrDefault("OPENAI_INSIGHTS_MAX", 4)
-		insightsAnalysisLimit := intOrDefault("OPENAI_INSIGHTS_ANALYSIS_LIMIT", 900)
-		insightsRefreshInterval := durationOrDefault("OPENAI_INSIGHTS_REFRESH_INTERVAL", time.Hour)
-		insightsEventMinInterval := durationOrDefault(
-			"OPENAI_INSIGHTS_EVENT_MIN_INTERVAL",
-			10*time.Minute,
-		)
-		insightsPM2Trigger := floatOrDefault("OPENAI_INSIGHTS_PM2_TRIGGER", 15.0)
-		insightsPM10Trigger := floatOrDefault("OPENAI_INSIGHTS_PM10_TRIGGER", 45.0)
-		insightsPM2DeltaTrigger := floatOrDefault("OPENAI_INSIGHTS_PM2_DELTA_TRIGGER", 8.0)
-		insightsPM10DeltaTrigger := floatOrDefault("OPENAI_INSIGHTS_PM10_DELTA_TRIGGER", 15.0)
-		insightsAnalyzeTimeout := durationOrDefault("OPENAI_INSIGHTS_ANALYZE_TIMEOUT", 15*time.Second)
-
-		alertAnalyzer := server.NewOpenAIAlertAnalyzer(
-			openAIAPIKey,
-			insightsModel,
-			insightsBaseURL,
-			insightsMax,
-		)
-		options = append(
-			options,
-			server.WithAlertAnalyzer(alertAnalyzer),
-			server.WithInsightsSchedulerConfig(server.InsightsSchedulerConfig{
-				AnalysisLimit:    insightsAnalysisLimit,
-				RefreshInterval:  insightsRefreshInterval,
-				EventMinInterval: insightsEventMinInterval,
-				PM2Threshold:     insightsPM2Trigger,
-				PM10Threshold:    insightsPM10Trigger,
-				PM2DeltaTrigger:  insightsPM2DeltaTrigger,
-				PM10DeltaTrigger: insightsPM10DeltaTrigger,
-				AnalyzeTimeout:   insightsAnalyzeTimeout,
-			}),
-		)
-		log.Printf(
-			"ai insights enabled model=%s analysis_limit=%d refresh_interval=%s",
-			insightsModel,
-			insightsAnalysisLimit,
-			insightsRefreshInterval,
-		)
-	} else {
-		log.Printf("ai insights disabled (set OPENAI_API_KEY to enable)")
+	insightsModel := envOrDefault("OPENAI_INSIGHTS_MODEL", fileConfig.Insights.Model)
+	insightsBaseURL := envOrDefault("OPENAI_BASE_URL", fileConfig.Insights.BaseURL)
+	insightsMax := intOrDefault("OPENAI_INSIGHTS_MAX", fileConfig.Insights.MaxAlerts)
+	insightsAnalysisLimit := intOrDefault("OPENAI_INSIGHTS_ANALYSIS_LIMIT", fileConfig.Insights.AnalysisLimit)
+	insightsRefreshInterval := durationOrDefault("OPENAI_INSIGHTS_REFRESH_INTERVAL", time.Duration(fileConfig.Insights.RefreshInterval))
+	insightsEventMinInterval := durationOrDefault("OPENAI_INSIGHTS_EVENT_MIN_INTERVAL", time.Duration(fileConfig.Insights.EventMinInterval))
+	insightsPM2Trigger := floatOrDefault("OPENAI_INSIGHTS_PM2_TRIGGER", fileConfig.Insights.PM2Trigger)
+	insightsPM10Trigger := floatOrDefault("OPENAI_INSIGHTS_PM10_TRIGGER", fileConfig.Insights.PM10Trigger)
+	insightsPM2DeltaTrigger := floatOrDefault("OPENAI_INSIGHTS_PM2_DELTA_TRIGGER", fileConfig.Insights.PM2DeltaTrigger)
+	insightsPM10DeltaTrigger := floatOrDefault("OPENAI_INSIGHTS_PM10_DELTA_TRIGGER", fileConfig.Insights.PM10DeltaTrigger)
+	insightsAnalyzeTimeout := durationOrDefault("OPENAI_INSIGHTS_ANALYZE_TIMEOUT", time.Duration(fileConfig.Insights.AnalyzeTimeout))
+
+	ollamaBaseURL := envOrDefault("OLLAMA_BASE_URL", fileConfig.Ollama.BaseURL)
+	ollamaModel := envOrDefault("OLLAMA_MODEL", fileConfig.Ollama.Model)
+
+	anthropicAPIKey := strings.TrimSpace(os.Getenv("ANTHROPIC_API_KEY"))
+	anthropicModel := envOrDefault("ANTHROPIC_MODEL", fileConfig.Anthropic.Model)
+	anthropicBaseURL := envOrDefault("ANTHROPIC_BASE_URL", fileConfig.Anthropic.BaseURL)
+
+	azureOpenAIAPIKey := strings.TrimSpace(os.Getenv("AZURE_OPENAI_API_KEY"))
+	azureOpenAIEndpoint := strings.TrimSpace(os.Getenv("AZURE_OPENAI_ENDPOINT"))
+	azureOpenAIDeployment := envOrDefault("AZURE_OPENAI_DEPLOYMENT", fileConfig.AzureOpenAI.Deployment)
+	azureOpenAIAPIVersion := envOrDefault("AZURE_OPENAI_API_VERSION", fileConfig.AzureOpenAI.APIVersion)
+
+	ruleThresholds := server.RuleBasedThresholds{
+		HumidityMoldPercent: floatOrDefault("RULES_HUMIDITY_MOLD_PERCENT", fileConfig.Rules.HumidityMoldPercent),
+		HumidityDryPercent:  floatOrDefault("RULES_HUMIDITY_DRY_PERCENT", fileConfig.Rules.HumidityDryPercent),
+		TempComfortLowC:     floatOrDefault("RULES_TEMP_COMFORT_LOW_C", fileConfig.Rules.TempComfortLowC),
+		TempComfortHighC:    floatOrDefault("RULES_TEMP_COMFORT_HIGH_C", fileConfig.Rules.TempComfortHighC),
+	}
+	ruleAnalyzer := server.NewRuleBasedAlertAnalyzer(ruleThresholds, intOrDefault("RULES_MAX_ALERTS", fileConfig.Rules.MaxAlerts))
+
+	analyzerBackend := strings.ToUpper(strings.TrimSpace(os.Getenv("INSIGHTS_ANALYZER_BACKEND")))
+	if analyzerBackend == "" {
+		if openAIAPIKey != "" {
+			analyzerBackend = "OPENAI"
+		} else {
+			analyzerBackend = "RULES"
+		}
+	}
+
+	// buildNamedAlertAnalyzer constructs the analyzer for one backend name,
+	// shared by the top-level switch below and by HYBRID/FAILOVER, which
+	// each need to resolve backend names of their own.
+	buildNamedAlertAnalyzer := func(name string) server.AlertAnalyzer {
+		switch name {
+		case "OPENAI":
+			if openAIAPIKey == "" {
+				log.Fatalf("backend %s requires OPENAI_API_KEY", name)
+			}
+			return server.NewOpenAIAlertAnalyzer(openAIAPIKey, insightsModel, insightsBaseURL, insightsMax)
+		case "OLLAMA":
+			return server.NewOllamaAlertAnalyzer(ollamaBaseURL, ollamaModel, insightsMax)
+		case "ANTHROPIC":
+			if anthropicAPIKey == "" {
+				log.Fatalf("backend %s requires ANTHROPIC_API_KEY", name)
+			}
+			return server.NewAnthropicAlertAnalyzer(anthropicAPIKey, anthropicModel, anthropicBaseURL, insightsMax)
+		case "AZURE_OPENAI":
+			if azureOpenAIAPIKey == "" || azureOpenAIEndpoint == "" {
+				log.Fatalf("backend %s requires AZURE_OPENAI_API_KEY and AZURE_OPENAI_ENDPOINT", name)
+			}
+			return server.NewAzureOpenAIAlertAnalyzer(azureOpenAIAPIKey, azureOpenAIEndpoint, azureOpenAIDeployment, azureOpenAIAPIVersion, insightsMax)
+		case "RULES":
+			return ruleAnalyzer
+		default:
+			log.Fatalf("unknown alert analyzer backend %q (want RULES, OPENAI, OLLAMA, ANTHROPIC, or AZURE_OPENAI)", name)
+			return nil
+		}
+	}
+
+	var alertAnalyzer server.AlertAnalyzer
+	switch analyzerBackend {
+	case "OPENAI", "OLLAMA", "ANTHROPIC", "AZURE_OPENAI", "RULES":
+		alertAnalyzer = buildNamedAlertAnalyzer(analyzerBackend)
+	case "HYBRID":
+		enrichBackend := strings.ToUpper(envOrDefault("INSIGHTS_ENRICH_BACKEND", fileConfig.Insights.EnrichBackend))
+		alertAnalyzer = server.NewHybridAlertAnalyzer(ruleAnalyzer, buildNamedAlertAnalyzer(enrichBackend), insightsMax)
+	case "FAILOVER":
+		primaryBackend := strings.ToUpper(envOrDefault("INSIGHTS_FAILOVER_PRIMARY", fileConfig.Insights.FailoverPrimary))
+		secondaryBackend := strings.ToUpper(envOrDefault("INSIGHTS_FAILOVER_SECONDARY", fileConfig.Insights.FailoverSecondary))
+		alertAnalyzer = server.NewFailoverAlertAnalyzer(buildNamedAlertAnalyzer(primaryBackend), buildNamedAlertAnalyzer(secondaryBackend))
+	default:
+		log.Fatalf("unknown INSIGHTS_ANALYZER_BACKEND %q (want RULES, OPENAI, OLLAMA, ANTHROPIC, AZURE_OPENAI, HYBRID, or FAILOVER)", analyzerBackend)
+	}
+
+	options = append(
+		options,
+		server.WithAlertAnalyzer(alertAnalyzer),
+		server.WithInsightsSchedulerConfig(server.InsightsSchedulerConfig{
+			AnalysisLimit:    insightsAnalysisLimit,
+			RefreshInterval:  insightsRefreshInterval,
+			EventMinInterval: insightsEventMinInterval,
+			PM2Threshold:     insightsPM2Trigger,
+			PM10Threshold:    insightsPM10Trigger,
+			PM2DeltaTrigger:  insightsPM2DeltaTrigger,
+			PM10DeltaTrigger: insightsPM10DeltaTrigger,
+			AnalyzeTimeout:   insightsAnalyzeTimeout,
+		}),
+	)
+	log.Printf(
+		"insights enabled backend=%s analysis_limit=%d refresh_interval=%s",
+		analyzerBackend,
+		insightsAnalysisLimit,
+		insightsRefreshInterval,
+	)
+
+	if subscribers := buildSubscribers(fileConfig); len(subscribers) > 0 {
+		options = append(options, server.WithSubscribers(subscribers...))
+	}
+
+	options = append(options, server.WithOpsConfig(server.OpsConfig{
+		DeviceOfflineTimeout: durationOrDefault("OPS_DEVICE_OFFLINE_TIMEOUT", time.Duration(fileConfig.Ops.DeviceOfflineTimeout)),
+		MonitorInterval:      durationOrDefault("OPS_MONITOR_INTERVAL", time.Duration(fileConfig.Ops.MonitorInterval)),
+		RetentionPeriod:      durationOrDefault("OPS_EVENTS_RETENTION", time.Duration(fileConfig.Ops.EventsRetention)),
+		IngestSessionTTL:     durationOrDefault("OPS_INGEST_SESSION_TTL", time.Duration(fileConfig.Ops.IngestSessionTTL)),
+	}))
+
+	if opsEventsPath := strings.TrimSpace(os.Getenv("OPS_EVENTS_SQLITE_PATH")); opsEventsPath != "" {
+		opsEventStore, err := server.NewSQLiteOpsEventStore(opsEventsPath)
+		if err != nil {
+			log.Fatalf("open ops events sqlite store: %v", err)
+		}
+		defer opsEventStore.Close()
+		options = append(options, server.WithOpsEventStore(opsEventStore))
+		log.Printf("persisting ops events to sqlite at %s", opsEventsPath)
 	}
 
 	api := server.NewAPI(store, ingestAPIKey, options...)
 
-	handler := withCORS(envOrDefault("CORS_ALLOW_ORIGIN", "*"), api.Handler())
+	var udpIngestListener io.Closer
+	if udpIngestAddr := strings.TrimSpace(os.Getenv("UDP_INGEST_ADDR")); udpIngestAddr != "" {
+		udpIngestListener, err = api.StartUDPIngestListener(lifecycleCtx, server.UDPIngestConfig{
+			Addr:             udpIngestAddr,
+			Format:           envOrDefault("UDP_INGEST_FORMAT", fileConfig.Server.UDPIngestFormat),
+			CoalesceInterval: durationOrDefault("UDP_INGEST_COALESCE_INTERVAL", time.Duration(fileConfig.Server.UDPIngestCoalesceInterval)),
+			CoalesceMaxBatch: intOrDefault("UDP_INGEST_COALESCE_MAX_BATCH", fileConfig.Server.UDPIngestCoalesceMaxBatch),
+		})
+		if err != nil {
+			log.Fatalf("start udp ingest listener: %v", err)
+		}
+		log.Printf("udp ingest listening on %s", udpIngestAddr)
+	}
+
+	insightsConfigFile := strings.TrimSpace(os.Getenv("INSIGHTS_CONFIG_FILE"))
+	if insightsConfigFile != "" {
+		if err := api.WatchInsightsConfigFile(lifecycleCtx, insightsConfigFile); err != nil {
+			log.Fatalf("watch insights config file: %v", err)
+		}
+		log.Printf("watching %s for insights threshold hot-reloads", insightsConfigFile)
+	}
+
+	initialCORSSettings, err := newCORSSettings(envOrDefault("CORS_ALLOW_ORIGIN", fileConfig.CORS.AllowOrigin))
+	if err != nil {
+		log.Fatalf("parse CORS_ALLOW_ORIGIN: %v", err)
+	}
+	corsSettingsValue := &atomic.Value{}
+	corsSettingsValue.Store(initialCORSSettings)
+	trustRequestID := boolOrDefault("TRUST_REQUEST_ID", fileConfig.Server.TrustRequestID)
+	handler := withRequestID(trustRequestID, withCORS(corsSettingsValue, api.Handler()))
+
+	listenAddr := envOrDefault("LISTEN_ADDR", fileConfig.Server.ListenAddr)
+	if listenAddr == "" {
+		listenAddr = ":" + port
+	}
+	listener, err := api.Listen(listenAddr)
+	if err != nil {
+		log.Fatalf("listen on %s: %v", listenAddr, err)
+	}
 
 	httpServer := &http.Server{
-		Addr:              ":" + port,
 		Handler:           handler,
 		ReadTimeout:       15 * time.Second,
 		ReadHeaderTimeout: 5 * time.Second,
@@ -107,20 +305,193 @@ func main() {
 		IdleTimeout:  60 * time.Second,
 	}
 
-	log.Printf("ingest service listening on :%s", port)
-	if err = httpServer.ListenAndServe(); err != nil {
-		log.Fatal(err)
+	metricsAddr := envOrDefault("METRICS_ADDR", fileConfig.Metrics.Addr)
+	metricsServer := &http.Server{
+		Addr:              metricsAddr,
+		Handler:           api.MetricsHandler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		log.Printf("metrics listening on %s", metricsAddr)
+		if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("metrics server error: %v", err)
+		}
+	}()
+
+	reloadSignals := make(chan os.Signal, 1)
+	signal.Notify(reloadSignals, syscall.SIGHUP)
+	go func() {
+		for range reloadSignals {
+			reloadConfiguration(api, fileConfig, retentionSettingsValue, corsSettingsValue, insightsConfigFile != "")
+		}
+	}()
+
+	serverErrors := make(chan error, 1)
+	go func() {
+		log.Printf("ingest service listening on %s", api.ListenAddr())
+		if err := httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErrors <- err
+			return
+		}
+		serverErrors <- nil
+	}()
+
+	select {
+	case err := <-serverErrors:
+		if err != nil {
+			log.Fatalf("server error: %v", err)
+		}
+	case <-lifecycleCtx.Done():
+		log.Printf("shutdown signal received, draining connections")
+	}
+
+	// Stop accepting new background work and let active SSE subscribers
+	// flush a terminal event before their connections are closed below.
+	api.Shutdown()
+	stopLifecycle()
+
+	if udpIngestListener != nil {
+		if err := udpIngestListener.Close(); err != nil {
+			log.Printf("udp ingest listener close did not complete cleanly: %v", err)
+		}
+	}
+
+	shutdownTimeout := durationOrDefault("SHUTDOWN_TIMEOUT", time.Duration(fileConfig.Server.ShutdownTimeout))
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("graceful shutdown did not complete cleanly: %v", err)
+	}
+	if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("metrics server shutdown did not complete cleanly: %v", err)
+	}
+
+	if retentionDone != nil {
+		select {
+		case <-retentionDone:
+		case <-shutdownCtx.Done():
+			log.Printf("retention worker did not stop before the shutdown deadline")
+		}
+	}
+
+	log.Printf("shutdown complete")
+}
+
+// corsSettings is the parsed form of CORS_ALLOW_ORIGIN, held in an
+// atomic.Value so a SIGHUP reload can retune it without restarting the
+// listener or racing with in-flight requests.
+type corsSettings struct {
+	allowedOrigins []originMatcher
+	allowAny       bool
+}
+
+func newCORSSettings(allowedOrigin string) (*corsSettings, error) {
+	allowedOrigins, allowAny, err := parseAllowedOrigins(allowedOrigin)
+	if err != nil {
+		return nil, err
+	}
+	return &corsSettings{allowedOrigins: allowedOrigins, allowAny: allowAny}, nil
+}
+
+// originMatcher matches one comma-separated entry of CORS_ALLOW_ORIGIN
+// against an incoming request's Origin header. parseOriginPattern compiles
+// the raw pattern into exactly one of the three forms below, so the
+// per-request hot path (matches) never allocates or compiles a regex.
+type originMatcher struct {
+	exact          string
+	wildcardScheme string
+	wildcardSuffix string
+	regex          *regexp.Regexp
+}
+
+func (matcher originMatcher) matches(origin string) bool {
+	switch {
+	case matcher.regex != nil:
+		return matcher.regex.MatchString(origin)
+	case matcher.wildcardSuffix != "":
+		rest, ok := strings.CutPrefix(origin, matcher.wildcardScheme)
+		if !ok {
+			return false
+		}
+		label, ok := strings.CutSuffix(rest, matcher.wildcardSuffix)
+		return ok && label != "" && !strings.Contains(label, ".")
+	default:
+		return origin == matcher.exact
 	}
 }
 
-func withCORS(allowedOrigin string, next http.Handler) http.Handler {
-	allowedOrigins, allowAny := parseAllowedOrigins(allowedOrigin)
+// parseOriginPattern compiles one CORS_ALLOW_ORIGIN entry into an
+// originMatcher. Three forms are supported: an exact origin (the
+// default), a single-label wildcard host such as "https://*.example.com"
+// (the "*" must be the entire leftmost host label — it never expands
+// across a dot boundary), and an anchored regex prefixed with "re:", e.g.
+// "re:^https://pr-\d+\.staging\.example\.com$".
+func parseOriginPattern(pattern string) (originMatcher, error) {
+	if rawRegex, ok := strings.CutPrefix(pattern, "re:"); ok {
+		compiled, err := regexp.Compile(rawRegex)
+		if err != nil {
+			return originMatcher{}, fmt.Errorf("invalid CORS origin regex %q: %w", pattern, err)
+		}
+		return originMatcher{regex: compiled}, nil
+	}
+
+	if strings.Contains(pattern, "*") {
+		scheme, host, found := strings.Cut(pattern, "://")
+		if !found {
+			return originMatcher{}, fmt.Errorf("invalid CORS wildcard origin %q: missing scheme", pattern)
+		}
+		if strings.Count(pattern, "*") != 1 || !strings.HasPrefix(host, "*.") {
+			return originMatcher{}, fmt.Errorf(
+				"invalid CORS wildcard origin %q: \"*\" must be the entire leftmost host label, e.g. https://*.example.com",
+				pattern,
+			)
+		}
+		return originMatcher{
+			wildcardScheme: scheme + "://",
+			wildcardSuffix: strings.TrimPrefix(host, "*"),
+		}, nil
+	}
+
+	return originMatcher{exact: pattern}, nil
+}
 
+// requestIDHeader is the header a request may set (honored only when
+// trustRequestID is true) and that withRequestID always echoes back on the
+// response, so a reverse proxy or client can correlate its own logs with
+// this service's.
+const requestIDHeader = "X-Request-ID"
+
+// withRequestID stashes a correlation ID into the request's context
+// (server.WithRequestID) and echoes it back via the X-Request-ID response
+// header, so ingest-path log lines can be traced to one client call. With
+// trustRequestID false (the default, matching TRUST_PROXY_HEADERS), any
+// incoming X-Request-ID is ignored and a fresh UUIDv7 is minted instead, so
+// an untrusted client can't inject an arbitrary value into structured logs
+// or collide with another tenant's correlation ID.
+func withRequestID(trustRequestID bool, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		requestID := ""
+		if trustRequestID {
+			requestID = strings.TrimSpace(request.Header.Get(requestIDHeader))
+		}
+		if requestID == "" {
+			requestID = server.NewRequestID()
+		}
+
+		response.Header().Set(requestIDHeader, requestID)
+		next.ServeHTTP(response, request.WithContext(server.WithRequestID(request.Context(), requestID)))
+	})
+}
+
+func withCORS(settingsValue *atomic.Value, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		settings := settingsValue.Load().(*corsSettings)
+
 		origin := strings.TrimSpace(request.Header.Get("Origin"))
-		if allowAny {
+		if settings.allowAny {
 			response.Header().Set("Access-Control-Allow-Origin", "*")
-		} else if origin != "" && originAllowed(origin, allowedOrigins) {
+		} else if origin != "" && originAllowed(origin, settings.allowedOrigins) {
 			response.Header().Set("Access-Control-Allow-Origin", origin)
 			response.Header().Set("Vary", "Origin")
 		}
@@ -137,34 +508,39 @@ func withCORS(allowedOrigin string, next http.Handler) http.Handler {
 	})
 }
 
-func parseAllowedOrigins(raw string) ([]string, bool) {
+func parseAllowedOrigins(raw string) ([]originMatcher, bool, error) {
 	value := strings.TrimSpace(raw)
 	if value == "" || value == "*" {
-		return nil, true
+		return nil, true, nil
 	}
 
 	parts := strings.Split(value, ",")
-	origins := make([]string, 0, len(parts))
+	matchers := make([]originMatcher, 0, len(parts))
 	for _, part := range parts {
 		trimmed := strings.TrimSpace(part)
 		if trimmed == "" {
 			continue
 		}
 		if trimmed == "*" {
-			return nil, true
+			return nil, true, nil
+		}
+
+		matcher, err := parseOriginPattern(trimmed)
+		if err != nil {
+			return nil, false, err
 		}
-		origins = append(origins, trimmed)
+		matchers = append(matchers, matcher)
 	}
 
-	if len(origins) == 0 {
-		return nil, true
+	if len(matchers) == 0 {
+		return nil, true, nil
 	}
-	return origins, false
+	return matchers, false, nil
 }
 
-func originAllowed(origin string, allowedOrigins []string) bool {
-	for _, allowedOrigin := range allowedOrigins {
-		if origin == allowedOrigin {
+func originAllowed(origin string, allowedOrigins []originMatcher) bool {
+	for _, matcher := range allowedOrigins {
+		if matcher.matches(origin) {
 			return true
 		}
 	}
@@ -234,15 +610,20 @@ func boolOrDefault(key string, fallback bool) bool {
 	}
 }
 
-func loadLocalEnvFiles(paths ...string) {
+// loadLocalEnvFiles loads each path into the process environment. With
+// force false (startup), a variable already set in the environment wins
+// over the file, matching normal shell-over-dotenv precedence. With force
+// true, used only by the SIGHUP reload path, the file's value always
+// overwrites the environment so an edited .env actually takes effect.
+func loadLocalEnvFiles(force bool, paths ...string) {
 	for _, path := range paths {
-		if err := loadLocalEnvFile(path); err != nil {
+		if err := loadLocalEnvFile(path, force); err != nil {
 			log.Printf("warning: failed to load %s: %v", path, err)
 		}
 	}
 }
 
-func loadLocalEnvFile(path string) error {
+func loadLocalEnvFile(path string, force bool) error {
 	fileHandle, err := os.Open(filepath.Clean(path))
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -273,8 +654,10 @@ func loadLocalEnvFile(path string) error {
 			continue
 		}
 
-		if _, alreadySet := os.LookupEnv(key); alreadySet {
-			continue
+		if !force {
+			if _, alreadySet := os.LookupEnv(key); alreadySet {
+				continue
+			}
 		}
 
 		value = strings.TrimSpace(value)
@@ -287,64 +670,245 @@ func loadLocalEnvFile(path string) error {
 	return scanner.Err()
 }
 
-func startRetentionWorker(store *server.PostgresStore) {
-	if !boolOrDefault("RETENTION_ENABLED", true) {
-		log.Printf("retention cleanup disabled")
-		return
+// buildRedisStore connects to redisURL and returns a RedisStore sharing its
+// reading history and InsightsSnapshot across every process pointed at the
+// same Redis instance, so one node can run the analyzer while others only
+// serve reads.
+func buildRedisStore(redisURL string, fileConfig *FileConfig) (*server.RedisStore, error) {
+	options, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse REDIS_URL: %w", err)
+	}
+	client := redis.NewClient(options)
+
+	pingCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := client.Ping(pingCtx).Err(); err != nil {
+		return nil, fmt.Errorf("ping redis: %w", err)
 	}
 
-	retentionDays := intOrDefault("RETENTION_DAYS", 60)
-	if retentionDays < 1 {
-		retentionDays = 60
+	keyPrefix := envOrDefault("REDIS_KEY_PREFIX", fileConfig.Redis.KeyPrefix)
+	maxReadings := intOrDefault("REDIS_MAX_READINGS", fileConfig.Redis.MaxReadings)
+
+	var storeOptions []server.RedisStoreOption
+	if envOrDefault("REDIS_CODEC", fileConfig.Redis.Codec) == "msgpack" {
+		storeOptions = append(storeOptions, server.WithRedisCodec(server.MsgpackReadingCodec()))
 	}
 
-	batchSize := intOrDefault("RETENTION_BATCH_SIZE", 5000)
+	return server.NewRedisStore(client, keyPrefix, maxReadings, storeOptions...), nil
+}
+
+// buildSubscribers assembles the external subscription sinks (webhook,
+// MQTT, InfluxDB) configured via environment variables. Any combination may
+// be enabled at once; an unset sink's env vars are simply left empty.
+func buildSubscribers(fileConfig *FileConfig) []server.Subscriber {
+	var subscribers []server.Subscriber
+
+	if webhookURL := strings.TrimSpace(os.Getenv("SUBSCRIBE_WEBHOOK_URL")); webhookURL != "" {
+		webhookSecret := strings.TrimSpace(os.Getenv("SUBSCRIBE_WEBHOOK_SECRET"))
+		subscribers = append(subscribers, server.NewWebhookSubscriber(webhookURL, webhookSecret))
+		log.Printf("subscription sink enabled: webhook %s", webhookURL)
+	}
+
+	if mqttBroker := strings.TrimSpace(os.Getenv("SUBSCRIBE_MQTT_BROKER")); mqttBroker != "" {
+		mqttClientID := envOrDefault("SUBSCRIBE_MQTT_CLIENT_ID", fileConfig.Redis.MQTTClientID)
+		mqttBaseTopic := envOrDefault("SUBSCRIBE_MQTT_TOPIC", fileConfig.Redis.MQTTTopic)
+		mqttQoS := intOrDefault("SUBSCRIBE_MQTT_QOS", fileConfig.Redis.MQTTQoS)
+		subscribers = append(subscribers, server.NewMQTTSubscriber(mqttBroker, mqttClientID, mqttBaseTopic, byte(mqttQoS)))
+		log.Printf("subscription sink enabled: mqtt %s topic=%s qos=%d", mqttBroker, mqttBaseTopic, mqttQoS)
+	}
+
+	if influxURL := strings.TrimSpace(os.Getenv("SUBSCRIBE_INFLUX_URL")); influxURL != "" {
+		influxOrg := strings.TrimSpace(os.Getenv("SUBSCRIBE_INFLUX_ORG"))
+		influxBucket := strings.TrimSpace(os.Getenv("SUBSCRIBE_INFLUX_BUCKET"))
+		influxToken := strings.TrimSpace(os.Getenv("SUBSCRIBE_INFLUX_TOKEN"))
+		subscribers = append(subscribers, server.NewInfluxLineProtocolSubscriber(influxURL, influxOrg, influxBucket, influxToken))
+		log.Printf("subscription sink enabled: influx %s org=%s bucket=%s", influxURL, influxOrg, influxBucket)
+	}
+
+	return subscribers
+}
+
+// retentionSettings is the parsed form of RETENTION_ENABLED/DAYS/
+// BATCH_SIZE/INTERVAL, held in an atomic.Value so a SIGHUP reload can
+// retune them without restarting the worker goroutine.
+type retentionSettings struct {
+	enabled   bool
+	days      int
+	batchSize int
+	interval  time.Duration
+}
+
+func newRetentionSettingsFromEnv(fileConfig *FileConfig) *retentionSettings {
+	days := intOrDefault("RETENTION_DAYS", fileConfig.Retention.Days)
+	if days < 1 {
+		days = fileConfig.Retention.Days
+	}
+
+	batchSize := intOrDefault("RETENTION_BATCH_SIZE", fileConfig.Retention.BatchSize)
 	if batchSize < 1 {
-		batchSize = 5000
+		batchSize = fileConfig.Retention.BatchSize
 	}
 
-	interval := durationOrDefault("RETENTION_INTERVAL", 24*time.Hour)
+	interval := durationOrDefault("RETENTION_INTERVAL", time.Duration(fileConfig.Retention.Interval))
 	if interval < time.Minute {
 		interval = time.Minute
 	}
 
+	return &retentionSettings{
+		enabled:   boolOrDefault("RETENTION_ENABLED", fileConfig.Retention.Enabled),
+		days:      days,
+		batchSize: batchSize,
+		interval:  interval,
+	}
+}
+
+// startRetentionWorker launches the periodic DeleteOlderThan sweep and
+// returns a channel that's closed once the worker goroutine has returned.
+// It re-reads settingsValue on every cleanup and before scheduling every
+// tick, so a SIGHUP reload of RETENTION_DAYS/BATCH_SIZE/INTERVAL takes
+// effect on the worker's next cycle. The worker stops as soon as ctx is
+// cancelled: a batch already in flight runs under a context derived from
+// ctx, so cancellation aborts it rather than letting it finish, and no new
+// batch or tick starts afterward, so main can wait on the
+// returned channel before calling store.Close() instead of orphaning an
+// in-flight DELETE on shutdown.
+func startRetentionWorker(ctx context.Context, store *server.PostgresStore, settingsValue *atomic.Value) <-chan struct{} {
+	done := make(chan struct{})
+
 	cleanup := func() {
-		cutoffTimestamp := time.Now().Add(-time.Duration(retentionDays) * 24 * time.Hour).Unix()
+		settings := settingsValue.Load().(*retentionSettings)
+		if !settings.enabled {
+			return
+		}
+
+		requestID := fmt.Sprintf("ret-%d", time.Now().Unix())
+		log := server.Logger().With("component", "retention", "requestID", requestID)
+
+		var totalDeleted int64
+		defer func() { server.RecordRetentionRun(totalDeleted, time.Now()) }()
+
+		// Keep the "default" retention policy's duration in sync with
+		// RETENTION_DAYS before every sweep, so a SIGHUP reload takes
+		// effect even though DeleteOlderThan itself is now policy-driven
+		// rather than taking an explicit cutoff.
+		upsertCtx, cancelUpsert := context.WithTimeout(server.WithRequestID(ctx, requestID), 10*time.Second)
+		err := store.UpsertRetentionPolicy(upsertCtx, server.RetentionPolicy{
+			Name:        server.DefaultRetentionPolicyName,
+			Duration:    time.Duration(settings.days) * 24 * time.Hour,
+			Replication: 1,
+		})
+		cancelUpsert()
+		if err != nil {
+			log.Error("retention policy upsert failed", "error", err)
+			return
+		}
 
 		for {
-			cleanupCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-			deletedRows, err := store.DeleteOlderThan(cleanupCtx, cutoffTimestamp, batchSize)
+			if ctx.Err() != nil {
+				return
+			}
+
+			batchSize := settingsValue.Load().(*retentionSettings).batchSize
+			cleanupCtx, cancel := context.WithTimeout(server.WithRequestID(ctx, requestID), 10*time.Second)
+			deletedRows, err := store.DeleteOlderThan(cleanupCtx, batchSize)
 			cancel()
 			if err != nil {
-				log.Printf("retention cleanup failed: %v", err)
+				if ctx.Err() != nil {
+					return
+				}
+				log.Error("retention cleanup failed", "error", err)
 				return
 			}
 
+			totalDeleted += deletedRows
 			if deletedRows == 0 {
 				return
 			}
 
-			log.Printf(
-				"retention cleanup deleted %d rows older than unix timestamp %d",
-				deletedRows,
-				cutoffTimestamp,
-			)
+			log.Info("retention cleanup deleted rows", "deleted_rows", deletedRows)
 		}
 	}
 
-	log.Printf(
-		"retention cleanup enabled days=%d interval=%s batch_size=%d",
-		retentionDays,
-		interval.String(),
-		batchSize,
-	)
+	initial := settingsValue.Load().(*retentionSettings)
+	if !initial.enabled {
+		log.Printf("retention cleanup disabled")
+	} else {
+		log.Printf(
+			"retention cleanup enabled days=%d interval=%s batch_size=%d",
+			initial.days,
+			initial.interval.String(),
+			initial.batchSize,
+		)
+	}
 
 	go func() {
+		defer close(done)
+
 		cleanup()
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
-		for range ticker.C {
-			cleanup()
+		for {
+			interval := settingsValue.Load().(*retentionSettings).interval
+			timer := time.NewTimer(interval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+				cleanup()
+			}
 		}
 	}()
+
+	return done
+}
+
+// reloadConfiguration re-reads .env (forcing overwrite of already-set
+// environment variables, unlike the startup load) and the process
+// environment, then applies the subset of settings that can change
+// without a restart: insights thresholds, the OpenAI model, CORS allowed
+// origins, and retention days/interval/batch size. It's invoked from the
+// SIGHUP handler registered in main. A reload that would change
+// INGEST_API_KEY or DATABASE_URL is rejected by api.Reload and logged
+// instead of applied, since neither can be safely hot-swapped.
+//
+// insightsConfigFileActive is true when INSIGHTS_CONFIG_FILE's fsnotify
+// watcher (started in main via WatchInsightsConfigFile) already owns the
+// insights thresholds; in that case this reload leaves them alone instead
+// of overwriting them with env/file defaults that may be stale compared to
+// what the watcher last applied.
+func reloadConfiguration(api *server.API, fileConfig *FileConfig, retentionSettingsValue *atomic.Value, corsSettingsValue *atomic.Value, insightsConfigFileActive bool) {
+	loadLocalEnvFiles(true, ".env")
+
+	cfg := server.Config{
+		IngestAPIKey: strings.TrimSpace(os.Getenv("INGEST_API_KEY")),
+		DatabaseURL:  strings.TrimSpace(os.Getenv("DATABASE_URL")),
+		Insights: server.InsightsSchedulerConfig{
+			AnalysisLimit:    intOrDefault("OPENAI_INSIGHTS_ANALYSIS_LIMIT", fileConfig.Insights.AnalysisLimit),
+			RefreshInterval:  durationOrDefault("OPENAI_INSIGHTS_REFRESH_INTERVAL", time.Duration(fileConfig.Insights.RefreshInterval)),
+			EventMinInterval: durationOrDefault("OPENAI_INSIGHTS_EVENT_MIN_INTERVAL", time.Duration(fileConfig.Insights.EventMinInterval)),
+			PM2Threshold:     floatOrDefault("OPENAI_INSIGHTS_PM2_TRIGGER", fileConfig.Insights.PM2Trigger),
+			PM10Threshold:    floatOrDefault("OPENAI_INSIGHTS_PM10_TRIGGER", fileConfig.Insights.PM10Trigger),
+			PM2DeltaTrigger:  floatOrDefault("OPENAI_INSIGHTS_PM2_DELTA_TRIGGER", fileConfig.Insights.PM2DeltaTrigger),
+			PM10DeltaTrigger: floatOrDefault("OPENAI_INSIGHTS_PM10_DELTA_TRIGGER", fileConfig.Insights.PM10DeltaTrigger),
+			AnalyzeTimeout:   durationOrDefault("OPENAI_INSIGHTS_ANALYZE_TIMEOUT", time.Duration(fileConfig.Insights.AnalyzeTimeout)),
+		},
+		SkipInsightsReload: insightsConfigFileActive,
+		OpenAIModel:        envOrDefault("OPENAI_INSIGHTS_MODEL", fileConfig.Insights.Model),
+	}
+
+	if err := api.Reload(cfg); err != nil {
+		log.Printf("configuration reload rejected: %v", err)
+		return
+	}
+
+	reloadedCORSSettings, err := newCORSSettings(envOrDefault("CORS_ALLOW_ORIGIN", fileConfig.CORS.AllowOrigin))
+	if err != nil {
+		log.Printf("configuration reload rejected: %v", err)
+		return
+	}
+
+	retentionSettingsValue.Store(newRetentionSettingsFromEnv(fileConfig))
+	corsSettingsValue.Store(reloadedCORSSettings)
+
+	log.Printf("configuration reloaded from environment")
 }
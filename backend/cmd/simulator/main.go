@@ -10,9 +10,11 @@ import (
 	"log"
 	"math"
 	"math/rand"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"time"
 )
 
@@ -47,14 +49,26 @@ func main() {
 	var timeout time.Duration
 	var count int
 	var seed int64
+	var format string
+	var replayPath string
+	var replaySpeed float64
+	var replayLoop bool
+	var replayRewriteNow bool
+	var transport string
 
-	flag.StringVar(&targetURL, "url", "http://localhost:8080/api/ingest", "ingest endpoint URL")
+	flag.StringVar(&targetURL, "url", "http://localhost:8080/api/ingest", "ingest endpoint URL (for -transport=udp, a host:port instead)")
 	flag.StringVar(&apiKey, "api-key", "dev-ingest-key", "ingest API key")
 	flag.DurationVar(&interval, "interval", 2*time.Second, "base delay between emitted readings")
 	flag.DurationVar(&jitter, "jitter", 500*time.Millisecond, "max random delay added to each interval")
 	flag.DurationVar(&timeout, "timeout", 5*time.Second, "HTTP request timeout")
 	flag.IntVar(&count, "count", 0, "number of readings to emit (0 = infinite)")
 	flag.Int64Var(&seed, "seed", 0, "random seed (0 = use current time)")
+	flag.StringVar(&format, "format", "json", "request body format to emit: json or line")
+	flag.StringVar(&replayPath, "replay", "", "replay historical readings from this CSV or NDJSON file instead of generating synthetic ones")
+	flag.Float64Var(&replaySpeed, "replay-speed", 1.0, "replay pacing multiplier (1 = wall-clock, 10 = ten times faster)")
+	flag.BoolVar(&replayLoop, "loop", false, "re-read the replay file from the start on EOF")
+	flag.BoolVar(&replayRewriteNow, "replay-rewrite-now", false, "shift replayed timestamps so the first reading lands at the current time instead of its recorded time")
+	flag.StringVar(&transport, "transport", "http", "how to deliver readings: http (POST to -url) or udp (datagram to -url's host:port, driving StartUDPIngestListener)")
 	flag.Parse()
 
 	if interval <= 0 {
@@ -72,14 +86,72 @@ func main() {
 	if apiKey == "" {
 		log.Fatal("api-key is required")
 	}
+	if format != "json" && format != "line" {
+		log.Fatal("format must be json or line")
+	}
+	if replaySpeed <= 0 {
+		log.Fatal("replay-speed must be > 0")
+	}
+	if transport != "http" && transport != "udp" {
+		log.Fatal("transport must be http or udp")
+	}
+
+	urlSetExplicitly := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "url" {
+			urlSetExplicitly = true
+		}
+	})
+	if !urlSetExplicitly {
+		switch {
+		case transport == "udp":
+			targetURL = "localhost:9125"
+		case format == "line":
+			targetURL = "http://localhost:8080/api/ingest/line"
+		}
+	}
+
+	client := &http.Client{Timeout: timeout}
+
+	var send func(ctx context.Context, reading sensorReading) error
+	if transport == "udp" {
+		udpConn, err := net.Dial("udp", targetURL)
+		if err != nil {
+			log.Fatalf("dial udp %s: %v", targetURL, err)
+		}
+		defer udpConn.Close()
+		send = func(_ context.Context, reading sensorReading) error {
+			return postReadingUDP(udpConn, apiKey, format, reading)
+		}
+	} else {
+		send = func(ctx context.Context, reading sensorReading) error {
+			return postReading(ctx, client, targetURL, apiKey, format, reading)
+		}
+	}
+
+	if replayPath != "" {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		log.Printf("simulator replaying file=%s speed=%.1f loop=%t transport=%s target=%s", replayPath, replaySpeed, replayLoop, transport, targetURL)
+		if err := runReplay(ctx, replayOptions{
+			path:       replayPath,
+			speed:      replaySpeed,
+			loop:       replayLoop,
+			rewriteNow: replayRewriteNow,
+			send:       send,
+		}); err != nil {
+			log.Fatalf("replay failed: %v", err)
+		}
+		return
+	}
 
 	if seed == 0 {
 		seed = time.Now().UnixNano()
 	}
 	rng := rand.New(rand.NewSource(seed))
-	log.Printf("simulator started seed=%d target=%s interval=%s", seed, targetURL, interval)
+	log.Printf("simulator started seed=%d transport=%s target=%s interval=%s", seed, transport, targetURL, interval)
 
-	client := &http.Client{Timeout: timeout}
 	model := simulator{
 		temperature: 21.0,
 		pressure:    1013.2,
@@ -101,7 +173,7 @@ func main() {
 		}
 
 		reading := model.next(rng, time.Now())
-		if err := postReading(ctx, client, targetURL, apiKey, reading); err != nil {
+		if err := send(ctx, reading); err != nil {
 			log.Printf("send failed: %v", err)
 		} else {
 			emitted++
@@ -167,18 +239,30 @@ func postReading(
 	client *http.Client,
 	targetURL string,
 	apiKey string,
+	format string,
 	reading sensorReading,
 ) error {
-	body, err := json.Marshal(reading)
-	if err != nil {
-		return fmt.Errorf("encode payload: %w", err)
+	var body []byte
+	var contentType string
+
+	switch format {
+	case "line":
+		body = []byte(encodeLineProtocol(reading))
+		contentType = "text/plain; charset=utf-8"
+	default:
+		encoded, err := json.Marshal(reading)
+		if err != nil {
+			return fmt.Errorf("encode payload: %w", err)
+		}
+		body = encoded
+		contentType = "application/json"
 	}
 
 	request, err := http.NewRequestWithContext(ctx, http.MethodPost, targetURL, bytes.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("build request: %w", err)
 	}
-	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Content-Type", contentType)
 	request.Header.Set("X-API-Key", apiKey)
 
 	response, err := client.Do(request)
@@ -195,6 +279,52 @@ func postReading(
 	return nil
 }
 
+// postReadingUDP encodes reading the same way postReading does and sends
+// it as a single UDP datagram, prefixed with apiKey and a newline -- the
+// shared-secret-in-the-first-field credential StartUDPIngestListener
+// checks in place of the X-API-Key header HTTP ingest uses.
+func postReadingUDP(conn net.Conn, apiKey string, format string, reading sensorReading) error {
+	var body []byte
+
+	switch format {
+	case "line":
+		body = []byte(encodeLineProtocol(reading))
+	default:
+		encoded, err := json.Marshal(reading)
+		if err != nil {
+			return fmt.Errorf("encode payload: %w", err)
+		}
+		body = encoded
+	}
+
+	datagram := append([]byte(apiKey+"\n"), body...)
+	_, err := conn.Write(datagram)
+	if err != nil {
+		return fmt.Errorf("write datagram: %w", err)
+	}
+	return nil
+}
+
+// encodeLineProtocol renders reading as a single InfluxDB line protocol
+// point under the "enviro" measurement, the format -format=line posts
+// instead of JSON, using nanosecond precision (the server's default when
+// /api/ingest/line is called without ?precision=).
+func encodeLineProtocol(reading sensorReading) string {
+	return fmt.Sprintf(
+		"enviro temperature=%s,pressure=%s,humidity=%s,oxidised=%s,reduced=%s,nh3=%s,pm1=%s,pm2=%s,pm10=%s %d",
+		strconv.FormatFloat(reading.Temperature, 'f', -1, 64),
+		strconv.FormatFloat(reading.Pressure, 'f', -1, 64),
+		strconv.FormatFloat(reading.Humidity, 'f', -1, 64),
+		strconv.FormatFloat(reading.Oxidised, 'f', -1, 64),
+		strconv.FormatFloat(reading.Reduced, 'f', -1, 64),
+		strconv.FormatFloat(reading.Nh3, 'f', -1, 64),
+		strconv.FormatFloat(reading.PM1, 'f', -1, 64),
+		strconv.FormatFloat(reading.PM2, 'f', -1, 64),
+		strconv.FormatFloat(reading.PM10, 'f', -1, 64),
+		reading.Timestamp*1_000_000,
+	)
+}
+
 func clamp(value float64, min float64, max float64) float64 {
 	if value < min {
 		return min
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// replayOptions configures runReplay, mirroring the flag set -replay=<file>
+// and friends expose on top of the synthetic simulator loop. send delivers
+// one reading however main wired up -transport (HTTP POST or UDP
+// datagram), so runReplay itself stays transport-agnostic.
+type replayOptions struct {
+	path       string
+	speed      float64
+	loop       bool
+	rewriteNow bool
+	send       func(ctx context.Context, reading sensorReading) error
+}
+
+// runReplay streams historical readings from a CSV or NDJSON file (columns
+// matching sensorReading's JSON tags) through options.send, the same
+// delivery path the synthetic loop uses, so a recorded field deployment
+// can be re-run against a dev server for regression testing of insights
+// and alerting rules. Pacing reproduces the gaps between consecutive
+// readings' original timestamps, divided by speed (speed=1 is wall-clock,
+// speed=10 replays ten times faster); rewriteNow shifts every timestamp so
+// the first reading lands at time.Now() instead of its recorded time.
+// loop re-reads the file from the start on EOF.
+func runReplay(ctx context.Context, options replayOptions) error {
+	if options.speed <= 0 {
+		return fmt.Errorf("replay speed must be > 0")
+	}
+
+	var timestampOffset int64
+	emitted := 0
+
+	for {
+		readings, err := loadReplayReadings(options.path)
+		if err != nil {
+			return err
+		}
+		if len(readings) == 0 {
+			return fmt.Errorf("replay file %s contains no readings", options.path)
+		}
+
+		if options.rewriteNow {
+			timestampOffset = time.Now().UnixMilli() - readings[0].Timestamp
+		}
+
+		var previousOriginal int64
+		for index, reading := range readings {
+			if index > 0 {
+				gap := time.Duration(reading.Timestamp-previousOriginal) * time.Millisecond
+				if gap > 0 {
+					select {
+					case <-ctx.Done():
+						return nil
+					case <-time.After(time.Duration(float64(gap) / options.speed)):
+					}
+				}
+			}
+			previousOriginal = reading.Timestamp
+
+			outgoing := reading
+			outgoing.Timestamp += timestampOffset
+
+			if err := options.send(ctx, outgoing); err != nil {
+				log.Printf("replay send failed: %v", err)
+			} else {
+				emitted++
+				log.Printf("replayed #%d pm2=%.1f pm10=%.1f temp=%.1f humidity=%.1f",
+					emitted, outgoing.PM2, outgoing.PM10, outgoing.Temperature, outgoing.Humidity)
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil
+			default:
+			}
+		}
+
+		if !options.loop {
+			log.Printf("replay complete (%d readings sent)", emitted)
+			return nil
+		}
+
+		log.Printf("replay reached EOF, looping")
+	}
+}
+
+// loadReplayReadings parses path as NDJSON (one JSON-encoded sensorReading
+// per line) or CSV (a header row naming sensorReading's JSON fields)
+// depending on its extension.
+func loadReplayReadings(path string) ([]sensorReading, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open replay file: %w", err)
+	}
+	defer file.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return parseReplayCSV(file)
+	}
+	return parseReplayNDJSON(file)
+}
+
+func parseReplayNDJSON(file io.Reader) ([]sensorReading, error) {
+	var readings []sensorReading
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var reading sensorReading
+		if err := json.Unmarshal([]byte(line), &reading); err != nil {
+			return nil, fmt.Errorf("decode ndjson line: %w", err)
+		}
+		readings = append(readings, reading)
+	}
+
+	return readings, scanner.Err()
+}
+
+func parseReplayCSV(file io.Reader) ([]sensorReading, error) {
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read csv header: %w", err)
+	}
+
+	columnIndex := make(map[string]int, len(header))
+	for index, name := range header {
+		columnIndex[strings.TrimSpace(name)] = index
+	}
+
+	var readings []sensorReading
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read csv row: %w", err)
+		}
+
+		reading, err := readingFromCSVRow(row, columnIndex)
+		if err != nil {
+			return nil, err
+		}
+		readings = append(readings, reading)
+	}
+
+	return readings, nil
+}
+
+func readingFromCSVRow(row []string, columnIndex map[string]int) (sensorReading, error) {
+	field := func(name string) (string, bool) {
+		index, ok := columnIndex[name]
+		if !ok || index >= len(row) {
+			return "", false
+		}
+		return row[index], true
+	}
+
+	parseInt := func(name string) (int64, error) {
+		raw, ok := field(name)
+		if !ok {
+			return 0, fmt.Errorf("missing column %q", name)
+		}
+		return strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+	}
+
+	parseFloat := func(name string) (float64, error) {
+		raw, ok := field(name)
+		if !ok {
+			return 0, fmt.Errorf("missing column %q", name)
+		}
+		return strconv.ParseFloat(strings.TrimSpace(raw), 64)
+	}
+
+	var reading sensorReading
+	var err error
+
+	if reading.Timestamp, err = parseInt("timestamp"); err != nil {
+		return sensorReading{}, fmt.Errorf("parse timestamp: %w", err)
+	}
+	if reading.Temperature, err = parseFloat("temperature"); err != nil {
+		return sensorReading{}, fmt.Errorf("parse temperature: %w", err)
+	}
+	if reading.Pressure, err = parseFloat("pressure"); err != nil {
+		return sensorReading{}, fmt.Errorf("parse pressure: %w", err)
+	}
+	if reading.Humidity, err = parseFloat("humidity"); err != nil {
+		return sensorReading{}, fmt.Errorf("parse humidity: %w", err)
+	}
+	if reading.Oxidised, err = parseFloat("oxidised"); err != nil {
+		return sensorReading{}, fmt.Errorf("parse oxidised: %w", err)
+	}
+	if reading.Reduced, err = parseFloat("reduced"); err != nil {
+		return sensorReading{}, fmt.Errorf("parse reduced: %w", err)
+	}
+	if reading.Nh3, err = parseFloat("nh3"); err != nil {
+		return sensorReading{}, fmt.Errorf("parse nh3: %w", err)
+	}
+	if reading.PM1, err = parseFloat("pm1"); err != nil {
+		return sensorReading{}, fmt.Errorf("parse pm1: %w", err)
+	}
+	if reading.PM2, err = parseFloat("pm2"); err != nil {
+		return sensorReading{}, fmt.Errorf("parse pm2: %w", err)
+	}
+	if reading.PM10, err = parseFloat("pm10"); err != nil {
+		return sensorReading{}, fmt.Errorf("parse pm10: %w", err)
+	}
+
+	return reading, nil
+}
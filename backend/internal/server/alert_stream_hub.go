@@ -0,0 +1,130 @@
+package server
+
+import (
+	"log/slog"
+	"sync"
+)
+
+const alertStreamSubscriberBuffer = 32
+
+// alertStreamHistorySize bounds how many recent alert batches alertStreamHub
+// retains for replay when a client reconnects with Last-Event-ID/?since=.
+const alertStreamHistorySize = 64
+
+type alertStreamEvent struct {
+	ID     int64
+	Source string
+	Alerts []Alert
+}
+
+type alertStreamSubscriber struct {
+	events chan alertStreamEvent
+}
+
+// alertStreamHub fans newly produced Alerts out to /api/alerts/stream
+// subscribers, mirroring streamHub's replay-by-event-id semantics so
+// dashboards don't miss insights while reconnecting.
+type alertStreamHub struct {
+	mu          sync.RWMutex
+	subscribers map[*alertStreamSubscriber]struct{}
+	history     []alertStreamEvent
+	nextEventID int64
+	log         *slog.Logger
+}
+
+func newAlertStreamHub() *alertStreamHub {
+	return &alertStreamHub{
+		subscribers: make(map[*alertStreamSubscriber]struct{}),
+		log:         logger.With("component", "alert_stream"),
+	}
+}
+
+// subscribe registers a new subscriber, optionally replaying alert batches
+// newer than sinceEventID from the in-memory history.
+func (hub *alertStreamHub) subscribe(sinceEventID int64) (*alertStreamSubscriber, func()) {
+	subscriber := &alertStreamSubscriber{
+		events: make(chan alertStreamEvent, alertStreamSubscriberBuffer),
+	}
+
+	hub.mu.Lock()
+	hub.subscribers[subscriber] = struct{}{}
+	var replay []alertStreamEvent
+	if sinceEventID > 0 {
+		for _, event := range hub.history {
+			if event.ID > sinceEventID {
+				replay = append(replay, event)
+			}
+		}
+	}
+	subscriberCount := len(hub.subscribers)
+	hub.mu.Unlock()
+
+	hub.log.Debug("alert stream subscriber added", "subscribers", subscriberCount)
+
+	for _, event := range replay {
+		select {
+		case subscriber.events <- event:
+		default:
+		}
+	}
+
+	unsubscribe := func() {
+		hub.mu.Lock()
+		if _, exists := hub.subscribers[subscriber]; exists {
+			delete(hub.subscribers, subscriber)
+			close(subscriber.events)
+		}
+		subscriberCount := len(hub.subscribers)
+		hub.mu.Unlock()
+
+		hub.log.Debug("alert stream subscriber removed", "subscribers", subscriberCount)
+	}
+
+	return subscriber, unsubscribe
+}
+
+// publish fans a freshly produced batch of alerts out to every subscriber.
+// A subscriber whose buffer is full is evicted rather than allowed to block
+// the publishing InsightsScheduler.
+func (hub *alertStreamHub) publish(alerts []Alert, source string) {
+	if len(alerts) == 0 {
+		return
+	}
+
+	hub.mu.Lock()
+	hub.nextEventID++
+	event := alertStreamEvent{ID: hub.nextEventID, Source: source, Alerts: cloneAlerts(alerts)}
+	hub.history = append(hub.history, event)
+	if len(hub.history) > alertStreamHistorySize {
+		hub.history = append([]alertStreamEvent(nil), hub.history[len(hub.history)-alertStreamHistorySize:]...)
+	}
+
+	subscribers := make([]*alertStreamSubscriber, 0, len(hub.subscribers))
+	for subscriber := range hub.subscribers {
+		subscribers = append(subscribers, subscriber)
+	}
+	hub.mu.Unlock()
+
+	for _, subscriber := range subscribers {
+		select {
+		case subscriber.events <- event:
+		default:
+			hub.evict(subscriber)
+		}
+	}
+}
+
+func (hub *alertStreamHub) evict(subscriber *alertStreamSubscriber) {
+	hub.mu.Lock()
+	_, exists := hub.subscribers[subscriber]
+	if exists {
+		delete(hub.subscribers, subscriber)
+		close(subscriber.events)
+	}
+	subscriberCount := len(hub.subscribers)
+	hub.mu.Unlock()
+
+	if exists {
+		hub.log.Debug("alert stream subscriber evicted", "subscribers", subscriberCount)
+	}
+}
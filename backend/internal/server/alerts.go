@@ -1,13 +1,10 @@
 package server
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"math"
-	"net/http"
 	"strings"
 	"sync"
 	"time"
@@ -55,14 +52,17 @@ func (analyzer *cachedAlertAnalyzer) Analyze(
 	defer analyzer.mu.Unlock()
 
 	if now.Sub(analyzer.lastAt) < analyzer.ttl {
+		analyzerCacheHitsTotal.WithLabelValues(analyzer.next.Source()).Inc()
 		cached := cloneAlerts(analyzer.lastValue)
 		return cached, nil
 	}
 
 	alerts, err := analyzer.next.Analyze(ctx, readings)
 	if err != nil {
+		analyzerCallsTotal.WithLabelValues(analyzer.next.Source(), "failure").Inc()
 		return nil, err
 	}
+	analyzerCallsTotal.WithLabelValues(analyzer.next.Source(), "success").Inc()
 
 	analyzer.lastAt = now
 	analyzer.lastValue = cloneAlerts(alerts)
@@ -75,178 +75,16 @@ func cloneAlerts(alerts []Alert) []Alert {
 	return output
 }
 
-type openAIAlertAnalyzer struct {
-	httpClient *http.Client
-	baseURL    string
-	apiKey     string
-	model      string
-	maxAlerts  int
-}
-
-func NewOpenAIAlertAnalyzer(apiKey string, model string, baseURL string, maxAlerts int) AlertAnalyzer {
-	trimmedModel := strings.TrimSpace(model)
-	if trimmedModel == "" {
-		trimmedModel = "gpt-5-mini"
-	}
-
-	trimmedBaseURL := strings.TrimSpace(baseURL)
-	if trimmedBaseURL == "" {
-		trimmedBaseURL = "https://api.openai.com/v1"
-	}
-
+// clampMaxAlerts applies the [1, 20] bound every AlertAnalyzer constructor
+// enforces on its maxAlerts parameter.
+func clampMaxAlerts(maxAlerts int) int {
 	if maxAlerts < 1 {
-		maxAlerts = 4
+		return 4
 	}
 	if maxAlerts > 20 {
-		maxAlerts = 20
+		return 20
 	}
-
-	return &openAIAlertAnalyzer{
-		// Request deadline is controlled by the caller context timeout.
-		httpClient: &http.Client{},
-		baseURL:    strings.TrimRight(trimmedBaseURL, "/"),
-		apiKey:     strings.TrimSpace(apiKey),
-		model:      trimmedModel,
-		maxAlerts:  maxAlerts,
-	}
-}
-
-func (analyzer *openAIAlertAnalyzer) Source() string {
-	return "openai"
-}
-
-func (analyzer *openAIAlertAnalyzer) Analyze(
-	ctx context.Context,
-	readings []SensorReading,
-) ([]Alert, error) {
-	if len(readings) == 0 {
-		return []Alert{}, nil
-	}
-
-	payload, err := json.Marshal(buildAlertSummary(readings))
-	if err != nil {
-		return nil, fmt.Errorf("marshal summary: %w", err)
-	}
-
-	requestPayload := map[string]any{
-		"model": analyzer.model,
-		"input": []map[string]any{
-			{
-				"role": "system",
-				"content": []map[string]any{
-					{
-						"type": "input_text",
-						"text": systemPrompt(analyzer.maxAlerts),
-					},
-				},
-			},
-			{
-				"role": "user",
-				"content": []map[string]any{
-					{
-						"type": "input_text",
-						"text": "Analyze this telemetry summary and return insights only as JSON.\n" + string(payload),
-					},
-				},
-			},
-		},
-		"text": map[string]any{
-			"format": map[string]any{
-				"type":   "json_schema",
-				"name":   "enviro_alerts",
-				"strict": true,
-				"schema": alertSchema(analyzer.maxAlerts),
-			},
-		},
-	}
-
-	requestBody, err := json.Marshal(requestPayload)
-	if err != nil {
-		return nil, fmt.Errorf("marshal request: %w", err)
-	}
-
-	request, err := http.NewRequestWithContext(
-		ctx,
-		http.MethodPost,
-		analyzer.baseURL+"/responses",
-		bytes.NewReader(requestBody),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("build request: %w", err)
-	}
-	request.Header.Set("Authorization", "Bearer "+analyzer.apiKey)
-	request.Header.Set("Content-Type", "application/json")
-
-	response, err := analyzer.httpClient.Do(request)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
-	}
-	defer response.Body.Close()
-
-	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
-	if err != nil {
-		return nil, fmt.Errorf("read response: %w", err)
-	}
-
-	if response.StatusCode >= http.StatusMultipleChoices {
-		return nil, fmt.Errorf("openai status %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
-	}
-
-	var modelResponse struct {
-		OutputText string `json:"output_text"`
-		Output     []struct {
-			Content []struct {
-				Type string `json:"type"`
-				Text string `json:"text"`
-			} `json:"content"`
-		} `json:"output"`
-	}
-
-	if err = json.Unmarshal(body, &modelResponse); err != nil {
-		return nil, fmt.Errorf("decode response: %w", err)
-	}
-
-	text := strings.TrimSpace(modelResponse.OutputText)
-	if text == "" {
-		for _, output := range modelResponse.Output {
-			for _, content := range output.Content {
-				if content.Type == "output_text" || content.Type == "text" {
-					text = strings.TrimSpace(content.Text)
-					if text != "" {
-						break
-					}
-				}
-			}
-			if text != "" {
-				break
-			}
-		}
-	}
-
-	if text == "" {
-		return nil, fmt.Errorf("openai response did not include text output")
-	}
-
-	var envelope struct {
-		Alerts []Alert `json:"alerts"`
-	}
-
-	if err = json.Unmarshal([]byte(text), &envelope); err != nil {
-		extracted := extractJSONObject(text)
-		if extracted == "" {
-			return nil, fmt.Errorf("invalid alert payload: %w", err)
-		}
-		if retryErr := json.Unmarshal([]byte(extracted), &envelope); retryErr != nil {
-			return nil, fmt.Errorf("invalid alert payload: %w", retryErr)
-		}
-	}
-
-	alerts := normalizeAlerts(envelope.Alerts, analyzer.maxAlerts)
-	if len(alerts) == 0 {
-		return []Alert{fallbackStableAlert(readings)}, nil
-	}
-
-	return alerts, nil
+	return maxAlerts
 }
 
 func systemPrompt(maxAlerts int) string {
@@ -309,6 +147,29 @@ func extractJSONObject(input string) string {
 	return input[start : end+1]
 }
 
+// parseAlertsEnvelope decodes a provider's raw {"alerts": [...]} JSON text
+// into normalized Alerts, shared by every LLM-backed AlertAnalyzer so each
+// provider only has to produce the text and hand it off here. A model that
+// wraps its JSON in prose gets one recovery attempt via extractJSONObject
+// before the call is treated as a failure.
+func parseAlertsEnvelope(text string, maxAlerts int) ([]Alert, error) {
+	var envelope struct {
+		Alerts []Alert `json:"alerts"`
+	}
+
+	if err := json.Unmarshal([]byte(text), &envelope); err != nil {
+		extracted := extractJSONObject(text)
+		if extracted == "" {
+			return nil, fmt.Errorf("invalid alert payload: %w", err)
+		}
+		if retryErr := json.Unmarshal([]byte(extracted), &envelope); retryErr != nil {
+			return nil, fmt.Errorf("invalid alert payload: %w", retryErr)
+		}
+	}
+
+	return normalizeAlerts(envelope.Alerts, maxAlerts), nil
+}
+
 func normalizeAlerts(alerts []Alert, maxAlerts int) []Alert {
 	output := make([]Alert, 0, len(alerts))
 
@@ -346,6 +207,7 @@ func normalizeAlerts(alerts []Alert, maxAlerts int) []Alert {
 			Title:    trimToLength(title, 60),
 			Message:  trimToLength(message, 180),
 		})
+		alertsBySeverityTotal.WithLabelValues(severity).Inc()
 
 		if len(output) >= maxAlerts {
 			break
@@ -362,6 +224,281 @@ func trimToLength(input string, maxLength int) string {
 	return strings.TrimSpace(input[:maxLength])
 }
 
+// RuleBasedThresholds configures the humidity and temperature comfort
+// bands used by ruleBasedAlertAnalyzer. PM2.5/PM10 thresholds are not
+// configurable here since they follow the fixed US EPA AQI breakpoint
+// tables.
+type RuleBasedThresholds struct {
+	HumidityMoldPercent float64
+	HumidityDryPercent  float64
+	TempComfortLowC     float64
+	TempComfortHighC    float64
+}
+
+func DefaultRuleBasedThresholds() RuleBasedThresholds {
+	return RuleBasedThresholds{
+		HumidityMoldPercent: 60,
+		HumidityDryPercent:  30,
+		TempComfortLowC:     18,
+		TempComfortHighC:    26,
+	}
+}
+
+type ruleBasedAlertAnalyzer struct {
+	thresholds RuleBasedThresholds
+	maxAlerts  int
+}
+
+// NewRuleBasedAlertAnalyzer returns an AlertAnalyzer that runs entirely
+// offline, computing US EPA AQI categories for PM2.5/PM10 plus humidity
+// and temperature comfort bands. It can be used standalone (no API key
+// required) or as the baseline half of NewHybridAlertAnalyzer.
+func NewRuleBasedAlertAnalyzer(thresholds RuleBasedThresholds, maxAlerts int) AlertAnalyzer {
+	defaults := DefaultRuleBasedThresholds()
+	if thresholds.HumidityMoldPercent <= 0 {
+		thresholds.HumidityMoldPercent = defaults.HumidityMoldPercent
+	}
+	if thresholds.HumidityDryPercent <= 0 {
+		thresholds.HumidityDryPercent = defaults.HumidityDryPercent
+	}
+	if thresholds.TempComfortLowC <= 0 {
+		thresholds.TempComfortLowC = defaults.TempComfortLowC
+	}
+	if thresholds.TempComfortHighC <= 0 {
+		thresholds.TempComfortHighC = defaults.TempComfortHighC
+	}
+
+	if maxAlerts < 1 {
+		maxAlerts = 4
+	}
+	if maxAlerts > 20 {
+		maxAlerts = 20
+	}
+
+	return &ruleBasedAlertAnalyzer{thresholds: thresholds, maxAlerts: maxAlerts}
+}
+
+func (analyzer *ruleBasedAlertAnalyzer) Source() string {
+	return "rules"
+}
+
+func (analyzer *ruleBasedAlertAnalyzer) Analyze(
+	_ context.Context,
+	readings []SensorReading,
+) ([]Alert, error) {
+	if len(readings) == 0 {
+		return []Alert{}, nil
+	}
+
+	latest := readings[len(readings)-1]
+	alerts := []Alert{
+		aqiAlert("PM2.5", latest.PM2, pm25AQIBreakpoints),
+		aqiAlert("PM10", latest.PM10, pm10AQIBreakpoints),
+		humidityAlert(latest.Humidity, analyzer.thresholds),
+		temperatureAlert(latest.Temperature, analyzer.thresholds),
+	}
+
+	if len(alerts) > analyzer.maxAlerts {
+		alerts = alerts[:analyzer.maxAlerts]
+	}
+	return alerts, nil
+}
+
+// aqiBreakpoint is one row of an EPA AQI breakpoint table: pollutant
+// concentrations in [concLow, concHigh] map linearly onto AQI values in
+// [aqiLow, aqiHigh] within category.
+type aqiBreakpoint struct {
+	concLow, concHigh float64
+	aqiLow, aqiHigh   float64
+	category          string
+}
+
+// pm25AQIBreakpoints is the EPA AQI breakpoint table for PM2.5 (ug/m3,
+// 24-hour average), per-category thresholds documented at
+// https://www.airnow.gov/aqi/aqi-basics/.
+var pm25AQIBreakpoints = []aqiBreakpoint{
+	{concLow: 0.0, concHigh: 12.0, aqiLow: 0, aqiHigh: 50, category: "Good"},
+	{concLow: 12.1, concHigh: 35.4, aqiLow: 51, aqiHigh: 100, category: "Moderate"},
+	{concLow: 35.5, concHigh: 55.4, aqiLow: 101, aqiHigh: 150, category: "Unhealthy for Sensitive Groups"},
+	{concLow: 55.5, concHigh: 150.4, aqiLow: 151, aqiHigh: 200, category: "Unhealthy"},
+	{concLow: 150.5, concHigh: 250.4, aqiLow: 201, aqiHigh: 300, category: "Very Unhealthy"},
+	{concLow: 250.5, concHigh: 500.4, aqiLow: 301, aqiHigh: 500, category: "Hazardous"},
+}
+
+// pm10AQIBreakpoints is the EPA AQI breakpoint table for PM10 (ug/m3,
+// 24-hour average).
+var pm10AQIBreakpoints = []aqiBreakpoint{
+	{concLow: 0, concHigh: 54, aqiLow: 0, aqiHigh: 50, category: "Good"},
+	{concLow: 55, concHigh: 154, aqiLow: 51, aqiHigh: 100, category: "Moderate"},
+	{concLow: 155, concHigh: 254, aqiLow: 101, aqiHigh: 150, category: "Unhealthy for Sensitive Groups"},
+	{concLow: 255, concHigh: 354, aqiLow: 151, aqiHigh: 200, category: "Unhealthy"},
+	{concLow: 355, concHigh: 424, aqiLow: 201, aqiHigh: 300, category: "Very Unhealthy"},
+	{concLow: 425, concHigh: 604, aqiLow: 301, aqiHigh: 500, category: "Hazardous"},
+}
+
+// calculateAQI applies the standard EPA piecewise-linear formula
+// AQI = (I_hi - I_lo)/(C_hi - C_lo) * (C - C_lo) + I_lo to concentration
+// against breakpoints, clamping to the table's top category above its
+// highest breakpoint.
+func calculateAQI(concentration float64, breakpoints []aqiBreakpoint) (aqi float64, category string) {
+	if concentration < 0 {
+		concentration = 0
+	}
+
+	for index, bp := range breakpoints {
+		if concentration <= bp.concHigh || index == len(breakpoints)-1 {
+			aqi = (bp.aqiHigh-bp.aqiLow)/(bp.concHigh-bp.concLow)*(concentration-bp.concLow) + bp.aqiLow
+			return math.Round(aqi), bp.category
+		}
+	}
+
+	return 0, "Good"
+}
+
+// aqiSeverity maps an EPA AQI category to the same critical/warn/info
+// severities the OpenAI analyzer emits.
+func aqiSeverity(category string) string {
+	switch category {
+	case "Good", "Moderate":
+		return "info"
+	case "Unhealthy for Sensitive Groups":
+		return "warn"
+	default:
+		return "critical"
+	}
+}
+
+func aqiAlert(label string, concentration float64, breakpoints []aqiBreakpoint) Alert {
+	aqi, category := calculateAQI(concentration, breakpoints)
+	severity := aqiSeverity(category)
+	kind := "insight"
+	if severity != "info" {
+		kind = "alert"
+	}
+
+	return Alert{
+		Kind:     kind,
+		Severity: severity,
+		Title:    fmt.Sprintf("%s AQI: %s", label, category),
+		Message:  fmt.Sprintf("%s is %.1f ug/m3 (AQI %.0f, %s).", label, concentration, aqi, category),
+	}
+}
+
+func humidityAlert(humidity float64, thresholds RuleBasedThresholds) Alert {
+	switch {
+	case humidity >= thresholds.HumidityMoldPercent:
+		return Alert{
+			Kind:     "alert",
+			Severity: "warn",
+			Title:    "High humidity, mold risk",
+			Message: fmt.Sprintf(
+				"Humidity is %.0f%%, at or above the %.0f%% mold-risk threshold.",
+				humidity, thresholds.HumidityMoldPercent,
+			),
+		}
+	case humidity <= thresholds.HumidityDryPercent:
+		return Alert{
+			Kind:     "alert",
+			Severity: "warn",
+			Title:    "Low humidity",
+			Message: fmt.Sprintf(
+				"Humidity is %.0f%%, at or below the %.0f%% dry-air threshold.",
+				humidity, thresholds.HumidityDryPercent,
+			),
+		}
+	default:
+		return Alert{
+			Kind:     "insight",
+			Severity: "info",
+			Title:    "Humidity comfortable",
+			Message:  fmt.Sprintf("Humidity is %.0f%%, within the comfortable range.", humidity),
+		}
+	}
+}
+
+func temperatureAlert(temperature float64, thresholds RuleBasedThresholds) Alert {
+	switch {
+	case temperature > thresholds.TempComfortHighC:
+		return Alert{
+			Kind:     "insight",
+			Severity: "info",
+			Title:    "Temperature above comfort band",
+			Message: fmt.Sprintf(
+				"Temperature is %.1fC, above the %.1fC comfort band.",
+				temperature, thresholds.TempComfortHighC,
+			),
+		}
+	case temperature < thresholds.TempComfortLowC:
+		return Alert{
+			Kind:     "insight",
+			Severity: "info",
+			Title:    "Temperature below comfort band",
+			Message: fmt.Sprintf(
+				"Temperature is %.1fC, below the %.1fC comfort band.",
+				temperature, thresholds.TempComfortLowC,
+			),
+		}
+	default:
+		return Alert{
+			Kind:     "insight",
+			Severity: "info",
+			Title:    "Temperature comfortable",
+			Message:  fmt.Sprintf("Temperature is %.1fC, within the comfortable range.", temperature),
+		}
+	}
+}
+
+// hybridAlertAnalyzer runs the rule-based analyzer first for a
+// deterministic baseline, then appends whatever the OpenAI analyzer
+// returns. If OpenAI enrichment fails, the baseline is still returned so
+// a transient API outage doesn't blank out /api/insights.
+type hybridAlertAnalyzer struct {
+	rules     AlertAnalyzer
+	enrich    AlertAnalyzer
+	maxAlerts int
+}
+
+// NewHybridAlertAnalyzer combines a rule-based baseline with enrichment
+// from another analyzer (typically OpenAI), capping the combined result
+// at maxAlerts.
+func NewHybridAlertAnalyzer(rules AlertAnalyzer, enrich AlertAnalyzer, maxAlerts int) AlertAnalyzer {
+	if maxAlerts < 1 {
+		maxAlerts = 4
+	}
+	return &hybridAlertAnalyzer{rules: rules, enrich: enrich, maxAlerts: maxAlerts}
+}
+
+func (analyzer *hybridAlertAnalyzer) Source() string {
+	return "hybrid"
+}
+
+func (analyzer *hybridAlertAnalyzer) Analyze(
+	ctx context.Context,
+	readings []SensorReading,
+) ([]Alert, error) {
+	baseline, err := analyzer.rules.Analyze(ctx, readings)
+	if err != nil {
+		return nil, err
+	}
+
+	enrichment, err := analyzer.enrich.Analyze(ctx, readings)
+	if err != nil {
+		logger.Warn("hybrid analyzer enrichment failed, using rule-based baseline only",
+			"component", "alerts", "error", err)
+		return trimAlerts(baseline, analyzer.maxAlerts), nil
+	}
+
+	combined := append(append([]Alert{}, baseline...), enrichment...)
+	return trimAlerts(combined, analyzer.maxAlerts), nil
+}
+
+func trimAlerts(alerts []Alert, maxAlerts int) []Alert {
+	if len(alerts) > maxAlerts {
+		return alerts[:maxAlerts]
+	}
+	return alerts
+}
+
 func fallbackStableAlert(readings []SensorReading) Alert {
 	summary := buildAlertSummary(readings)
 	message := fmt.Sprintf(
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"strings"
 	"testing"
 )
@@ -61,3 +62,82 @@ func TestFallbackStableAlertProducesInsight(t *testing.T) {
 		t.Fatalf("expected non-empty message")
 	}
 }
+
+func TestCalculateAQIMatchesKnownEPABreakpoints(t *testing.T) {
+	aqi, category := calculateAQI(35.4, pm25AQIBreakpoints)
+	if category != "Moderate" {
+		t.Fatalf("expected Moderate category at PM2.5=35.4, got %q", category)
+	}
+	if aqi != 100 {
+		t.Fatalf("expected AQI=100 at the top of the Moderate band, got %v", aqi)
+	}
+
+	aqi, category = calculateAQI(500, pm10AQIBreakpoints)
+	if category != "Hazardous" {
+		t.Fatalf("expected Hazardous category above the top PM10 breakpoint, got %q", category)
+	}
+	if aqi <= 300 {
+		t.Fatalf("expected AQI above 300 in the Hazardous band, got %v", aqi)
+	}
+}
+
+func TestRuleBasedAlertAnalyzerFlagsHighHumidity(t *testing.T) {
+	analyzer := NewRuleBasedAlertAnalyzer(DefaultRuleBasedThresholds(), 4)
+
+	alerts, err := analyzer.Analyze(context.Background(), []SensorReading{
+		{Timestamp: 1738886400000, Temperature: 22.0, Humidity: 72.0, PM2: 4.0, PM10: 6.0},
+	})
+	if err != nil {
+		t.Fatalf("analyze returned error: %v", err)
+	}
+
+	var found bool
+	for _, alert := range alerts {
+		if alert.Title == "High humidity, mold risk" {
+			found = true
+			if alert.Severity != "warn" {
+				t.Fatalf("expected warn severity for high humidity, got %q", alert.Severity)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("expected a high-humidity alert, got %+v", alerts)
+	}
+}
+
+func TestRuleBasedAlertAnalyzerHonorsMaxAlerts(t *testing.T) {
+	analyzer := NewRuleBasedAlertAnalyzer(DefaultRuleBasedThresholds(), 2)
+
+	alerts, err := analyzer.Analyze(context.Background(), []SensorReading{
+		{Timestamp: 1738886400000, Temperature: 22.0, Humidity: 45.0, PM2: 4.0, PM10: 6.0},
+	})
+	if err != nil {
+		t.Fatalf("analyze returned error: %v", err)
+	}
+	if len(alerts) != 2 {
+		t.Fatalf("expected exactly 2 alerts, got %d", len(alerts))
+	}
+}
+
+func TestParseAlertsEnvelopeRecoversJSONWrappedInProse(t *testing.T) {
+	text := "Sure, here are the insights:\n" +
+		`{"alerts":[{"kind":"insight","severity":"info","title":"Stable","message":"Air quality looks fine today."}]}` +
+		"\nLet me know if you need anything else."
+
+	alerts, err := parseAlertsEnvelope(text, 4)
+	if err != nil {
+		t.Fatalf("parseAlertsEnvelope returned error: %v", err)
+	}
+	if len(alerts) != 1 {
+		t.Fatalf("expected 1 alert, got %d", len(alerts))
+	}
+	if alerts[0].Title != "Stable" {
+		t.Fatalf("expected title %q, got %q", "Stable", alerts[0].Title)
+	}
+}
+
+func TestParseAlertsEnvelopeRejectsUnrecoverableText(t *testing.T) {
+	if _, err := parseAlertsEnvelope("not json at all", 4); err == nil {
+		t.Fatal("expected an error for text with no JSON object")
+	}
+}
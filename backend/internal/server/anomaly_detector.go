@@ -0,0 +1,188 @@
+package server
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// AnomalyDetectorConfig tunes the EWMA + robust z-score anomaly detector
+// InsightsScheduler uses, alongside the existing threshold/delta rules, to
+// decide whether a reading is statistically surprising enough to trigger a
+// recompute.
+type AnomalyDetectorConfig struct {
+	// Alpha is the EWMA/EW-variance smoothing factor: mean_t = alpha*x_t +
+	// (1-alpha)*mean_{t-1}.
+	Alpha float64
+	// ZScoreThreshold (K) is the robust z-score a metric must exceed to
+	// count as anomalous.
+	ZScoreThreshold float64
+	// ResidualWindow bounds how many recent residuals are kept to compute
+	// the median absolute deviation (MAD).
+	ResidualWindow int
+	// WarmupSamples suppresses triggers for a metric's first N
+	// observations, before the running statistics are meaningful.
+	WarmupSamples int
+}
+
+func DefaultAnomalyDetectorConfig() AnomalyDetectorConfig {
+	return AnomalyDetectorConfig{
+		Alpha:           0.1,
+		ZScoreThreshold: 3.5,
+		ResidualWindow:  50,
+		WarmupSamples:   20,
+	}
+}
+
+// MetricAnomalyStats is a read-only snapshot of one metric's anomaly
+// detector state, exposed on a diagnostic endpoint so operators can see
+// what the running mean/MAD/z-score look like while tuning thresholds.
+type MetricAnomalyStats struct {
+	Mean       float64 `json:"mean"`
+	Variance   float64 `json:"variance"`
+	MAD        float64 `json:"mad"`
+	LastValue  float64 `json:"last_value"`
+	LastZScore float64 `json:"last_z_score"`
+	Samples    int     `json:"samples"`
+	WarmedUp   bool    `json:"warmed_up"`
+}
+
+// metricAnomalyState is the running EWMA/EW-variance and residual window
+// for a single metric (e.g. "pm2").
+type metricAnomalyState struct {
+	mean       float64
+	variance   float64
+	residuals  []float64
+	samples    int
+	lastValue  float64
+	lastZScore float64
+}
+
+// anomalyDetector maintains one metricAnomalyState per monitored metric.
+// It's safe for concurrent use.
+type anomalyDetector struct {
+	mu     sync.Mutex
+	config AnomalyDetectorConfig
+	states map[string]*metricAnomalyState
+}
+
+func newAnomalyDetector(config AnomalyDetectorConfig) *anomalyDetector {
+	return &anomalyDetector{
+		config: config,
+		states: make(map[string]*metricAnomalyState),
+	}
+}
+
+// updateConfig atomically swaps the detector's tuning parameters, leaving
+// any already-accumulated per-metric state untouched.
+func (detector *anomalyDetector) updateConfig(config AnomalyDetectorConfig) {
+	detector.mu.Lock()
+	defer detector.mu.Unlock()
+	detector.config = config
+}
+
+// observe feeds a fresh value for metric through the EWMA/MAD model,
+// updates the running statistics, and reports whether the resulting
+// robust z-score |x_t - mean_t| / (1.4826*MAD) exceeds config.ZScoreThreshold.
+// NaN, infinite, and negative values are treated as sensor glitches and
+// never trigger or update the running state. positiveOnly mirrors the
+// existing "increase only" PM rule: a drop in value never counts as
+// anomalous.
+func (detector *anomalyDetector) observe(metric string, value float64, positiveOnly bool) bool {
+	if math.IsNaN(value) || math.IsInf(value, 0) || value < 0 {
+		return false
+	}
+
+	detector.mu.Lock()
+	defer detector.mu.Unlock()
+
+	state, ok := detector.states[metric]
+	if !ok {
+		state = &metricAnomalyState{mean: value}
+		detector.states[metric] = state
+	}
+
+	alpha := detector.config.Alpha
+	previousMean := state.mean
+	residual := value - previousMean
+
+	state.mean = alpha*value + (1-alpha)*previousMean
+	state.variance = alpha*residual*residual + (1-alpha)*state.variance
+	state.samples++
+	state.lastValue = value
+
+	state.residuals = append(state.residuals, residual)
+	if len(state.residuals) > detector.config.ResidualWindow {
+		state.residuals = state.residuals[len(state.residuals)-detector.config.ResidualWindow:]
+	}
+
+	mad := medianAbsoluteDeviation(state.residuals)
+	if mad == 0 {
+		state.lastZScore = 0
+		return false
+	}
+	state.lastZScore = math.Abs(value-state.mean) / (1.4826 * mad)
+
+	if state.samples <= detector.config.WarmupSamples {
+		return false
+	}
+	if state.lastZScore < detector.config.ZScoreThreshold {
+		return false
+	}
+	if positiveOnly && residual < 0 {
+		return false
+	}
+
+	return true
+}
+
+// stats returns a snapshot of every metric the detector has observed, for
+// a diagnostic endpoint.
+func (detector *anomalyDetector) stats() map[string]MetricAnomalyStats {
+	detector.mu.Lock()
+	defer detector.mu.Unlock()
+
+	out := make(map[string]MetricAnomalyStats, len(detector.states))
+	for metric, state := range detector.states {
+		out[metric] = MetricAnomalyStats{
+			Mean:       state.mean,
+			Variance:   state.variance,
+			MAD:        medianAbsoluteDeviation(state.residuals),
+			LastValue:  state.lastValue,
+			LastZScore: state.lastZScore,
+			Samples:    state.samples,
+			WarmedUp:   state.samples > detector.config.WarmupSamples,
+		}
+	}
+	return out
+}
+
+// medianAbsoluteDeviation computes the median absolute deviation of
+// residuals: median(|residual_i - median(residuals)|).
+func medianAbsoluteDeviation(residuals []float64) float64 {
+	if len(residuals) == 0 {
+		return 0
+	}
+
+	center := median(residuals)
+
+	deviations := make([]float64, len(residuals))
+	for i, residual := range residuals {
+		deviations[i] = math.Abs(residual - center)
+	}
+	return median(deviations)
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	n := len(sorted)
+	if n == 0 {
+		return 0
+	}
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAnomalyDetectorSuppressesTriggersDuringWarmup(t *testing.T) {
+	config := DefaultAnomalyDetectorConfig()
+	config.WarmupSamples = 5
+	detector := newAnomalyDetector(config)
+
+	for i := 0; i < 5; i++ {
+		if detector.observe("pm2", 10, true) {
+			t.Fatalf("expected warmup sample %d not to trigger", i)
+		}
+	}
+}
+
+// baselineWithNoise feeds a detector small oscillations around center so
+// the rolling residual window has a non-zero MAD, the way a real sensor's
+// natural jitter would, rather than the degenerate all-zero-residual case.
+func baselineWithNoise(detector *anomalyDetector, metric string, center float64, positiveOnly bool, samples int) {
+	for i := 0; i < samples; i++ {
+		offset := 0.2
+		if i%2 == 0 {
+			offset = -0.2
+		}
+		detector.observe(metric, center+offset, positiveOnly)
+	}
+}
+
+func TestAnomalyDetectorTriggersOnLargePositiveSpike(t *testing.T) {
+	config := DefaultAnomalyDetectorConfig()
+	config.WarmupSamples = 5
+	detector := newAnomalyDetector(config)
+
+	baselineWithNoise(detector, "pm2", 10, true, 30)
+
+	if !detector.observe("pm2", 200, true) {
+		t.Fatal("expected a large positive spike to trigger after warmup")
+	}
+}
+
+func TestAnomalyDetectorPositiveOnlyIgnoresDrop(t *testing.T) {
+	config := DefaultAnomalyDetectorConfig()
+	config.WarmupSamples = 5
+	detector := newAnomalyDetector(config)
+
+	baselineWithNoise(detector, "pm2", 10, true, 30)
+
+	if detector.observe("pm2", -0.0001, true) {
+		t.Fatal("expected negative values to be ignored as a sensor glitch")
+	}
+	if detector.observe("pm2", 0.01, true) {
+		t.Fatal("expected a large drop not to trigger a positive-only metric")
+	}
+}
+
+func TestAnomalyDetectorIgnoresNaNAndNegativeValues(t *testing.T) {
+	detector := newAnomalyDetector(DefaultAnomalyDetectorConfig())
+
+	if detector.observe("pm2", math.NaN(), true) {
+		t.Fatal("expected NaN to never trigger")
+	}
+	if detector.observe("pm2", -5, true) {
+		t.Fatal("expected a negative reading to never trigger")
+	}
+
+	stats := detector.stats()
+	if _, tracked := stats["pm2"]; tracked {
+		t.Fatal("expected glitch values not to establish tracked state")
+	}
+}
+
+func TestMedianAbsoluteDeviationOfConstantResidualsIsZero(t *testing.T) {
+	if mad := medianAbsoluteDeviation([]float64{2, 2, 2, 2}); mad != 0 {
+		t.Fatalf("expected MAD of constant residuals to be 0, got %g", mad)
+	}
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const anthropicAPIVersion = "2023-06-01"
+
+// anthropicAlertAnalyzer calls Anthropic's Messages API, forcing the
+// alerts schema via tool use so the reply's tool_use input is already
+// shaped like alertSchema rather than free-form prose.
+type anthropicAlertAnalyzer struct {
+	httpClient *http.Client
+	baseURL    string
+	apiKey     string
+	model      string
+	maxAlerts  int
+}
+
+// NewAnthropicAlertAnalyzer returns an AlertAnalyzer backed by Anthropic's
+// Messages API. baseURL defaults to the public Anthropic endpoint.
+func NewAnthropicAlertAnalyzer(apiKey string, model string, baseURL string, maxAlerts int) AlertAnalyzer {
+	trimmedModel := strings.TrimSpace(model)
+	if trimmedModel == "" {
+		trimmedModel = "claude-3-5-haiku-latest"
+	}
+
+	trimmedBaseURL := strings.TrimSpace(baseURL)
+	if trimmedBaseURL == "" {
+		trimmedBaseURL = "https://api.anthropic.com/v1"
+	}
+
+	return &anthropicAlertAnalyzer{
+		// Request deadline is controlled by the caller context timeout.
+		httpClient: &http.Client{},
+		baseURL:    strings.TrimRight(trimmedBaseURL, "/"),
+		apiKey:     strings.TrimSpace(apiKey),
+		model:      trimmedModel,
+		maxAlerts:  clampMaxAlerts(maxAlerts),
+	}
+}
+
+func (analyzer *anthropicAlertAnalyzer) Source() string {
+	return "anthropic"
+}
+
+func (analyzer *anthropicAlertAnalyzer) Analyze(ctx context.Context, readings []SensorReading) ([]Alert, error) {
+	if len(readings) == 0 {
+		return []Alert{}, nil
+	}
+
+	payload, err := json.Marshal(buildAlertSummary(readings))
+	if err != nil {
+		return nil, fmt.Errorf("marshal summary: %w", err)
+	}
+
+	const toolName = "emit_alerts"
+	requestPayload := map[string]any{
+		"model":      analyzer.model,
+		"max_tokens": 1024,
+		"system":     systemPrompt(analyzer.maxAlerts),
+		"messages": []map[string]any{
+			{
+				"role":    "user",
+				"content": "Analyze this telemetry summary and call emit_alerts with your insights.\n" + string(payload),
+			},
+		},
+		"tools": []map[string]any{
+			{
+				"name":         toolName,
+				"description":  "Record the insights produced for this telemetry summary.",
+				"input_schema": alertSchema(analyzer.maxAlerts),
+			},
+		},
+		"tool_choice": map[string]any{"type": "tool", "name": toolName},
+	}
+
+	requestBody, err := json.Marshal(requestPayload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		analyzer.baseURL+"/messages",
+		bytes.NewReader(requestBody),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	request.Header.Set("x-api-key", analyzer.apiKey)
+	request.Header.Set("anthropic-version", anthropicAPIVersion)
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := analyzer.httpClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	if response.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("anthropic status %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var modelResponse struct {
+		Content []struct {
+			Type  string          `json:"type"`
+			Name  string          `json:"name"`
+			Input json.RawMessage `json:"input"`
+		} `json:"content"`
+	}
+	if err := json.Unmarshal(body, &modelResponse); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	for _, block := range modelResponse.Content {
+		if block.Type != "tool_use" || block.Name != toolName {
+			continue
+		}
+
+		alerts, err := parseAlertsEnvelope(string(block.Input), analyzer.maxAlerts)
+		if err != nil {
+			return nil, err
+		}
+		if len(alerts) == 0 {
+			return []Alert{fallbackStableAlert(readings)}, nil
+		}
+		return alerts, nil
+	}
+
+	return nil, fmt.Errorf("anthropic response did not include an emit_alerts tool call")
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	scopeIngest = "ingest"
+	scopeRead   = "read"
+)
+
+type jwtClaims struct {
+	Scope string `json:"scope"`
+	Exp   int64  `json:"exp"`
+	Nbf   int64  `json:"nbf"`
+}
+
+func (claims jwtClaims) scopes() map[string]struct{} {
+	scopes := make(map[string]struct{})
+	for _, scope := range strings.Fields(claims.Scope) {
+		scopes[scope] = struct{}{}
+	}
+	return scopes
+}
+
+// verifyJWT validates a compact JWT's ed25519 signature along with its
+// exp/nbf claims. Only the EdDSA algorithm is supported; anything else is
+// rejected rather than silently accepted.
+func verifyJWT(token string, publicKey ed25519.PublicKey) (jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return jwtClaims{}, fmt.Errorf("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return jwtClaims{}, fmt.Errorf("invalid header: %w", err)
+	}
+	if err = json.Unmarshal(headerBytes, &header); err != nil {
+		return jwtClaims{}, fmt.Errorf("invalid header: %w", err)
+	}
+	if header.Alg != "EdDSA" {
+		return jwtClaims{}, fmt.Errorf("unsupported algorithm: %s", header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return jwtClaims{}, fmt.Errorf("invalid signature encoding: %w", err)
+	}
+
+	signingInput := parts[0] + "." + parts[1]
+	if !ed25519.Verify(publicKey, []byte(signingInput), signature) {
+		return jwtClaims{}, fmt.Errorf("invalid signature")
+	}
+
+	payloadBytes, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return jwtClaims{}, fmt.Errorf("invalid payload: %w", err)
+	}
+
+	var claims jwtClaims
+	if err = json.Unmarshal(payloadBytes, &claims); err != nil {
+		return jwtClaims{}, fmt.Errorf("invalid claims: %w", err)
+	}
+
+	now := time.Now().Unix()
+	if claims.Exp != 0 && now >= claims.Exp {
+		return jwtClaims{}, fmt.Errorf("token expired")
+	}
+	if claims.Nbf != 0 && now < claims.Nbf {
+		return jwtClaims{}, fmt.Errorf("token not yet valid")
+	}
+
+	return claims, nil
+}
+
+// authorizeBearerToken returns true when the request carries a bearer JWT
+// that verifies against api.jwtPublicKey and grants at least one of
+// requiredScopes (or any valid token when requiredScopes is empty).
+func (api *API) authorizeBearerToken(request *http.Request, requiredScopes ...string) bool {
+	if api.jwtPublicKey == nil {
+		return false
+	}
+
+	const bearerPrefix = "Bearer "
+	authHeader := strings.TrimSpace(request.Header.Get("Authorization"))
+	if !strings.HasPrefix(authHeader, bearerPrefix) {
+		return false
+	}
+
+	claims, err := verifyJWT(strings.TrimSpace(strings.TrimPrefix(authHeader, bearerPrefix)), api.jwtPublicKey)
+	if err != nil {
+		return false
+	}
+
+	if len(requiredScopes) == 0 {
+		return true
+	}
+
+	grantedScopes := claims.scopes()
+	for _, required := range requiredScopes {
+		if _, granted := grantedScopes[required]; granted {
+			return true
+		}
+	}
+	return false
+}
+
+// authorizeReadRequest gates the read-only endpoints. It is a no-op
+// (always authorized) unless WithAuthRequired was used to opt the
+// deployment into bearer-token enforcement.
+func (api *API) authorizeReadRequest(response http.ResponseWriter, request *http.Request) bool {
+	if !api.readAuthEnabled {
+		return true
+	}
+
+	if api.authorizeBearerToken(request, api.readScopes...) {
+		return true
+	}
+
+	writeError(response, http.StatusUnauthorized, "unauthorized")
+	return false
+}
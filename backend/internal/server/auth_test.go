@@ -0,0 +1,142 @@
+package server
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func signTestJWT(t *testing.T, privateKey ed25519.PrivateKey, claims jwtClaims) string {
+	t.Helper()
+
+	header, err := json.Marshal(map[string]string{"alg": "EdDSA", "typ": "JWT"})
+	if err != nil {
+		t.Fatalf("marshal header: %v", err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("marshal claims: %v", err)
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature := ed25519.Sign(privateKey, []byte(signingInput))
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestAuthorizeIngestRequestAcceptsValidIngestScopeToken(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	store := &fakeStore{}
+	api := NewAPI(store, "", WithJWTPublicKey(publicKey))
+	handler := api.Handler()
+
+	token := signTestJWT(t, privateKey, jwtClaims{Scope: scopeIngest, Exp: time.Now().Add(time.Hour).Unix()})
+
+	request := httptest.NewRequest(http.MethodPost, "/api/ingest", jsonReadingBody())
+	request.Header.Set("Authorization", "Bearer "+token)
+	response := httptest.NewRecorder()
+
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, response.Code, response.Body.String())
+	}
+}
+
+func TestAuthorizeIngestRequestRejectsWrongScope(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	store := &fakeStore{}
+	api := NewAPI(store, "", WithJWTPublicKey(publicKey))
+	handler := api.Handler()
+
+	token := signTestJWT(t, privateKey, jwtClaims{Scope: scopeRead, Exp: time.Now().Add(time.Hour).Unix()})
+
+	request := httptest.NewRequest(http.MethodPost, "/api/ingest", jsonReadingBody())
+	request.Header.Set("Authorization", "Bearer "+token)
+	response := httptest.NewRecorder()
+
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, response.Code)
+	}
+}
+
+func TestAuthorizeIngestRequestRejectsExpiredToken(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	store := &fakeStore{}
+	api := NewAPI(store, "", WithJWTPublicKey(publicKey))
+	handler := api.Handler()
+
+	token := signTestJWT(t, privateKey, jwtClaims{Scope: scopeIngest, Exp: time.Now().Add(-time.Minute).Unix()})
+
+	request := httptest.NewRequest(http.MethodPost, "/api/ingest", jsonReadingBody())
+	request.Header.Set("Authorization", "Bearer "+token)
+	response := httptest.NewRecorder()
+
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, response.Code)
+	}
+}
+
+func TestAuthorizeReadRequestRequiresScopeWhenEnabled(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	store := &fakeStore{}
+	api := NewAPI(store, "secret", WithJWTPublicKey(publicKey), WithAuthRequired(scopeRead))
+	handler := api.Handler()
+
+	unauthorized := httptest.NewRequest(http.MethodGet, "/api/readings", nil)
+	unauthorizedResponse := httptest.NewRecorder()
+	handler.ServeHTTP(unauthorizedResponse, unauthorized)
+	if unauthorizedResponse.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, unauthorizedResponse.Code)
+	}
+
+	token := signTestJWT(t, privateKey, jwtClaims{Scope: scopeRead, Exp: time.Now().Add(time.Hour).Unix()})
+	authorized := httptest.NewRequest(http.MethodGet, "/api/readings", nil)
+	authorized.Header.Set("Authorization", "Bearer "+token)
+	authorizedResponse := httptest.NewRecorder()
+	handler.ServeHTTP(authorizedResponse, authorized)
+	if authorizedResponse.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, authorizedResponse.Code)
+	}
+}
+
+func jsonReadingBody() io.Reader {
+	return bytes.NewBufferString(`{
+		"timestamp":"1738886400",
+		"temperature":"22.4",
+		"pressure":"101305",
+		"humidity":"40.1",
+		"oxidised":"1.2",
+		"reduced":"1.1",
+		"nh3":"0.7",
+		"pm1":"2",
+		"pm2":"3",
+		"pm10":"4"
+	}`)
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// azureOpenAIAlertAnalyzer calls an Azure OpenAI resource's chat
+// completions endpoint, which routes by deployment name rather than model
+// name and requires an api-version query parameter.
+type azureOpenAIAlertAnalyzer struct {
+	httpClient *http.Client
+	endpoint   string
+	apiKey     string
+	deployment string
+	apiVersion string
+	maxAlerts  int
+}
+
+// NewAzureOpenAIAlertAnalyzer returns an AlertAnalyzer backed by an Azure
+// OpenAI resource. endpoint is the resource's base URL (e.g.
+// https://my-resource.openai.azure.com), deployment is the deployed
+// model's deployment name, and apiVersion defaults to a recent stable
+// Azure OpenAI API version if left blank.
+func NewAzureOpenAIAlertAnalyzer(apiKey string, endpoint string, deployment string, apiVersion string, maxAlerts int) AlertAnalyzer {
+	trimmedDeployment := strings.TrimSpace(deployment)
+	if trimmedDeployment == "" {
+		trimmedDeployment = "gpt-5-mini"
+	}
+
+	trimmedAPIVersion := strings.TrimSpace(apiVersion)
+	if trimmedAPIVersion == "" {
+		trimmedAPIVersion = "2024-08-01-preview"
+	}
+
+	return &azureOpenAIAlertAnalyzer{
+		// Request deadline is controlled by the caller context timeout.
+		httpClient: &http.Client{},
+		endpoint:   strings.TrimRight(strings.TrimSpace(endpoint), "/"),
+		apiKey:     strings.TrimSpace(apiKey),
+		deployment: trimmedDeployment,
+		apiVersion: trimmedAPIVersion,
+		maxAlerts:  clampMaxAlerts(maxAlerts),
+	}
+}
+
+func (analyzer *azureOpenAIAlertAnalyzer) Source() string {
+	return "azure_openai"
+}
+
+func (analyzer *azureOpenAIAlertAnalyzer) Analyze(ctx context.Context, readings []SensorReading) ([]Alert, error) {
+	if len(readings) == 0 {
+		return []Alert{}, nil
+	}
+
+	payload, err := json.Marshal(buildAlertSummary(readings))
+	if err != nil {
+		return nil, fmt.Errorf("marshal summary: %w", err)
+	}
+
+	requestPayload := map[string]any{
+		"messages": []map[string]any{
+			{"role": "system", "content": systemPrompt(analyzer.maxAlerts)},
+			{"role": "user", "content": "Analyze this telemetry summary and return insights only as JSON.\n" + string(payload)},
+		},
+		"response_format": map[string]any{
+			"type": "json_schema",
+			"json_schema": map[string]any{
+				"name":   "enviro_alerts",
+				"strict": true,
+				"schema": alertSchema(analyzer.maxAlerts),
+			},
+		},
+	}
+
+	requestBody, err := json.Marshal(requestPayload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	requestURL := fmt.Sprintf(
+		"%s/openai/deployments/%s/chat/completions?api-version=%s",
+		analyzer.endpoint,
+		url.PathEscape(analyzer.deployment),
+		url.QueryEscape(analyzer.apiVersion),
+	)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, requestURL, bytes.NewReader(requestBody))
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	request.Header.Set("api-key", analyzer.apiKey)
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := analyzer.httpClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	if response.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("azure openai status %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var modelResponse struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(body, &modelResponse); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	if len(modelResponse.Choices) == 0 {
+		return nil, fmt.Errorf("azure openai response did not include any choices")
+	}
+
+	text := strings.TrimSpace(modelResponse.Choices[0].Message.Content)
+	if text == "" {
+		return nil, fmt.Errorf("azure openai response did not include message content")
+	}
+
+	alerts, err := parseAlertsEnvelope(text, analyzer.maxAlerts)
+	if err != nil {
+		return nil, err
+	}
+	if len(alerts) == 0 {
+		return []Alert{fallbackStableAlert(readings)}, nil
+	}
+
+	return alerts, nil
+}
@@ -0,0 +1,68 @@
+package server
+
+import "fmt"
+
+// Config is the subset of operator-tunable settings that can be
+// hot-reloaded via API.Reload without restarting the process. main()
+// rebuilds it from the environment (and a re-read .env) on SIGHUP;
+// everything else — TLS certs, JWT keys, which analyzer backend is
+// active, store wiring — still requires a restart.
+type Config struct {
+	// IngestAPIKey and DatabaseURL are only compared, never applied: a
+	// reload that tries to change either is rejected, since swapping the
+	// ingest credential or tearing down the Store out from under active
+	// connections can't be done safely without a restart.
+	IngestAPIKey string
+	DatabaseURL  string
+
+	Insights InsightsSchedulerConfig
+
+	// SkipInsightsReload leaves the scheduler's insights thresholds alone
+	// even though Insights is populated above. Set this when
+	// INSIGHTS_CONFIG_FILE's fsnotify watcher (WatchInsightsConfigFile) is
+	// already the source of truth for those thresholds, so an unrelated
+	// SIGHUP (e.g. rotating CORS_ALLOW_ORIGIN) doesn't stomp live tuning
+	// applied through the watched file with stale env/file defaults.
+	SkipInsightsReload bool
+
+	// OpenAIModel, if non-empty, replaces the model used by an
+	// AlertAnalyzer that implements modelReloadable (currently
+	// openAIAlertAnalyzer). Empty leaves the current model unchanged.
+	OpenAIModel string
+}
+
+// modelReloadable is an optional AlertAnalyzer capability for backends
+// whose model name can be swapped at runtime, used by Reload.
+type modelReloadable interface {
+	SetModel(model string)
+}
+
+// Reload applies cfg without dropping connections or restarting
+// background work, meant to be called from a SIGHUP handler in main after
+// .env and the environment have been re-read. It rejects the entire
+// reload (and logs nothing itself — the caller logs the returned error)
+// if IngestAPIKey or DatabaseURL changed, since neither can be hot-swapped
+// safely.
+func (api *API) Reload(cfg Config) error {
+	if cfg.IngestAPIKey != api.ingestAPIKey {
+		return fmt.Errorf("reload rejected: INGEST_API_KEY cannot change without a restart")
+	}
+	if api.databaseURL != "" && cfg.DatabaseURL != api.databaseURL {
+		return fmt.Errorf("reload rejected: DATABASE_URL cannot change without a restart")
+	}
+
+	if !cfg.SkipInsightsReload {
+		if scheduler, ok := api.insightsEngine.(*InsightsScheduler); ok {
+			scheduler.ReloadConfig(cfg.Insights)
+		}
+	}
+
+	if cfg.OpenAIModel != "" {
+		if reloadable, ok := api.alertAnalyzer.(modelReloadable); ok {
+			reloadable.SetModel(cfg.OpenAIModel)
+		}
+	}
+
+	logger.Info("applied configuration reload", "component", "config")
+	return nil
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// csvReadingColumns is the canonical column order for CSV exports of
+// SensorReading, matching the struct's field declaration order.
+var csvReadingColumns = []string{
+	"timestamp", "temperature", "pressure", "humidity",
+	"oxidised", "reduced", "nh3", "pm1", "pm2", "pm10",
+}
+
+func readingCSVRow(reading SensorReading) []string {
+	return []string{
+		strconv.FormatInt(reading.Timestamp, 10),
+		strconv.FormatFloat(reading.Temperature, 'f', -1, 64),
+		strconv.FormatFloat(reading.Pressure, 'f', -1, 64),
+		strconv.FormatFloat(reading.Humidity, 'f', -1, 64),
+		strconv.FormatFloat(reading.Oxidised, 'f', -1, 64),
+		strconv.FormatFloat(reading.Reduced, 'f', -1, 64),
+		strconv.FormatFloat(reading.Nh3, 'f', -1, 64),
+		strconv.FormatFloat(reading.PM1, 'f', -1, 64),
+		strconv.FormatFloat(reading.PM2, 'f', -1, 64),
+		strconv.FormatFloat(reading.PM10, 'f', -1, 64),
+	}
+}
+
+// wantsCSVFormat reports whether the client asked for CSV via ?format=csv
+// or an Accept: text/csv header.
+func wantsCSVFormat(request *http.Request) bool {
+	if strings.EqualFold(request.URL.Query().Get("format"), "csv") {
+		return true
+	}
+	return strings.Contains(request.Header.Get("Accept"), "text/csv")
+}
+
+// streamReadingsCSV writes readings as CSV with the canonical column
+// order, flushing every chunkSize rows, suitable for direct import into
+// pandas/Excel.
+func streamReadingsCSV(
+	response http.ResponseWriter,
+	chunkSize int,
+	truncated bool,
+	iterate func(visit func(SensorReading) error) error,
+) {
+	response.Header().Set("Content-Type", "text/csv")
+	response.Header().Set("Trailer", "X-Enviro-Count")
+	response.Header().Set("X-Enviro-Truncated", strconv.FormatBool(truncated))
+	response.WriteHeader(http.StatusOK)
+
+	flusher, _ := response.(http.Flusher)
+	writer := csv.NewWriter(response)
+	_ = writer.Write(csvReadingColumns)
+
+	count := 0
+	err := iterate(func(reading SensorReading) error {
+		if err := writer.Write(readingCSVRow(reading)); err != nil {
+			return err
+		}
+		count++
+		if count%chunkSize == 0 {
+			writer.Flush()
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("stream readings csv failed", "component", "readings", "rows", count, "error", err)
+	}
+	writer.Flush()
+
+	response.Header().Set("X-Enviro-Count", strconv.Itoa(count))
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
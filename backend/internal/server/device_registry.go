@@ -0,0 +1,320 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultDeviceID is the synthetic device identity used for the legacy
+// single-secret constructor (NewAPI(store, "secret")): every reading and
+// ops event ingested with that shared key is attributed to this device,
+// so existing deployments that never call /api/devices/register keep
+// working unchanged.
+const defaultDeviceID = "default"
+
+// Device is one registered Enviro unit, authenticated by its own API key
+// independent of every other device's.
+type Device struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	// PublicKey is an optional client-supplied public key (or CSR)
+	// recorded at registration time for a future mTLS listener to
+	// validate against, alongside the issued API key. It isn't verified
+	// or used for authentication yet.
+	PublicKey string `json:"public_key,omitempty"`
+}
+
+func (device Device) revoked() bool {
+	return device.RevokedAt != nil
+}
+
+// DeviceRegistry issues and authenticates per-device API keys. A raw API
+// key is only ever returned at registration and rotation time -- the
+// registry itself stores and looks devices up by key hash, the same way
+// a password store never keeps the plaintext around.
+type DeviceRegistry interface {
+	RegisterDevice(ctx context.Context, name string, publicKey string) (Device, string, error)
+	ListDevices(ctx context.Context) ([]Device, error)
+	RevokeDevice(ctx context.Context, id string) error
+	RotateDeviceKey(ctx context.Context, id string) (string, error)
+	AuthenticateDevice(ctx context.Context, apiKey string) (Device, bool, error)
+}
+
+// memoryDeviceRegistry is the default DeviceRegistry, used whenever store
+// doesn't itself implement one. Like memoryIngestSessionStore, it only
+// lives for the process's lifetime.
+type memoryDeviceRegistry struct {
+	mu         sync.Mutex
+	devices    map[string]Device
+	keyHashes  map[string]string // sha256 hex of an API key -> device ID
+	nextDevice int64
+}
+
+func newMemoryDeviceRegistry() *memoryDeviceRegistry {
+	return &memoryDeviceRegistry{
+		devices:   make(map[string]Device),
+		keyHashes: make(map[string]string),
+	}
+}
+
+func generateDeviceAPIKey() (string, error) {
+	var raw [32]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return "", fmt.Errorf("failed to generate device api key: %w", err)
+	}
+	return hex.EncodeToString(raw[:]), nil
+}
+
+func hashDeviceAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+func (registry *memoryDeviceRegistry) RegisterDevice(_ context.Context, name string, publicKey string) (Device, string, error) {
+	apiKey, err := generateDeviceAPIKey()
+	if err != nil {
+		return Device{}, "", err
+	}
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	registry.nextDevice++
+	device := Device{
+		ID:        fmt.Sprintf("device-%d", registry.nextDevice),
+		Name:      name,
+		CreatedAt: time.Now(),
+		PublicKey: publicKey,
+	}
+	registry.devices[device.ID] = device
+	registry.keyHashes[hashDeviceAPIKey(apiKey)] = device.ID
+
+	return device, apiKey, nil
+}
+
+func (registry *memoryDeviceRegistry) ListDevices(_ context.Context) ([]Device, error) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	devices := make([]Device, 0, len(registry.devices))
+	for _, device := range registry.devices {
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+func (registry *memoryDeviceRegistry) RevokeDevice(_ context.Context, id string) error {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	device, ok := registry.devices[id]
+	if !ok {
+		return fmt.Errorf("device %q not found", id)
+	}
+
+	now := time.Now()
+	device.RevokedAt = &now
+	registry.devices[id] = device
+	return nil
+}
+
+func (registry *memoryDeviceRegistry) RotateDeviceKey(_ context.Context, id string) (string, error) {
+	apiKey, err := generateDeviceAPIKey()
+	if err != nil {
+		return "", err
+	}
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if _, ok := registry.devices[id]; !ok {
+		return "", fmt.Errorf("device %q not found", id)
+	}
+
+	for hash, deviceID := range registry.keyHashes {
+		if deviceID == id {
+			delete(registry.keyHashes, hash)
+		}
+	}
+	registry.keyHashes[hashDeviceAPIKey(apiKey)] = id
+
+	return apiKey, nil
+}
+
+func (registry *memoryDeviceRegistry) AuthenticateDevice(_ context.Context, apiKey string) (Device, bool, error) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	deviceID, ok := registry.keyHashes[hashDeviceAPIKey(apiKey)]
+	if !ok {
+		return Device{}, false, nil
+	}
+
+	device, ok := registry.devices[deviceID]
+	if !ok || device.revoked() {
+		return Device{}, false, nil
+	}
+	return device, true, nil
+}
+
+// WithDeviceRegistry overrides the DeviceRegistry backing device
+// registration and per-device ingest authentication, mainly so tests can
+// inject a fake. Without this option, NewAPI wires up the default
+// memoryDeviceRegistry unless store itself implements DeviceRegistry.
+func WithDeviceRegistry(registry DeviceRegistry) APIOption {
+	return func(api *API) {
+		api.deviceRegistry = registry
+	}
+}
+
+// WithDeviceBootstrapToken sets the token that every
+// POST /api/devices/register requires in its X-Bootstrap-Token header,
+// so only an operator who possesses it can enroll new devices.
+func WithDeviceBootstrapToken(token string) APIOption {
+	return func(api *API) {
+		api.deviceBootstrapToken = token
+	}
+}
+
+// WithAdminAPIKey sets the key required in X-Admin-Key to list devices,
+// revoke a device, or rotate its key -- kept separate from the ingest
+// and bootstrap credentials so a compromised device key can't be used to
+// manage the fleet.
+func WithAdminAPIKey(key string) APIOption {
+	return func(api *API) {
+		api.adminAPIKey = key
+	}
+}
+
+func constantTimeEquals(provided string, expected string) bool {
+	if expected == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(provided), []byte(expected)) == 1
+}
+
+// handleDeviceRegister handles POST /api/devices/register: an operator
+// (or a provisioning script holding the bootstrap token) enrolls a new
+// device and gets back its API key exactly once -- the registry itself
+// never stores or returns it again.
+func (api *API) handleDeviceRegister(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		writeError(response, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !api.authorizeBootstrapRequest(response, request) {
+		return
+	}
+
+	request.Body = http.MaxBytesReader(response, request.Body, 4096)
+	payload, err := io.ReadAll(request.Body)
+	if err != nil {
+		writeError(response, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	var body struct {
+		Name      string `json:"name"`
+		PublicKey string `json:"public_key"`
+	}
+	if len(strings.TrimSpace(string(payload))) > 0 {
+		if err := json.Unmarshal(payload, &body); err != nil {
+			writeError(response, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if strings.TrimSpace(body.Name) == "" {
+		writeError(response, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	device, apiKey, err := api.deviceRegistry.RegisterDevice(request.Context(), body.Name, body.PublicKey)
+	if err != nil {
+		logger.Error("device registration failed", "component", "devices", "error", err)
+		writeError(response, http.StatusInternalServerError, "failed to register device")
+		return
+	}
+
+	writeJSON(response, http.StatusCreated, map[string]any{
+		"device_id": device.ID,
+		"name":      device.Name,
+		"api_key":   apiKey,
+	})
+}
+
+// handleDevicesList handles GET /api/devices, returning every registered
+// device (never including API keys -- those are only ever returned at
+// registration and rotation time).
+func (api *API) handleDevicesList(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		writeError(response, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !api.authorizeAdminRequest(response, request) {
+		return
+	}
+
+	devices, err := api.deviceRegistry.ListDevices(request.Context())
+	if err != nil {
+		logger.Error("device list failed", "component", "devices", "error", err)
+		writeError(response, http.StatusInternalServerError, "failed to list devices")
+		return
+	}
+
+	writeJSON(response, http.StatusOK, map[string]any{"devices": devices})
+}
+
+// handleDeviceByID dispatches requests under /api/devices/{id}: DELETE
+// revokes the device, and POST .../rotate issues it a fresh API key.
+func (api *API) handleDeviceByID(response http.ResponseWriter, request *http.Request) {
+	if !api.authorizeAdminRequest(response, request) {
+		return
+	}
+
+	path := strings.TrimPrefix(request.URL.Path, "/api/devices/")
+	if rotateID, ok := strings.CutSuffix(path, "/rotate"); ok {
+		if request.Method != http.MethodPost {
+			writeError(response, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		if rotateID == "" {
+			writeError(response, http.StatusBadRequest, "device id is required")
+			return
+		}
+
+		apiKey, err := api.deviceRegistry.RotateDeviceKey(request.Context(), rotateID)
+		if err != nil {
+			writeError(response, http.StatusNotFound, err.Error())
+			return
+		}
+		writeJSON(response, http.StatusOK, map[string]any{"device_id": rotateID, "api_key": apiKey})
+		return
+	}
+
+	if request.Method != http.MethodDelete {
+		writeError(response, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if path == "" {
+		writeError(response, http.StatusBadRequest, "device id is required")
+		return
+	}
+
+	if err := api.deviceRegistry.RevokeDevice(request.Context(), path); err != nil {
+		writeError(response, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(response, http.StatusOK, map[string]string{"status": "revoked"})
+}
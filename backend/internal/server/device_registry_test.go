@@ -0,0 +1,156 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func registerDeviceForTest(t *testing.T, handler http.Handler, name string) (deviceID string, apiKey string) {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodPost, "/api/devices/register", bytes.NewBufferString(`{"name":"`+name+`"}`))
+	request.Header.Set("X-Bootstrap-Token", "bootstrap-secret")
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, response.Code, response.Body.String())
+	}
+
+	var body struct {
+		DeviceID string `json:"device_id"`
+		APIKey   string `json:"api_key"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode registration response: %v", err)
+	}
+	if body.DeviceID == "" || body.APIKey == "" {
+		t.Fatalf("expected device_id and api_key in response, got %q %q", body.DeviceID, body.APIKey)
+	}
+	return body.DeviceID, body.APIKey
+}
+
+func TestHandleDeviceRegisterRequiresBootstrapToken(t *testing.T) {
+	api := NewAPI(&fakeStore{}, "secret", WithDeviceBootstrapToken("bootstrap-secret"))
+	handler := api.Handler()
+
+	request := httptest.NewRequest(http.MethodPost, "/api/devices/register", bytes.NewBufferString(`{"name":"roof-sensor"}`))
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, response.Code)
+	}
+}
+
+func TestDeviceRegisterThenIngestStampsDeviceID(t *testing.T) {
+	store := &fakeStore{}
+	api := NewAPI(store, "secret", WithDeviceBootstrapToken("bootstrap-secret"))
+	handler := api.Handler()
+
+	deviceID, apiKey := registerDeviceForTest(t, handler, "roof-sensor")
+
+	request := httptest.NewRequest(http.MethodPost, "/api/ingest", bytes.NewBufferString(`{
+		"timestamp":"1738886400",
+		"temperature":"22.4",
+		"pressure":"101305",
+		"humidity":"40.1",
+		"oxidised":"1.2",
+		"reduced":"1.1",
+		"nh3":"0.7",
+		"pm1":"2",
+		"pm2":"3",
+		"pm10":"4"
+	}`))
+	request.Header.Set("X-API-Key", apiKey)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, response.Code, response.Body.String())
+	}
+	if len(store.added) != 1 {
+		t.Fatalf("expected 1 persisted reading, got %d", len(store.added))
+	}
+	if store.added[0].DeviceID != deviceID {
+		t.Fatalf("expected reading stamped with device id %q, got %q", deviceID, store.added[0].DeviceID)
+	}
+}
+
+func TestRotatedDeviceKeyRejectsIngestWithOldKey(t *testing.T) {
+	store := &fakeStore{}
+	api := NewAPI(
+		store,
+		"secret",
+		WithDeviceBootstrapToken("bootstrap-secret"),
+		WithAdminAPIKey("admin-secret"),
+	)
+	handler := api.Handler()
+
+	deviceID, oldKey := registerDeviceForTest(t, handler, "roof-sensor")
+
+	rotateRequest := httptest.NewRequest(http.MethodPost, "/api/devices/"+deviceID+"/rotate", nil)
+	rotateRequest.Header.Set("X-Admin-Key", "admin-secret")
+	rotateResponse := httptest.NewRecorder()
+	handler.ServeHTTP(rotateResponse, rotateRequest)
+
+	if rotateResponse.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rotateResponse.Code, rotateResponse.Body.String())
+	}
+
+	ingestRequest := httptest.NewRequest(http.MethodPost, "/api/ingest", bytes.NewBufferString(`{
+		"timestamp":"1738886400",
+		"temperature":"22.4",
+		"pressure":"101305",
+		"humidity":"40.1",
+		"oxidised":"1.2",
+		"reduced":"1.1",
+		"nh3":"0.7",
+		"pm1":"2",
+		"pm2":"3",
+		"pm10":"4"
+	}`))
+	ingestRequest.Header.Set("X-API-Key", oldKey)
+	ingestResponse := httptest.NewRecorder()
+	handler.ServeHTTP(ingestResponse, ingestRequest)
+
+	if ingestResponse.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d for revoked key, got %d", http.StatusUnauthorized, ingestResponse.Code)
+	}
+}
+
+func TestHandleReadingsFiltersByDeviceID(t *testing.T) {
+	store := &fakeStore{latest: []SensorReading{
+		{Timestamp: 1, DeviceID: "device-1"},
+		{Timestamp: 2, DeviceID: "device-2"},
+		{Timestamp: 3, DeviceID: "device-1"},
+	}}
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	request := httptest.NewRequest(http.MethodGet, "/api/readings?device_id=device-1", nil)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, response.Code, response.Body.String())
+	}
+
+	var body struct {
+		Readings []SensorReading `json:"readings"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode readings response: %v", err)
+	}
+	if len(body.Readings) != 2 {
+		t.Fatalf("expected 2 readings for device-1, got %d", len(body.Readings))
+	}
+	for _, reading := range body.Readings {
+		if reading.DeviceID != "device-1" {
+			t.Fatalf("expected only device-1 readings, got %q", reading.DeviceID)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	subscriptionModeAll     = "all"
+	subscriptionModeSampled = "sampled"
+
+	// dynamicSubscriptionSampleInterval is the minimum gap between
+	// deliveries for a "sampled" mode subscription, throttling a sink
+	// that only wants a periodic sample rather than every reading.
+	dynamicSubscriptionSampleInterval = 30 * time.Second
+)
+
+// SubscriptionDefinition is an operator-registered fan-out target for
+// ingested readings, modeled after InfluxDB's Subscriptions feature:
+// POST /api/subscriptions {name, url, mode, filter} registers one, backed
+// by a subscriptionSink -- the same bounded drop-oldest queue and
+// exponential-backoff retry chunk1-1 built for the built-in MQTT/webhook/
+// Influx sinks. URL's scheme picks the transport: "udp://host:port" is
+// delivered as a fire-and-forget UDP datagram, anything else is POSTed as
+// JSON over HTTP. Filter, when set, is a rule expression in the same
+// METRIC OP VALUE [and|or ...] grammar AlertRule.Expr uses (see
+// compileRuleExpr); Mode "sampled" additionally throttles delivery to at
+// most one reading per dynamicSubscriptionSampleInterval, "all" forwards
+// every matching reading.
+type SubscriptionDefinition struct {
+	ID     int64  `json:"id,omitempty"`
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Mode   string `json:"mode"`
+	Filter string `json:"filter,omitempty"`
+}
+
+// SubscriptionStore is an optional Store capability (see PostgresStore's
+// subscriptions table) that persists SubscriptionDefinitions so they
+// survive a restart, the same pattern as InsightsSnapshotStore and
+// OpsEventStore for the other optional PostgresStore-only features.
+type SubscriptionStore interface {
+	AddSubscription(ctx context.Context, definition SubscriptionDefinition) (SubscriptionDefinition, error)
+	ListSubscriptions(ctx context.Context) ([]SubscriptionDefinition, error)
+	DeleteSubscription(ctx context.Context, id int64) error
+}
+
+func validateSubscriptionDefinition(definition SubscriptionDefinition) error {
+	if strings.TrimSpace(definition.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if strings.TrimSpace(definition.URL) == "" {
+		return fmt.Errorf("url is required")
+	}
+	if _, err := url.Parse(definition.URL); err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	switch definition.Mode {
+	case subscriptionModeAll, subscriptionModeSampled:
+	default:
+		return fmt.Errorf("mode must be %q or %q", subscriptionModeAll, subscriptionModeSampled)
+	}
+	if definition.Filter != "" {
+		if _, err := compileRuleExpr(definition.Filter); err != nil {
+			return fmt.Errorf("invalid filter: %w", err)
+		}
+	}
+	return nil
+}
+
+// dynamicSubscriptionSink wraps a SubscriptionDefinition as a Subscriber,
+// delivering over HTTP or UDP depending on URL's scheme and applying
+// Filter/Mode before a reading is even enqueued on the underlying
+// subscriptionSink.
+type dynamicSubscriptionSink struct {
+	definition SubscriptionDefinition
+	filter     *compiledRuleExpr
+	httpClient *http.Client
+	udpAddr    *net.UDPAddr
+
+	mu           sync.Mutex
+	lastSampleAt time.Time
+}
+
+func newDynamicSubscriptionSink(definition SubscriptionDefinition) (*dynamicSubscriptionSink, error) {
+	sink := &dynamicSubscriptionSink{definition: definition}
+
+	if definition.Filter != "" {
+		compiled, err := compileRuleExpr(definition.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter: %w", err)
+		}
+		sink.filter = compiled
+	}
+
+	parsed, err := url.Parse(definition.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+
+	if parsed.Scheme == "udp" {
+		addr, err := net.ResolveUDPAddr("udp", parsed.Host)
+		if err != nil {
+			return nil, fmt.Errorf("resolve udp address: %w", err)
+		}
+		sink.udpAddr = addr
+	} else {
+		sink.httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return sink, nil
+}
+
+func (sink *dynamicSubscriptionSink) Name() string {
+	return "subscription:" + sink.definition.Name
+}
+
+// shouldDeliver applies Filter and Mode, returning false to have the
+// caller skip this reading entirely without ever touching the queue.
+func (sink *dynamicSubscriptionSink) shouldDeliver(reading SensorReading) bool {
+	if sink.filter != nil {
+		if matched, _ := sink.filter.evaluate(reading); !matched {
+			return false
+		}
+	}
+
+	if sink.definition.Mode != subscriptionModeSampled {
+		return true
+	}
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	now := time.Now()
+	if now.Sub(sink.lastSampleAt) < dynamicSubscriptionSampleInterval {
+		return false
+	}
+	sink.lastSampleAt = now
+	return true
+}
+
+func (sink *dynamicSubscriptionSink) Publish(ctx context.Context, reading SensorReading) error {
+	body, err := json.Marshal(reading)
+	if err != nil {
+		return fmt.Errorf("marshal reading: %w", err)
+	}
+	return sink.send(ctx, body)
+}
+
+// PublishInsights is a no-op: dynamic subscriptions forward ingested
+// readings only (POST /api/subscriptions registers a reading sink, not an
+// insights sink), unlike chunk1-1's built-in MQTT/webhook/Influx sinks
+// which also mirror InsightsSnapshots.
+func (sink *dynamicSubscriptionSink) PublishInsights(_ context.Context, _ InsightsSnapshot) error {
+	return nil
+}
+
+func (sink *dynamicSubscriptionSink) send(ctx context.Context, body []byte) error {
+	if sink.udpAddr != nil {
+		conn, err := net.DialUDP("udp", nil, sink.udpAddr)
+		if err != nil {
+			return fmt.Errorf("dial udp: %w", err)
+		}
+		defer conn.Close()
+		_, err = conn.Write(body)
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, sink.definition.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := sink.httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer response.Body.Close()
+
+	// Only a 5xx is treated as retryable -- a 4xx means the subscriber
+	// itself rejected the payload, and retrying an exponential backoff
+	// against a client error just wastes the queue's time.
+	if response.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("subscription endpoint status %d", response.StatusCode)
+	}
+	if response.StatusCode >= http.StatusMultipleChoices {
+		logger.Warn("subscription endpoint rejected delivery, not retrying",
+			"component", "subscriptions", "sink", sink.Name(), "status", response.StatusCode)
+	}
+	return nil
+}
+
+var _ Subscriber = (*dynamicSubscriptionSink)(nil)
+
+// dynamicSubscriptionEntry pairs a registered definition with the running
+// subscriptionSink delivering it.
+type dynamicSubscriptionEntry struct {
+	definition SubscriptionDefinition
+	subscriber *dynamicSubscriptionSink
+	sink       *subscriptionSink
+}
+
+// dynamicSubscriptionBroker fans out ingested readings to a dynamic set of
+// operator-registered SubscriptionDefinitions, reusing subscriptionSink's
+// bounded drop-oldest queue and retry-with-backoff delivery loop. Unlike
+// subscriptionHub (chunk1-1's fixed, config-at-startup set of sinks), its
+// membership changes at runtime via add/remove as operators register and
+// unregister subscriptions. overflow, when set, is called every time a
+// sink's queue drops a reading, so PostgresStore can record it as an ops
+// event instead of only a silently-incrementing counter.
+type dynamicSubscriptionBroker struct {
+	ctx      context.Context
+	overflow func(subscriptionName string)
+
+	mu    sync.RWMutex
+	sinks map[int64]*dynamicSubscriptionEntry
+}
+
+func newDynamicSubscriptionBroker(ctx context.Context, overflow func(subscriptionName string)) *dynamicSubscriptionBroker {
+	return &dynamicSubscriptionBroker{
+		ctx:      ctx,
+		overflow: overflow,
+		sinks:    make(map[int64]*dynamicSubscriptionEntry),
+	}
+}
+
+func (broker *dynamicSubscriptionBroker) add(definition SubscriptionDefinition) error {
+	subscriber, err := newDynamicSubscriptionSink(definition)
+	if err != nil {
+		return err
+	}
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	broker.sinks[definition.ID] = &dynamicSubscriptionEntry{
+		definition: definition,
+		subscriber: subscriber,
+		sink:       newSubscriptionSink(broker.ctx, subscriber),
+	}
+	return nil
+}
+
+func (broker *dynamicSubscriptionBroker) remove(id int64) {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	delete(broker.sinks, id)
+}
+
+func (broker *dynamicSubscriptionBroker) publish(reading SensorReading) {
+	broker.mu.RLock()
+	defer broker.mu.RUnlock()
+
+	for _, entry := range broker.sinks {
+		if !entry.subscriber.shouldDeliver(reading) {
+			continue
+		}
+		if dropped := entry.sink.publish(reading); dropped && broker.overflow != nil {
+			broker.overflow(entry.definition.Name)
+		}
+	}
+}
+
+// --- handlers ---
+
+func (api *API) handleSubscriptions(response http.ResponseWriter, request *http.Request) {
+	if !api.authorizeIngestRequest(response, request) {
+		return
+	}
+
+	if api.subscriptionStore == nil {
+		writeError(response, http.StatusNotImplemented, "subscriptions are not supported by this store")
+		return
+	}
+
+	switch request.Method {
+	case http.MethodGet:
+		subscriptions, err := api.subscriptionStore.ListSubscriptions(request.Context())
+		if err != nil {
+			writeError(response, http.StatusInternalServerError, "failed to list subscriptions")
+			return
+		}
+		writeJSON(response, http.StatusOK, map[string]any{"subscriptions": subscriptions})
+
+	case http.MethodPost:
+		request.Body = http.MaxBytesReader(response, request.Body, maxIngestBodyBytes)
+		payload, err := io.ReadAll(request.Body)
+		if err != nil {
+			writeError(response, http.StatusBadRequest, "invalid request body")
+			return
+		}
+
+		var definition SubscriptionDefinition
+		if err := json.Unmarshal(payload, &definition); err != nil {
+			writeError(response, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := validateSubscriptionDefinition(definition); err != nil {
+			writeError(response, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		stored, err := api.subscriptionStore.AddSubscription(request.Context(), definition)
+		if err != nil {
+			writeError(response, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		writeJSON(response, http.StatusCreated, stored)
+
+	default:
+		writeError(response, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (api *API) handleSubscriptionByID(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodDelete {
+		writeError(response, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !api.authorizeIngestRequest(response, request) {
+		return
+	}
+
+	if api.subscriptionStore == nil {
+		writeError(response, http.StatusNotImplemented, "subscriptions are not supported by this store")
+		return
+	}
+
+	rawID := strings.TrimPrefix(request.URL.Path, "/api/subscriptions/")
+	id, err := strconv.ParseInt(rawID, 10, 64)
+	if rawID == "" || err != nil {
+		writeError(response, http.StatusBadRequest, "subscription id is required")
+		return
+	}
+
+	if err := api.subscriptionStore.DeleteSubscription(request.Context(), id); err != nil {
+		writeError(response, http.StatusNotFound, err.Error())
+		return
+	}
+
+	writeJSON(response, http.StatusOK, map[string]string{"status": "deleted"})
+}
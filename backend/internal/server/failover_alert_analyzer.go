@@ -0,0 +1,45 @@
+package server
+
+import (
+	"context"
+	"time"
+)
+
+// failoverAlertAnalyzer tries a primary AlertAnalyzer and transparently
+// falls back to a secondary one on error or timeout, so a flaky or
+// rate-limited LLM backend doesn't blank out /api/insights.
+type failoverAlertAnalyzer struct {
+	primary   AlertAnalyzer
+	secondary AlertAnalyzer
+}
+
+// NewFailoverAlertAnalyzer returns an AlertAnalyzer that calls primary
+// first and, if it returns an error (including a context deadline),
+// falls back to secondary. secondary is typically NewRuleBasedAlertAnalyzer
+// so there's always an offline analyzer to land on.
+func NewFailoverAlertAnalyzer(primary AlertAnalyzer, secondary AlertAnalyzer) AlertAnalyzer {
+	return &failoverAlertAnalyzer{primary: primary, secondary: secondary}
+}
+
+func (analyzer *failoverAlertAnalyzer) Source() string {
+	return "failover:" + analyzer.primary.Source() + "+" + analyzer.secondary.Source()
+}
+
+func (analyzer *failoverAlertAnalyzer) Analyze(ctx context.Context, readings []SensorReading) ([]Alert, error) {
+	primaryCtx := ctx
+	if deadline, ok := ctx.Deadline(); ok {
+		halfCtx, cancel := context.WithTimeout(ctx, time.Until(deadline)/2)
+		defer cancel()
+		primaryCtx = halfCtx
+	}
+
+	alerts, err := analyzer.primary.Analyze(primaryCtx, readings)
+	if err == nil {
+		return alerts, nil
+	}
+
+	logger.Warn("failover analyzer primary failed, falling back to secondary",
+		"component", "alerts", "primary", analyzer.primary.Source(), "error", err)
+
+	return analyzer.secondary.Analyze(ctx, readings)
+}
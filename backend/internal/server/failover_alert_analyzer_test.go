@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFailoverAlertAnalyzerUsesPrimaryOnSuccess(t *testing.T) {
+	primary := &fakeAlertAnalyzer{alerts: []Alert{{Kind: "insight", Severity: "info", Title: "Primary", Message: "from primary"}}, source: "primary"}
+	secondary := &fakeAlertAnalyzer{alerts: []Alert{{Kind: "insight", Severity: "info", Title: "Secondary", Message: "from secondary"}}, source: "secondary"}
+
+	analyzer := NewFailoverAlertAnalyzer(primary, secondary)
+
+	alerts, err := analyzer.Analyze(context.Background(), []SensorReading{{Timestamp: 1738886400000}})
+	if err != nil {
+		t.Fatalf("analyze returned error: %v", err)
+	}
+	if len(alerts) != 1 || alerts[0].Title != "Primary" {
+		t.Fatalf("expected primary's alert, got %+v", alerts)
+	}
+	if secondary.calls != 0 {
+		t.Fatalf("expected secondary not to be called, got %d calls", secondary.calls)
+	}
+}
+
+func TestFailoverAlertAnalyzerFallsBackToSecondaryOnError(t *testing.T) {
+	primary := &fakeAlertAnalyzer{err: errors.New("primary unavailable"), source: "primary"}
+	secondary := &fakeAlertAnalyzer{alerts: []Alert{{Kind: "insight", Severity: "info", Title: "Secondary", Message: "from secondary"}}, source: "secondary"}
+
+	analyzer := NewFailoverAlertAnalyzer(primary, secondary)
+
+	alerts, err := analyzer.Analyze(context.Background(), []SensorReading{{Timestamp: 1738886400000}})
+	if err != nil {
+		t.Fatalf("analyze returned error: %v", err)
+	}
+	if len(alerts) != 1 || alerts[0].Title != "Secondary" {
+		t.Fatalf("expected secondary's alert after primary failure, got %+v", alerts)
+	}
+	if primary.calls != 1 {
+		t.Fatalf("expected primary to be called once, got %d calls", primary.calls)
+	}
+}
+
+func TestFailoverAlertAnalyzerSourceDescribesBothBackends(t *testing.T) {
+	analyzer := NewFailoverAlertAnalyzer(
+		&fakeAlertAnalyzer{source: "primary"},
+		&fakeAlertAnalyzer{source: "secondary"},
+	)
+
+	if source := analyzer.Source(); source != "failover:primary+secondary" {
+		t.Fatalf("expected combined source, got %q", source)
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// InfluxLineProtocolSubscriber pushes each reading to a remote InfluxDB v2
+// /api/v2/write endpoint, encoded with EncodeLineProtocolPoint the same way
+// the line-protocol ingest endpoint decodes it.
+type InfluxLineProtocolSubscriber struct {
+	httpClient *http.Client
+	writeURL   string
+	token      string
+}
+
+// NewInfluxLineProtocolSubscriber returns a subscriber that writes to an
+// InfluxDB v2 instance at baseURL (e.g. "http://influxdb:8086"), bucket and
+// org identify the destination, and token authenticates the write.
+func NewInfluxLineProtocolSubscriber(baseURL string, org string, bucket string, token string) *InfluxLineProtocolSubscriber {
+	query := url.Values{"org": {org}, "bucket": {bucket}, "precision": {"ns"}}
+	writeURL := strings.TrimRight(baseURL, "/") + "/api/v2/write?" + query.Encode()
+
+	return &InfluxLineProtocolSubscriber{
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		writeURL:   writeURL,
+		token:      token,
+	}
+}
+
+func (subscriber *InfluxLineProtocolSubscriber) Name() string {
+	return "influx:" + subscriber.writeURL
+}
+
+func (subscriber *InfluxLineProtocolSubscriber) Publish(ctx context.Context, reading SensorReading) error {
+	return subscriber.write(ctx, EncodeLineProtocolPoint(reading))
+}
+
+// PublishInsights writes a single "enviro_insights" point summarizing the
+// snapshot, since a full InsightsSnapshot doesn't decompose into the flat
+// field set line protocol expects.
+func (subscriber *InfluxLineProtocolSubscriber) PublishInsights(ctx context.Context, snapshot InsightsSnapshot) error {
+	body := fmt.Sprintf(
+		"enviro_insights,source=%s,trigger=%s insights_count=%di,analyzed_samples=%di %d",
+		snapshot.Source,
+		snapshot.Trigger,
+		len(snapshot.Insights),
+		snapshot.AnalyzedSamples,
+		snapshot.GeneratedAt*1_000_000,
+	)
+	return subscriber.write(ctx, body)
+}
+
+func (subscriber *InfluxLineProtocolSubscriber) write(ctx context.Context, body string) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, subscriber.writeURL, strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	request.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if subscriber.token != "" {
+		request.Header.Set("Authorization", "Token "+subscriber.token)
+	}
+
+	response, err := subscriber.httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("influx write status %d", response.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// withIngestMetrics records request count and latency for an ingest route,
+// labeled by a truncated SHA-256 hash of the caller's X-API-Key (never the
+// raw key) and the response status code, so operators can see per-device
+// ingest volume and error rate without the secret leaking into metric
+// labels.
+func withIngestMetrics(next http.HandlerFunc) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: response, statusCode: http.StatusOK}
+
+		next(recorder, request)
+
+		keyHash := ingestAPIKeyHash(request.Header.Get("X-API-Key"))
+		status := strconv.Itoa(recorder.statusCode)
+		ingestRequestsTotal.WithLabelValues(keyHash, status).Inc()
+		ingestRequestDuration.WithLabelValues(keyHash, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// statusRecorder captures the status code an inner handler wrote, since
+// http.ResponseWriter doesn't expose it once WriteHeader has been called.
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (recorder *statusRecorder) WriteHeader(statusCode int) {
+	recorder.statusCode = statusCode
+	recorder.ResponseWriter.WriteHeader(statusCode)
+}
+
+func ingestAPIKeyHash(apiKey string) string {
+	if apiKey == "" {
+		return "none"
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])[:12]
+}
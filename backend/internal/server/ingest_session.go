@@ -0,0 +1,499 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultIngestSessionTTL is how long an opened chunked-ingest session
+// stays resumable before it's treated as abandoned, absent an explicit
+// OpsConfig.IngestSessionTTL override.
+const defaultIngestSessionTTL = 24 * time.Hour
+
+// maxIngestSessionTotal bounds how many readings a single session can
+// declare up front, the chunked-upload analogue of maxBatchSize.
+const maxIngestSessionTotal = 200000
+
+// IngestSession is the resumable state of one chunked batch upload: an
+// intermittently-connected device opens a session for its whole backlog
+// (Total readings), then streams it in over PATCH chunks addressed by
+// offset, the way chunked blob upload protocols (tus.io, Azure Block
+// Blob) let a flaky link resume from the last acked offset instead of
+// restarting the whole transfer.
+type IngestSession struct {
+	ID        string          `json:"id"`
+	DeviceID  string          `json:"device_id"`
+	Total     int64           `json:"total"`
+	Readings  []SensorReading `json:"readings"`
+	Received  []bool          `json:"received"`
+	Committed bool            `json:"committed"`
+	CreatedAt time.Time       `json:"created_at"`
+	ExpiresAt time.Time       `json:"expires_at"`
+}
+
+// watermark returns the highest offset n such that every reading at
+// offsets [0,n) has been received: the contiguous prefix a resuming
+// client can safely skip over.
+func (session *IngestSession) watermark() int64 {
+	for offset, received := range session.Received {
+		if !received {
+			return int64(offset)
+		}
+	}
+	return int64(len(session.Received))
+}
+
+func (session *IngestSession) expired(now time.Time) bool {
+	return now.After(session.ExpiresAt)
+}
+
+// IngestSessionStore persists chunked-ingest session state so a session
+// opened before a process restart can still be resumed afterward,
+// mirroring how OpsEventStore persists ops history independent of the
+// process lifetime. HasIngestedReading/MarkReadingIngested form a
+// separate, longer-lived dedupe ledger (keyed on (device_id, timestamp))
+// so a commit retried after a restart never double-inserts into the main
+// Store even once its IngestSession has expired and been pruned.
+type IngestSessionStore interface {
+	SaveIngestSession(ctx context.Context, session IngestSession) error
+	LoadIngestSession(ctx context.Context, id string) (IngestSession, bool, error)
+	DeleteIngestSession(ctx context.Context, id string) error
+	PruneExpiredIngestSessions(ctx context.Context, now time.Time) (int64, error)
+	HasIngestedReading(ctx context.Context, deviceID string, timestamp int64) (bool, error)
+	MarkReadingIngested(ctx context.Context, deviceID string, timestamp int64) error
+}
+
+// memoryIngestSessionStore is the default IngestSessionStore, used
+// whenever store doesn't itself implement one. It keeps state only for
+// this process's lifetime, so a restart loses in-flight sessions and the
+// dedupe ledger -- acceptable for the in-memory MemoryStore deployment,
+// since nothing else about that deployment survives a restart either.
+type memoryIngestSessionStore struct {
+	mu        sync.Mutex
+	sessions  map[string]IngestSession
+	committed map[string]struct{}
+}
+
+func newMemoryIngestSessionStore() *memoryIngestSessionStore {
+	return &memoryIngestSessionStore{
+		sessions:  make(map[string]IngestSession),
+		committed: make(map[string]struct{}),
+	}
+}
+
+func (store *memoryIngestSessionStore) SaveIngestSession(_ context.Context, session IngestSession) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.sessions[session.ID] = session
+	return nil
+}
+
+func (store *memoryIngestSessionStore) LoadIngestSession(_ context.Context, id string) (IngestSession, bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	session, ok := store.sessions[id]
+	return session, ok, nil
+}
+
+func (store *memoryIngestSessionStore) DeleteIngestSession(_ context.Context, id string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	delete(store.sessions, id)
+	return nil
+}
+
+func (store *memoryIngestSessionStore) PruneExpiredIngestSessions(_ context.Context, now time.Time) (int64, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	var pruned int64
+	for id, session := range store.sessions {
+		if !session.Committed && session.expired(now) {
+			delete(store.sessions, id)
+			pruned++
+		}
+	}
+	return pruned, nil
+}
+
+func ingestDedupeKey(deviceID string, timestamp int64) string {
+	return deviceID + ":" + strconv.FormatInt(timestamp, 10)
+}
+
+func (store *memoryIngestSessionStore) HasIngestedReading(_ context.Context, deviceID string, timestamp int64) (bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	_, ok := store.committed[ingestDedupeKey(deviceID, timestamp)]
+	return ok, nil
+}
+
+func (store *memoryIngestSessionStore) MarkReadingIngested(_ context.Context, deviceID string, timestamp int64) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.committed[ingestDedupeKey(deviceID, timestamp)] = struct{}{}
+	return nil
+}
+
+// WithIngestSessionStore overrides the IngestSessionStore backing the
+// chunked ingest session endpoints, mainly so tests can inject a fake.
+// Without this option, NewAPI wires up the default
+// memoryIngestSessionStore unless store itself implements
+// IngestSessionStore.
+func WithIngestSessionStore(sessionStore IngestSessionStore) APIOption {
+	return func(api *API) {
+		api.ingestSessionStore = sessionStore
+	}
+}
+
+// handleIngestSessionOpen handles POST /api/ingest/session: it reserves a
+// session for total readings and returns the Location of the chunk
+// endpoint plus an Upload-UUID header, mirroring the response shape of
+// chunked blob upload protocols.
+func (api *API) handleIngestSessionOpen(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		writeError(response, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	deviceID, ok := api.authorizeIngestRequestForDevice(response, request)
+	if !ok {
+		return
+	}
+
+	request.Body = http.MaxBytesReader(response, request.Body, 4096)
+	payload, err := io.ReadAll(request.Body)
+	if err != nil {
+		writeError(response, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	var body struct {
+		Total int64 `json:"total"`
+	}
+	if len(strings.TrimSpace(string(payload))) > 0 {
+		if err := json.Unmarshal(payload, &body); err != nil {
+			writeError(response, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if body.Total <= 0 || body.Total > maxIngestSessionTotal {
+		writeError(response, http.StatusBadRequest, fmt.Sprintf("total must be between 1 and %d", maxIngestSessionTotal))
+		return
+	}
+
+	ttl := api.opsConfig.IngestSessionTTL
+	if ttl <= 0 {
+		ttl = defaultIngestSessionTTL
+	}
+	now := time.Now()
+	session := IngestSession{
+		ID:        NewRequestID(),
+		DeviceID:  deviceID,
+		Total:     body.Total,
+		Readings:  make([]SensorReading, body.Total),
+		Received:  make([]bool, body.Total),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+
+	if err := api.ingestSessionStore.SaveIngestSession(request.Context(), session); err != nil {
+		logger.Error("ingest session create failed", "component", "ingest", "error", err)
+		writeError(response, http.StatusInternalServerError, "failed to create session")
+		return
+	}
+
+	location := "/api/ingest/session/" + session.ID
+	response.Header().Set("Location", location)
+	response.Header().Set("Upload-UUID", session.ID)
+	writeJSON(response, http.StatusCreated, map[string]any{
+		"id":         session.ID,
+		"location":   location,
+		"expires_at": session.ExpiresAt,
+	})
+}
+
+// parseIngestContentRange parses a "readings <start>-<end>/<total>"
+// Content-Range header, the readings-array analogue of RFC 9110's
+// byte-range unit.
+func parseIngestContentRange(header string) (start int64, end int64, total int64, err error) {
+	fields := strings.Fields(strings.TrimSpace(header))
+	if len(fields) != 2 || fields[0] != "readings" {
+		return 0, 0, 0, fmt.Errorf("content-range must look like %q", "readings <start>-<end>/<total>")
+	}
+
+	rangeAndTotal := strings.SplitN(fields[1], "/", 2)
+	if len(rangeAndTotal) != 2 {
+		return 0, 0, 0, fmt.Errorf("content-range is missing a total")
+	}
+
+	startAndEnd := strings.SplitN(rangeAndTotal[0], "-", 2)
+	if len(startAndEnd) != 2 {
+		return 0, 0, 0, fmt.Errorf("content-range is missing a start-end pair")
+	}
+
+	start, err = strconv.ParseInt(startAndEnd[0], 10, 64)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("content-range start is not a number")
+	}
+	end, err = strconv.ParseInt(startAndEnd[1], 10, 64)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("content-range end is not a number")
+	}
+	total, err = strconv.ParseInt(rangeAndTotal[1], 10, 64)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("content-range total is not a number")
+	}
+	if end < start {
+		return 0, 0, 0, fmt.Errorf("content-range end must not be before start")
+	}
+	return start, end, total, nil
+}
+
+// handleIngestSessionChunk handles PATCH /api/ingest/session/{id}: it
+// writes a chunk of readings at the offsets named by Content-Range into
+// the session, idempotently (re-sending an already-received offset just
+// overwrites it with the same data), and reports the new contiguous
+// watermark via a Range response header.
+func (api *API) handleIngestSessionChunk(response http.ResponseWriter, request *http.Request, id string) {
+	if !api.authorizeIngestRequest(response, request) {
+		return
+	}
+
+	session, ok, err := api.ingestSessionStore.LoadIngestSession(request.Context(), id)
+	if err != nil {
+		logger.Error("ingest session load failed", "component", "ingest", "error", err)
+		writeError(response, http.StatusInternalServerError, "failed to load session")
+		return
+	}
+	if !ok {
+		writeError(response, http.StatusNotFound, "session not found")
+		return
+	}
+	if session.Committed {
+		writeError(response, http.StatusConflict, "session already committed")
+		return
+	}
+	if session.expired(time.Now()) {
+		writeError(response, http.StatusGone, "session expired")
+		return
+	}
+
+	start, end, total, err := parseIngestContentRange(request.Header.Get("Content-Range"))
+	if err != nil {
+		writeError(response, http.StatusBadRequest, err.Error())
+		return
+	}
+	if total != session.Total {
+		writeError(response, http.StatusBadRequest, "content-range total does not match the session")
+		return
+	}
+	if start < 0 || end >= session.Total {
+		writeError(response, http.StatusBadRequest, "content-range is out of bounds for the session")
+		return
+	}
+
+	request.Body = http.MaxBytesReader(response, request.Body, maxBatchBodyBytes)
+	payload, err := io.ReadAll(request.Body)
+	if err != nil {
+		writeError(response, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	readings, err := DecodeReadingsBatch(payload, maxBatchSize)
+	if err != nil {
+		writeError(response, http.StatusBadRequest, err.Error())
+		return
+	}
+	if int64(len(readings)) != end-start+1 {
+		writeError(response, http.StatusBadRequest, "chunk does not contain content-range's declared number of readings")
+		return
+	}
+
+	for index, reading := range readings {
+		offset := start + int64(index)
+		reading.DeviceID = session.DeviceID
+		session.Readings[offset] = reading
+		session.Received[offset] = true
+	}
+
+	if err := api.ingestSessionStore.SaveIngestSession(request.Context(), session); err != nil {
+		logger.Error("ingest session save failed", "component", "ingest", "error", err)
+		writeError(response, http.StatusInternalServerError, "failed to persist chunk")
+		return
+	}
+
+	watermark := session.watermark()
+	response.Header().Set("Range", fmt.Sprintf("0-%d", watermark))
+	writeJSON(response, http.StatusAccepted, map[string]any{
+		"range": fmt.Sprintf("0-%d", watermark),
+	})
+}
+
+// handleIngestSessionCommit handles PUT /api/ingest/session/{id}: once
+// every offset has been received, it verifies the client-supplied ETag
+// against the server's own hash of the assembled payload, then persists
+// the session's readings to the main Store -- skipping any reading
+// already marked ingested under (device_id, timestamp), so retrying a
+// commit whose response was lost never double-inserts.
+func (api *API) handleIngestSessionCommit(response http.ResponseWriter, request *http.Request, id string) {
+	if !api.authorizeIngestRequest(response, request) {
+		return
+	}
+
+	session, ok, err := api.ingestSessionStore.LoadIngestSession(request.Context(), id)
+	if err != nil {
+		logger.Error("ingest session load failed", "component", "ingest", "error", err)
+		writeError(response, http.StatusInternalServerError, "failed to load session")
+		return
+	}
+	if !ok {
+		writeError(response, http.StatusNotFound, "session not found")
+		return
+	}
+	if session.Committed {
+		writeJSON(response, http.StatusOK, map[string]any{"status": "committed", "ingested": session.Total})
+		return
+	}
+	if session.expired(time.Now()) {
+		writeError(response, http.StatusGone, "session expired")
+		return
+	}
+	if session.watermark() != session.Total {
+		writeError(response, http.StatusConflict, "session has missing chunks")
+		return
+	}
+
+	digest, err := json.Marshal(session.Readings)
+	if err != nil {
+		writeError(response, http.StatusInternalServerError, "failed to assemble session payload")
+		return
+	}
+	sum := sha256.Sum256(digest)
+	expectedETag := hex.EncodeToString(sum[:])
+
+	providedETag := strings.Trim(request.Header.Get("ETag"), `"`)
+	if providedETag == "" || providedETag != expectedETag {
+		writeError(response, http.StatusConflict, "etag does not match the assembled session payload")
+		return
+	}
+
+	readingsToIngest := make([]SensorReading, 0, len(session.Readings))
+	for _, reading := range session.Readings {
+		alreadyIngested, err := api.ingestSessionStore.HasIngestedReading(request.Context(), session.DeviceID, reading.Timestamp)
+		if err != nil {
+			logger.Error("ingest session dedupe check failed", "component", "ingest", "error", err)
+			writeError(response, http.StatusInternalServerError, "failed to check dedupe state")
+			return
+		}
+		if !alreadyIngested {
+			readingsToIngest = append(readingsToIngest, reading)
+		}
+	}
+
+	if len(readingsToIngest) > 0 {
+		if err := api.store.AddBatch(request.Context(), readingsToIngest); err != nil {
+			logger.Error("ingest session commit persist failed", "component", "ingest", "error", err)
+			writeError(response, http.StatusInternalServerError, "failed to persist readings")
+			return
+		}
+		for _, reading := range readingsToIngest {
+			if err := api.ingestSessionStore.MarkReadingIngested(request.Context(), session.DeviceID, reading.Timestamp); err != nil {
+				logger.Error("ingest session dedupe mark failed", "component", "ingest", "error", err)
+			}
+		}
+		api.onTelemetryReceived(session.DeviceID, time.Now())
+		for _, reading := range readingsToIngest {
+			api.stream.publish(reading)
+			api.subscriptions.publish(reading)
+		}
+		if api.insightsEngine != nil {
+			api.insightsEngine.OnBatch(readingsToIngest)
+		}
+		if api.rulesEngine != nil {
+			api.rulesEngine.OnBatch(readingsToIngest)
+		}
+	}
+
+	session.Committed = true
+	if err := api.ingestSessionStore.SaveIngestSession(request.Context(), session); err != nil {
+		logger.Error("ingest session save failed", "component", "ingest", "error", err)
+	}
+
+	writeJSON(response, http.StatusOK, map[string]any{
+		"status":   "committed",
+		"ingested": len(readingsToIngest),
+	})
+}
+
+// handleIngestSessionByID dispatches PATCH and PUT requests under
+// /api/ingest/session/{id} to handleIngestSessionChunk and
+// handleIngestSessionCommit respectively.
+func (api *API) handleIngestSessionByID(response http.ResponseWriter, request *http.Request) {
+	id := strings.TrimPrefix(request.URL.Path, "/api/ingest/session/")
+	if id == "" {
+		writeError(response, http.StatusBadRequest, "session id is required")
+		return
+	}
+
+	switch request.Method {
+	case http.MethodPatch:
+		api.handleIngestSessionChunk(response, request, id)
+	case http.MethodPut:
+		api.handleIngestSessionCommit(response, request, id)
+	default:
+		writeError(response, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// startIngestSessionMonitor periodically prunes expired chunked ingest
+// sessions, independent of whether an OpsEventStore is configured (unlike
+// startDeviceMonitor, which ties its ticker to ops-event persistence).
+func (api *API) startIngestSessionMonitor(ctx context.Context) {
+	if api.opsConfig.MonitorInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(api.opsConfig.MonitorInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				api.pruneIngestSessions(now)
+			}
+		}
+	}()
+}
+
+// pruneIngestSessions deletes expired, uncommitted chunked ingest
+// sessions. It's invoked from startIngestSessionMonitor's ticker loop.
+func (api *API) pruneIngestSessions(now time.Time) {
+	if api.ingestSessionStore == nil {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		pruned, err := api.ingestSessionStore.PruneExpiredIngestSessions(ctx, now)
+		if err != nil {
+			logger.Error("ingest session prune failed", "component", "ingest", "error", err)
+			return
+		}
+		if pruned > 0 {
+			logger.Debug("pruned expired ingest sessions", "component", "ingest", "pruned", pruned)
+		}
+	}()
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func openIngestSessionForTest(t *testing.T, handler http.Handler, deviceID string, total int) (location string, sessionID string) {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodPost, "/api/ingest/session", bytes.NewBufferString(
+		`{"device_id":"`+deviceID+`","total":`+strconv.Itoa(total)+`}`,
+	))
+	request.Header.Set("X-API-Key", "secret")
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, response.Code, response.Body.String())
+	}
+	location = response.Header().Get("Location")
+	sessionID = response.Header().Get("Upload-UUID")
+	if location == "" || sessionID == "" {
+		t.Fatalf("expected Location and Upload-UUID headers, got %q %q", location, sessionID)
+	}
+	return location, sessionID
+}
+
+func sessionETag(t *testing.T, api *API, sessionID string) string {
+	t.Helper()
+
+	session, ok, err := api.ingestSessionStore.LoadIngestSession(context.Background(), sessionID)
+	if err != nil || !ok {
+		t.Fatalf("expected to load session %q: ok=%v err=%v", sessionID, ok, err)
+	}
+	digest, err := json.Marshal(session.Readings)
+	if err != nil {
+		t.Fatalf("failed to marshal session readings: %v", err)
+	}
+	sum := sha256.Sum256(digest)
+	return hex.EncodeToString(sum[:])
+}
+
+func readingJSON(timestamp int64) string {
+	return `{"timestamp":"` + strconv.FormatInt(timestamp, 10) + `","temperature":"22.4","pressure":"101305","humidity":"40.1","oxidised":"1.2","reduced":"1.1","nh3":"0.7","pm1":"2","pm2":"3","pm10":"4"}`
+}
+
+func TestIngestSessionHappyPathCommitsReadings(t *testing.T) {
+	store := &fakeStore{}
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	location, sessionID := openIngestSessionForTest(t, handler, "dev-1", 2)
+
+	chunkBody := "[" + readingJSON(1738886400) + "," + readingJSON(1738886401) + "]"
+	chunkRequest := httptest.NewRequest(http.MethodPatch, location, bytes.NewBufferString(chunkBody))
+	chunkRequest.Header.Set("X-API-Key", "secret")
+	chunkRequest.Header.Set("Content-Range", "readings 0-1/2")
+	chunkResponse := httptest.NewRecorder()
+	handler.ServeHTTP(chunkResponse, chunkRequest)
+
+	if chunkResponse.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, chunkResponse.Code, chunkResponse.Body.String())
+	}
+	if chunkResponse.Header().Get("Range") != "0-2" {
+		t.Fatalf("expected Range 0-2, got %q", chunkResponse.Header().Get("Range"))
+	}
+
+	etag := sessionETag(t, api, sessionID)
+
+	commitRequest := httptest.NewRequest(http.MethodPut, location, nil)
+	commitRequest.Header.Set("X-API-Key", "secret")
+	commitRequest.Header.Set("ETag", etag)
+	commitResponse := httptest.NewRecorder()
+	handler.ServeHTTP(commitResponse, commitRequest)
+
+	if commitResponse.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, commitResponse.Code, commitResponse.Body.String())
+	}
+	if len(store.added) != 2 {
+		t.Fatalf("expected 2 persisted readings, got %d", len(store.added))
+	}
+
+	retryRequest := httptest.NewRequest(http.MethodPut, location, nil)
+	retryRequest.Header.Set("X-API-Key", "secret")
+	retryRequest.Header.Set("ETag", etag)
+	retryResponse := httptest.NewRecorder()
+	handler.ServeHTTP(retryResponse, retryRequest)
+
+	if retryResponse.Code != http.StatusOK {
+		t.Fatalf("expected a repeated commit to be idempotent, got %d", retryResponse.Code)
+	}
+	if len(store.added) != 2 {
+		t.Fatalf("expected the repeated commit not to double-insert, got %d readings", len(store.added))
+	}
+}
+
+func TestIngestSessionMidStreamRetryDoesNotDuplicate(t *testing.T) {
+	store := &fakeStore{}
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	location, sessionID := openIngestSessionForTest(t, handler, "dev-1", 3)
+
+	firstChunk := "[" + readingJSON(1738886400) + "," + readingJSON(1738886401) + "]"
+	firstRequest := httptest.NewRequest(http.MethodPatch, location, bytes.NewBufferString(firstChunk))
+	firstRequest.Header.Set("X-API-Key", "secret")
+	firstRequest.Header.Set("Content-Range", "readings 0-1/3")
+	firstResponse := httptest.NewRecorder()
+	handler.ServeHTTP(firstResponse, firstRequest)
+	if firstResponse.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, firstResponse.Code, firstResponse.Body.String())
+	}
+
+	// Simulate the client never seeing the ack and resending the same
+	// offset 0 reading again before continuing.
+	retryChunk := "[" + readingJSON(1738886400) + "]"
+	retryRequest := httptest.NewRequest(http.MethodPatch, location, bytes.NewBufferString(retryChunk))
+	retryRequest.Header.Set("X-API-Key", "secret")
+	retryRequest.Header.Set("Content-Range", "readings 0-0/3")
+	retryResponse := httptest.NewRecorder()
+	handler.ServeHTTP(retryResponse, retryRequest)
+	if retryResponse.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, retryResponse.Code, retryResponse.Body.String())
+	}
+	if retryResponse.Header().Get("Range") != "0-2" {
+		t.Fatalf("expected watermark to stay at 0-2 after resending an already-received offset, got %q", retryResponse.Header().Get("Range"))
+	}
+
+	finalChunk := "[" + readingJSON(1738886402) + "]"
+	finalRequest := httptest.NewRequest(http.MethodPatch, location, bytes.NewBufferString(finalChunk))
+	finalRequest.Header.Set("X-API-Key", "secret")
+	finalRequest.Header.Set("Content-Range", "readings 2-2/3")
+	finalResponse := httptest.NewRecorder()
+	handler.ServeHTTP(finalResponse, finalRequest)
+	if finalResponse.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, finalResponse.Code, finalResponse.Body.String())
+	}
+	if finalResponse.Header().Get("Range") != "0-3" {
+		t.Fatalf("expected Range 0-3 once every offset is received, got %q", finalResponse.Header().Get("Range"))
+	}
+
+	etag := sessionETag(t, api, sessionID)
+	commitRequest := httptest.NewRequest(http.MethodPut, location, nil)
+	commitRequest.Header.Set("X-API-Key", "secret")
+	commitRequest.Header.Set("ETag", etag)
+	commitResponse := httptest.NewRecorder()
+	handler.ServeHTTP(commitResponse, commitRequest)
+
+	if commitResponse.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, commitResponse.Code, commitResponse.Body.String())
+	}
+	if len(store.added) != 3 {
+		t.Fatalf("expected exactly 3 persisted readings despite the retried chunk, got %d", len(store.added))
+	}
+}
+
+func TestIngestSessionExpiredSessionRejectsChunks(t *testing.T) {
+	store := &fakeStore{}
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	location, sessionID := openIngestSessionForTest(t, handler, "dev-1", 1)
+
+	session, ok, err := api.ingestSessionStore.LoadIngestSession(context.Background(), sessionID)
+	if err != nil || !ok {
+		t.Fatalf("expected to load session: ok=%v err=%v", ok, err)
+	}
+	session.ExpiresAt = time.Now().Add(-time.Minute)
+	if err := api.ingestSessionStore.SaveIngestSession(context.Background(), session); err != nil {
+		t.Fatalf("failed to force-expire session: %v", err)
+	}
+
+	chunkRequest := httptest.NewRequest(http.MethodPatch, location, bytes.NewBufferString("["+readingJSON(1738886400)+"]"))
+	chunkRequest.Header.Set("X-API-Key", "secret")
+	chunkRequest.Header.Set("Content-Range", "readings 0-0/1")
+	chunkResponse := httptest.NewRecorder()
+	handler.ServeHTTP(chunkResponse, chunkRequest)
+
+	if chunkResponse.Code != http.StatusGone {
+		t.Fatalf("expected status %d for an expired session, got %d: %s", http.StatusGone, chunkResponse.Code, chunkResponse.Body.String())
+	}
+}
@@ -2,10 +2,15 @@ package server
 
 import (
 	"context"
-	"log"
-	"math"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/fsnotify/fsnotify"
 )
 
 type InsightsSnapshot struct {
@@ -37,18 +42,41 @@ type InsightsSchedulerConfig struct {
 	PM2DeltaTrigger  float64
 	PM10DeltaTrigger float64
 	AnalyzeTimeout   time.Duration
+
+	// AnomalyAlpha, AnomalyZScoreThreshold, AnomalyResidualWindow and
+	// AnomalyWarmupSamples configure the EWMA/MAD anomaly detector that
+	// augments the PM threshold/delta rules above. See AnomalyDetectorConfig.
+	AnomalyAlpha           float64
+	AnomalyZScoreThreshold float64
+	AnomalyResidualWindow  int
+	AnomalyWarmupSamples   int
 }
 
 func DefaultInsightsSchedulerConfig() InsightsSchedulerConfig {
+	anomalyDefaults := DefaultAnomalyDetectorConfig()
 	return InsightsSchedulerConfig{
-		AnalysisLimit:    900,
-		RefreshInterval:  time.Hour,
-		EventMinInterval: 10 * time.Minute,
-		PM2Threshold:     8.0,
-		PM10Threshold:    30.0,
-		PM2DeltaTrigger:  3.0,
-		PM10DeltaTrigger: 10.0,
-		AnalyzeTimeout:   15 * time.Second,
+		AnalysisLimit:          900,
+		RefreshInterval:        time.Hour,
+		EventMinInterval:       10 * time.Minute,
+		PM2Threshold:           8.0,
+		PM10Threshold:          30.0,
+		PM2DeltaTrigger:        3.0,
+		PM10DeltaTrigger:       10.0,
+		AnalyzeTimeout:         15 * time.Second,
+		AnomalyAlpha:           anomalyDefaults.Alpha,
+		AnomalyZScoreThreshold: anomalyDefaults.ZScoreThreshold,
+		AnomalyResidualWindow:  anomalyDefaults.ResidualWindow,
+		AnomalyWarmupSamples:   anomalyDefaults.WarmupSamples,
+	}
+}
+
+// anomalyDetectorConfig extracts the AnomalyDetectorConfig embedded in cfg.
+func (cfg InsightsSchedulerConfig) anomalyDetectorConfig() AnomalyDetectorConfig {
+	return AnomalyDetectorConfig{
+		Alpha:           cfg.AnomalyAlpha,
+		ZScoreThreshold: cfg.AnomalyZScoreThreshold,
+		ResidualWindow:  cfg.AnomalyResidualWindow,
+		WarmupSamples:   cfg.AnomalyWarmupSamples,
 	}
 }
 
@@ -57,6 +85,12 @@ type InsightsScheduler struct {
 	snapshotStore InsightsSnapshotStore
 	analyzer      AlertAnalyzer
 	config        InsightsSchedulerConfig
+	anomaly       *anomalyDetector
+	log           *slog.Logger
+
+	// onSnapshot, if set, is called with every freshly recomputed snapshot,
+	// used to forward insights to external subscription sinks.
+	onSnapshot func(InsightsSnapshot)
 
 	mu               sync.RWMutex
 	snapshot         InsightsSnapshot
@@ -67,11 +101,11 @@ type InsightsScheduler struct {
 	pending          bool
 }
 
-func NewInsightsScheduler(
-	store Store,
-	analyzer AlertAnalyzer,
-	config InsightsSchedulerConfig,
-) *InsightsScheduler {
+// normalizeInsightsSchedulerConfig fills in any unset/invalid field of cfg
+// with its DefaultInsightsSchedulerConfig counterpart. It's shared by
+// NewInsightsScheduler and ReloadConfig so a hot-reloaded config file is
+// validated exactly the same way a config passed at construction is.
+func normalizeInsightsSchedulerConfig(config InsightsSchedulerConfig) InsightsSchedulerConfig {
 	cfg := config
 	defaults := DefaultInsightsSchedulerConfig()
 
@@ -99,11 +133,34 @@ func NewInsightsScheduler(
 	if cfg.AnalyzeTimeout <= 0 {
 		cfg.AnalyzeTimeout = defaults.AnalyzeTimeout
 	}
+	if cfg.AnomalyAlpha <= 0 || cfg.AnomalyAlpha > 1 {
+		cfg.AnomalyAlpha = defaults.AnomalyAlpha
+	}
+	if cfg.AnomalyZScoreThreshold <= 0 {
+		cfg.AnomalyZScoreThreshold = defaults.AnomalyZScoreThreshold
+	}
+	if cfg.AnomalyResidualWindow < 2 {
+		cfg.AnomalyResidualWindow = defaults.AnomalyResidualWindow
+	}
+	if cfg.AnomalyWarmupSamples < 1 {
+		cfg.AnomalyWarmupSamples = defaults.AnomalyWarmupSamples
+	}
 
+	return cfg
+}
+
+func NewInsightsScheduler(
+	store Store,
+	analyzer AlertAnalyzer,
+	config InsightsSchedulerConfig,
+) *InsightsScheduler {
+	normalizedConfig := normalizeInsightsSchedulerConfig(config)
 	return &InsightsScheduler{
 		store:    store,
 		analyzer: analyzer,
-		config:   cfg,
+		config:   normalizedConfig,
+		anomaly:  newAnomalyDetector(normalizedConfig.anomalyDetectorConfig()),
+		log:      logger.With("component", "insights"),
 		snapshotStore: func() InsightsSnapshotStore {
 			if snapshotStore, ok := store.(InsightsSnapshotStore); ok {
 				return snapshotStore
@@ -142,7 +199,7 @@ func (scheduler *InsightsScheduler) loadSnapshotFromStore() {
 
 	snapshot, ok, err := scheduler.snapshotStore.LatestInsightsSnapshot(loadCtx)
 	if err != nil {
-		log.Printf("insights snapshot load failed: %v", err)
+		scheduler.log.Error("insights snapshot load failed", "error", err)
 		return
 	}
 	if !ok {
@@ -154,11 +211,7 @@ func (scheduler *InsightsScheduler) loadSnapshotFromStore() {
 	scheduler.hasSnapshot = true
 	scheduler.mu.Unlock()
 
-	log.Printf(
-		"insights snapshot restored source=%s generated_at=%d",
-		snapshot.Source,
-		snapshot.GeneratedAt,
-	)
+	scheduler.log.Info("insights snapshot restored", "source", snapshot.Source, "generated_at", snapshot.GeneratedAt)
 }
 
 func (scheduler *InsightsScheduler) Snapshot(limit int) (InsightsSnapshot, bool) {
@@ -179,6 +232,186 @@ func (scheduler *InsightsScheduler) Snapshot(limit int) (InsightsSnapshot, bool)
 	return snapshot, true
 }
 
+// ReloadConfig atomically swaps the scheduler's thresholds, refresh
+// interval and analyze timeout for cfg, re-validating it against
+// defaults the same way NewInsightsScheduler does. It's safe to call
+// while shouldTriggerFromReading/recompute are running concurrently.
+func (scheduler *InsightsScheduler) ReloadConfig(cfg InsightsSchedulerConfig) {
+	normalized := normalizeInsightsSchedulerConfig(cfg)
+
+	scheduler.mu.Lock()
+	previous := scheduler.config
+	scheduler.config = normalized
+	scheduler.mu.Unlock()
+
+	scheduler.anomaly.updateConfig(normalized.anomalyDetectorConfig())
+
+	configReloadTotal.WithLabelValues("success").Inc()
+	scheduler.log.Info("insights config reloaded", "diff", diffInsightsSchedulerConfig(previous, normalized))
+}
+
+// diffInsightsSchedulerConfig renders the fields that changed between
+// previous and next for a reload log line, e.g. "pm2_threshold=8->10".
+func diffInsightsSchedulerConfig(previous, next InsightsSchedulerConfig) string {
+	var changes []string
+	if previous.AnalysisLimit != next.AnalysisLimit {
+		changes = append(changes, fmt.Sprintf("analysis_limit=%d->%d", previous.AnalysisLimit, next.AnalysisLimit))
+	}
+	if previous.RefreshInterval != next.RefreshInterval {
+		changes = append(changes, fmt.Sprintf("refresh_interval=%s->%s", previous.RefreshInterval, next.RefreshInterval))
+	}
+	if previous.EventMinInterval != next.EventMinInterval {
+		changes = append(changes, fmt.Sprintf("event_min_interval=%s->%s", previous.EventMinInterval, next.EventMinInterval))
+	}
+	if previous.PM2Threshold != next.PM2Threshold {
+		changes = append(changes, fmt.Sprintf("pm2_threshold=%g->%g", previous.PM2Threshold, next.PM2Threshold))
+	}
+	if previous.PM10Threshold != next.PM10Threshold {
+		changes = append(changes, fmt.Sprintf("pm10_threshold=%g->%g", previous.PM10Threshold, next.PM10Threshold))
+	}
+	if previous.PM2DeltaTrigger != next.PM2DeltaTrigger {
+		changes = append(changes, fmt.Sprintf("pm2_delta_trigger=%g->%g", previous.PM2DeltaTrigger, next.PM2DeltaTrigger))
+	}
+	if previous.PM10DeltaTrigger != next.PM10DeltaTrigger {
+		changes = append(changes, fmt.Sprintf("pm10_delta_trigger=%g->%g", previous.PM10DeltaTrigger, next.PM10DeltaTrigger))
+	}
+	if previous.AnalyzeTimeout != next.AnalyzeTimeout {
+		changes = append(changes, fmt.Sprintf("analyze_timeout=%s->%s", previous.AnalyzeTimeout, next.AnalyzeTimeout))
+	}
+	if previous.AnomalyAlpha != next.AnomalyAlpha {
+		changes = append(changes, fmt.Sprintf("anomaly_alpha=%g->%g", previous.AnomalyAlpha, next.AnomalyAlpha))
+	}
+	if previous.AnomalyZScoreThreshold != next.AnomalyZScoreThreshold {
+		changes = append(changes, fmt.Sprintf("anomaly_z_score_threshold=%g->%g", previous.AnomalyZScoreThreshold, next.AnomalyZScoreThreshold))
+	}
+	if previous.AnomalyResidualWindow != next.AnomalyResidualWindow {
+		changes = append(changes, fmt.Sprintf("anomaly_residual_window=%d->%d", previous.AnomalyResidualWindow, next.AnomalyResidualWindow))
+	}
+	if previous.AnomalyWarmupSamples != next.AnomalyWarmupSamples {
+		changes = append(changes, fmt.Sprintf("anomaly_warmup_samples=%d->%d", previous.AnomalyWarmupSamples, next.AnomalyWarmupSamples))
+	}
+
+	if len(changes) == 0 {
+		return "no fields changed"
+	}
+	return strings.Join(changes, " ")
+}
+
+// WatchConfigFile watches the JSON file at path and calls ReloadConfig
+// with its contents whenever it changes, so PM thresholds can be tuned
+// on a deployed device without restarting the HTTP server or losing the
+// in-memory reading buffer. It handles editors (vim in particular) that
+// save by renaming the old file away and creating a new one in its
+// place, by re-adding the watch whenever it's lost.
+func (scheduler *InsightsScheduler) WatchConfigFile(ctx context.Context, path string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("create insights config watcher: %w", err)
+	}
+
+	if err := watcher.Add(path); err != nil {
+		watcher.Close()
+		return fmt.Errorf("watch insights config file %s: %w", path, err)
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+					time.Sleep(50 * time.Millisecond)
+					if err := watcher.Add(path); err != nil {
+						scheduler.log.Error("insights config watch lost", "path", path, "error", err)
+						continue
+					}
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
+					scheduler.reloadConfigFile(path)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				scheduler.log.Error("insights config watcher error", "path", path, "error", err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (scheduler *InsightsScheduler) reloadConfigFile(path string) {
+	cfg, err := loadInsightsSchedulerConfigFile(path)
+	if err != nil {
+		configReloadTotal.WithLabelValues("failure").Inc()
+		scheduler.log.Error("insights config reload failed", "path", path, "error", err)
+		return
+	}
+	scheduler.ReloadConfig(cfg)
+}
+
+// insightsSchedulerConfigFile mirrors InsightsSchedulerConfig for JSON
+// decoding, with durations as Go duration strings (e.g. "10m") so the
+// file stays hand-editable. Fields omitted from the file fall back to
+// DefaultInsightsSchedulerConfig, same as an unset InsightsSchedulerConfig
+// passed to NewInsightsScheduler.
+type insightsSchedulerConfigFile struct {
+	AnalysisLimit          int     `json:"analysis_limit"`
+	RefreshInterval        string  `json:"refresh_interval"`
+	EventMinInterval       string  `json:"event_min_interval"`
+	PM2Threshold           float64 `json:"pm2_threshold"`
+	PM10Threshold          float64 `json:"pm10_threshold"`
+	PM2DeltaTrigger        float64 `json:"pm2_delta_trigger"`
+	PM10DeltaTrigger       float64 `json:"pm10_delta_trigger"`
+	AnalyzeTimeout         string  `json:"analyze_timeout"`
+	AnomalyAlpha           float64 `json:"anomaly_alpha"`
+	AnomalyZScoreThreshold float64 `json:"anomaly_z_score_threshold"`
+	AnomalyResidualWindow  int     `json:"anomaly_residual_window"`
+	AnomalyWarmupSamples   int     `json:"anomaly_warmup_samples"`
+}
+
+func loadInsightsSchedulerConfigFile(path string) (InsightsSchedulerConfig, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return InsightsSchedulerConfig{}, fmt.Errorf("read config file: %w", err)
+	}
+
+	var file insightsSchedulerConfigFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return InsightsSchedulerConfig{}, fmt.Errorf("parse config file: %w", err)
+	}
+
+	cfg := InsightsSchedulerConfig{
+		AnalysisLimit:          file.AnalysisLimit,
+		PM2Threshold:           file.PM2Threshold,
+		PM10Threshold:          file.PM10Threshold,
+		PM2DeltaTrigger:        file.PM2DeltaTrigger,
+		PM10DeltaTrigger:       file.PM10DeltaTrigger,
+		AnomalyAlpha:           file.AnomalyAlpha,
+		AnomalyZScoreThreshold: file.AnomalyZScoreThreshold,
+		AnomalyResidualWindow:  file.AnomalyResidualWindow,
+		AnomalyWarmupSamples:   file.AnomalyWarmupSamples,
+	}
+	if file.RefreshInterval != "" {
+		cfg.RefreshInterval, _ = time.ParseDuration(file.RefreshInterval)
+	}
+	if file.EventMinInterval != "" {
+		cfg.EventMinInterval, _ = time.ParseDuration(file.EventMinInterval)
+	}
+	if file.AnalyzeTimeout != "" {
+		cfg.AnalyzeTimeout, _ = time.ParseDuration(file.AnalyzeTimeout)
+	}
+
+	return cfg, nil
+}
+
 func (scheduler *InsightsScheduler) OnReading(reading SensorReading) {
 	if !scheduler.shouldTriggerFromReading(reading) {
 		return
@@ -195,9 +428,23 @@ func (scheduler *InsightsScheduler) OnBatch(readings []SensorReading) {
 	}
 }
 
+// shouldTriggerFromReading decides whether reading is surprising enough to
+// warrant an insights recompute. It combines the original PM
+// threshold-crossing/delta rules with an EWMA + robust z-score anomaly
+// detector (see anomalyDetector) covering PM2, PM10, temperature and
+// humidity; either signal can trigger, and EventMinInterval throttles
+// either kind of trigger the same way.
 func (scheduler *InsightsScheduler) shouldTriggerFromReading(reading SensorReading) bool {
 	now := time.Now()
 
+	// PM2/PM10 anomaly checks are "increase only", mirroring the existing
+	// delta rule: a drop in particulate levels is never itself alarming.
+	pm2Anomalous := scheduler.anomaly.observe("pm2", reading.PM2, true)
+	pm10Anomalous := scheduler.anomaly.observe("pm10", reading.PM10, true)
+	temperatureAnomalous := scheduler.anomaly.observe("temperature", reading.Temperature, false)
+	humidityAnomalous := scheduler.anomaly.observe("humidity", reading.Humidity, false)
+	anomalous := pm2Anomalous || pm10Anomalous || temperatureAnomalous || humidityAnomalous
+
 	scheduler.mu.Lock()
 	defer scheduler.mu.Unlock()
 
@@ -216,10 +463,10 @@ func (scheduler *InsightsScheduler) shouldTriggerFromReading(reading SensorReadi
 	pm10Crossed := previous.PM10 < scheduler.config.PM10Threshold &&
 		reading.PM10 >= scheduler.config.PM10Threshold
 
-	pm2Jumped := math.Abs(reading.PM2-previous.PM2) >= scheduler.config.PM2DeltaTrigger
-	pm10Jumped := math.Abs(reading.PM10-previous.PM10) >= scheduler.config.PM10DeltaTrigger
+	pm2Jumped := reading.PM2-previous.PM2 >= scheduler.config.PM2DeltaTrigger
+	pm10Jumped := reading.PM10-previous.PM10 >= scheduler.config.PM10DeltaTrigger
 
-	if !(pm2Crossed || pm10Crossed || pm2Jumped || pm10Jumped) {
+	if !(pm2Crossed || pm10Crossed || pm2Jumped || pm10Jumped || anomalous) {
 		return false
 	}
 
@@ -232,6 +479,13 @@ func (scheduler *InsightsScheduler) shouldTriggerFromReading(reading SensorReadi
 	return true
 }
 
+// AnomalyStats exposes the anomaly detector's current per-metric running
+// statistics (EWMA mean, MAD, last z-score, warmup status), so a
+// diagnostic endpoint can help operators tune AnomalyDetectorConfig.
+func (scheduler *InsightsScheduler) AnomalyStats() map[string]MetricAnomalyStats {
+	return scheduler.anomaly.stats()
+}
+
 func (scheduler *InsightsScheduler) requestRecompute(trigger string) {
 	scheduler.mu.Lock()
 	if scheduler.running {
@@ -264,18 +518,31 @@ func (scheduler *InsightsScheduler) recomputeLoop(trigger string) {
 }
 
 func (scheduler *InsightsScheduler) recompute(trigger string) {
-	ctx, cancel := context.WithTimeout(context.Background(), scheduler.config.AnalyzeTimeout)
+	requestID := fmt.Sprintf("ai-%d", time.Now().Unix())
+	ctx, cancel := context.WithTimeout(WithRequestID(context.Background(), requestID), scheduler.config.AnalyzeTimeout)
 	defer cancel()
+	log := scheduler.log.With("requestID", requestID)
+
+	start := time.Now()
+	result := "ok"
+	defer func() {
+		insightsRecomputeDuration.Observe(time.Since(start).Seconds())
+		insightsRecomputeTotal.WithLabelValues(trigger, result).Inc()
+	}()
 
 	readings, err := scheduler.store.Latest(ctx, scheduler.config.AnalysisLimit)
 	if err != nil {
-		log.Printf("insights recompute failed to load readings: %v", err)
+		insightsStoreLatestFailuresTotal.Inc()
+		result = "error"
+		log.Error("insights recompute failed to load readings", "trigger", trigger, "error", err)
 		return
 	}
 
 	alerts, err := scheduler.analyzer.Analyze(ctx, readings)
 	if err != nil {
-		log.Printf("insights recompute failed to analyze readings: %v", err)
+		insightsAnalyzeFailuresTotal.Inc()
+		result = "error"
+		log.Error("insights recompute failed to analyze readings", "trigger", trigger, "error", err)
 		return
 	}
 
@@ -293,18 +560,24 @@ func (scheduler *InsightsScheduler) recompute(trigger string) {
 	scheduler.hasSnapshot = true
 	scheduler.mu.Unlock()
 
+	insightsLastGeneratedTimestamp.Set(float64(snapshot.GeneratedAt) / 1000)
+
 	if scheduler.snapshotStore != nil {
-		saveCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		saveCtx, cancel := context.WithTimeout(WithRequestID(context.Background(), requestID), 5*time.Second)
 		if err := scheduler.snapshotStore.SaveInsightsSnapshot(saveCtx, snapshot); err != nil {
-			log.Printf("insights snapshot persist failed: %v", err)
+			log.Error("insights snapshot persist failed", "error", err)
 		}
 		cancel()
 	}
 
-	log.Printf(
-		"insights recomputed trigger=%s samples=%d insights=%d",
-		trigger,
-		len(readings),
-		len(alerts),
+	if scheduler.onSnapshot != nil {
+		scheduler.onSnapshot(snapshot)
+	}
+
+	log.Info("insights recomputed",
+		"trigger", trigger,
+		"samples", len(readings),
+		"insights", len(alerts),
+		"duration_ms", time.Since(start).Milliseconds(),
 	)
 }
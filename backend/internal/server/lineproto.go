@@ -0,0 +1,196 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// allowedLineProtocolMeasurements restricts which measurement names the
+// line-protocol ingest endpoint accepts, so a misconfigured Telegraf
+// output plugin can't silently mix unrelated series into the reading
+// stream.
+var allowedLineProtocolMeasurements = map[string]struct{}{
+	"enviro": {},
+}
+
+var allowedLineProtocolFields = map[string]struct{}{
+	"temperature": {},
+	"pressure":    {},
+	"humidity":    {},
+	"oxidised":    {},
+	"reduced":     {},
+	"nh3":         {},
+	"pm1":         {},
+	"pm2":         {},
+	"pm10":        {},
+}
+
+// linePrecisionDivisors maps the precisions InfluxDB's /write endpoint
+// accepts to the divisor that converts a line-protocol timestamp down to
+// the unix-seconds precision SensorReading.Timestamp stores.
+var linePrecisionDivisors = map[string]int64{
+	"ns": 1_000_000_000,
+	"us": 1_000_000,
+	"ms": 1_000,
+	"s":  1,
+}
+
+// DefaultLineProtocolPrecision is the timestamp precision assumed when a
+// line-protocol ingest request doesn't specify ?precision=, matching
+// InfluxDB's own /write default.
+const DefaultLineProtocolPrecision = "ns"
+
+// DecodeLineProtocolBatch parses newline-separated InfluxDB line protocol
+// points (e.g. `enviro temperature=22.3,pm2=2.0 1700000000000000000`) into
+// SensorReadings. Tag sets are accepted but otherwise ignored; the
+// measurement name is checked against an allow-list and the field set is
+// mapped onto SensorReading columns the same way decodeReadingPayload maps
+// JSON fields. precision (one of "ns", "us", "ms", "s") selects how
+// timestamps are interpreted before being converted to the unix-seconds
+// precision the store uses.
+func DecodeLineProtocolBatch(raw []byte, maxBatchSize int, precision string) ([]SensorReading, error) {
+	timestampDivisor, ok := linePrecisionDivisors[precision]
+	if !ok {
+		return nil, fmt.Errorf("unsupported precision: %s", precision)
+	}
+
+	readings := make([]SensorReading, 0, 64)
+
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		reading, err := decodeLineProtocolPoint(line, timestampDivisor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid line %d: %w", lineNumber, err)
+		}
+
+		readings = append(readings, reading)
+		if len(readings) > maxBatchSize {
+			return nil, fmt.Errorf("batch exceeds max size of %d", maxBatchSize)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(readings) == 0 {
+		return nil, fmt.Errorf("batch must include at least one reading")
+	}
+
+	return readings, nil
+}
+
+func decodeLineProtocolPoint(line string, timestampDivisor int64) (SensorReading, error) {
+	identifier, remainder, found := cutUnescaped(line, ' ')
+	if !found {
+		return SensorReading{}, fmt.Errorf("missing field set")
+	}
+
+	fieldSet, rawTimestamp, hasTimestamp := cutUnescaped(remainder, ' ')
+	if !hasTimestamp {
+		fieldSet = remainder
+	}
+
+	measurement, _, _ := strings.Cut(identifier, ",")
+	if _, allowed := allowedLineProtocolMeasurements[measurement]; !allowed {
+		return SensorReading{}, fmt.Errorf("unknown measurement: %s", measurement)
+	}
+
+	var reading SensorReading
+	if hasTimestamp {
+		rawTimestampValue, err := strconv.ParseInt(strings.TrimSpace(rawTimestamp), 10, 64)
+		if err != nil {
+			return SensorReading{}, fmt.Errorf("invalid timestamp: %w", err)
+		}
+		reading.Timestamp = rawTimestampValue / timestampDivisor
+	}
+
+	for _, fieldPair := range strings.Split(fieldSet, ",") {
+		key, rawValue, found := strings.Cut(fieldPair, "=")
+		if !found {
+			return SensorReading{}, fmt.Errorf("malformed field: %s", fieldPair)
+		}
+
+		if _, allowed := allowedLineProtocolFields[key]; !allowed {
+			return SensorReading{}, fmt.Errorf("unknown field: %s", key)
+		}
+
+		value, err := strconv.ParseFloat(strings.TrimSuffix(rawValue, "i"), 64)
+		if err != nil {
+			return SensorReading{}, fmt.Errorf("invalid value for %s: %w", key, err)
+		}
+
+		switch key {
+		case "temperature":
+			reading.Temperature = value
+		case "pressure":
+			reading.Pressure = value
+		case "humidity":
+			reading.Humidity = value
+		case "oxidised":
+			reading.Oxidised = value
+		case "reduced":
+			reading.Reduced = value
+		case "nh3":
+			reading.Nh3 = value
+		case "pm1":
+			reading.PM1 = value
+		case "pm2":
+			reading.PM2 = value
+		case "pm10":
+			reading.PM10 = value
+		}
+	}
+
+	if reading.Timestamp == 0 {
+		return SensorReading{}, fmt.Errorf("timestamp is required")
+	}
+
+	return reading, nil
+}
+
+// EncodeLineProtocolPoint renders a reading as a single InfluxDB line
+// protocol point under the "enviro" measurement, the inverse of
+// decodeLineProtocolPoint, for pushing readings to a remote Influx /write
+// endpoint.
+func EncodeLineProtocolPoint(reading SensorReading) string {
+	return fmt.Sprintf(
+		"enviro temperature=%s,pressure=%s,humidity=%s,oxidised=%s,reduced=%s,nh3=%s,pm1=%s,pm2=%s,pm10=%s %d",
+		strconv.FormatFloat(reading.Temperature, 'f', -1, 64),
+		strconv.FormatFloat(reading.Pressure, 'f', -1, 64),
+		strconv.FormatFloat(reading.Humidity, 'f', -1, 64),
+		strconv.FormatFloat(reading.Oxidised, 'f', -1, 64),
+		strconv.FormatFloat(reading.Reduced, 'f', -1, 64),
+		strconv.FormatFloat(reading.Nh3, 'f', -1, 64),
+		strconv.FormatFloat(reading.PM1, 'f', -1, 64),
+		strconv.FormatFloat(reading.PM2, 'f', -1, 64),
+		strconv.FormatFloat(reading.PM10, 'f', -1, 64),
+		reading.Timestamp*1_000_000_000,
+	)
+}
+
+// cutUnescaped splits value on the first unescaped occurrence of separator,
+// matching the line protocol escaping rules: a backslash-escaped separator
+// does not split the line.
+func cutUnescaped(value string, separator byte) (string, string, bool) {
+	for index := 0; index < len(value); index++ {
+		switch value[index] {
+		case '\\':
+			index++
+		case separator:
+			return value[:index], value[index+1:], true
+		}
+	}
+	return value, "", false
+}
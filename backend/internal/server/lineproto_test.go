@@ -0,0 +1,82 @@
+package server
+
+import "testing"
+
+func TestDecodeLineProtocolBatchParsesMultipleLines(t *testing.T) {
+	payload := "enviro,device=pi temperature=22.3,pressure=1012.5,humidity=45.1,pm1=1.0,pm2=2.0,pm10=3.0,oxidised=0.1,reduced=0.05,nh3=0.02 1700000000000000000\n" +
+		"enviro temperature=22.4,pressure=1012.6,humidity=45.2,pm1=1.1,pm2=2.1,pm10=3.1,oxidised=0.11,reduced=0.06,nh3=0.03 1700000001000000000\n"
+
+	readings, err := DecodeLineProtocolBatch([]byte(payload), 10, "ns")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(readings) != 2 {
+		t.Fatalf("expected 2 readings, got %d", len(readings))
+	}
+
+	first := readings[0]
+	if first.Timestamp != 1700000000 {
+		t.Fatalf("expected timestamp 1700000000, got %d", first.Timestamp)
+	}
+	if first.Temperature != 22.3 || first.PM2 != 2.0 {
+		t.Fatalf("unexpected field values: %+v", first)
+	}
+}
+
+func TestDecodeLineProtocolBatchRejectsUnknownMeasurement(t *testing.T) {
+	payload := "weather temperature=22.3 1700000000000000000"
+
+	if _, err := DecodeLineProtocolBatch([]byte(payload), 10, "ns"); err == nil {
+		t.Fatal("expected error for unknown measurement")
+	}
+}
+
+func TestDecodeLineProtocolBatchRejectsUnknownField(t *testing.T) {
+	payload := "enviro temperature=22.3,co2=400 1700000000000000000"
+
+	if _, err := DecodeLineProtocolBatch([]byte(payload), 10, "ns"); err == nil {
+		t.Fatal("expected error for unknown field")
+	}
+}
+
+func TestDecodeLineProtocolBatchHonorsPrecision(t *testing.T) {
+	tests := []struct {
+		precision string
+		timestamp string
+		expected  int64
+	}{
+		{"ns", "1700000000000000000", 1700000000},
+		{"us", "1700000000000000", 1700000000},
+		{"ms", "1700000000000", 1700000000},
+		{"s", "1700000000", 1700000000},
+	}
+
+	for _, testCase := range tests {
+		payload := "enviro temperature=22.3 " + testCase.timestamp
+		readings, err := DecodeLineProtocolBatch([]byte(payload), 10, testCase.precision)
+		if err != nil {
+			t.Fatalf("precision %s: unexpected error: %v", testCase.precision, err)
+		}
+		if readings[0].Timestamp != testCase.expected {
+			t.Fatalf("precision %s: expected timestamp %d, got %d", testCase.precision, testCase.expected, readings[0].Timestamp)
+		}
+	}
+}
+
+func TestDecodeLineProtocolBatchRejectsUnsupportedPrecision(t *testing.T) {
+	payload := "enviro temperature=22.3 1700000000"
+	if _, err := DecodeLineProtocolBatch([]byte(payload), 10, "fortnights"); err == nil {
+		t.Fatal("expected error for unsupported precision")
+	}
+}
+
+func TestDecodeLineProtocolBatchRejectsOversizedBatch(t *testing.T) {
+	payload := ""
+	for i := 0; i < 3; i++ {
+		payload += "enviro temperature=22.3 1700000000000000000\n"
+	}
+
+	if _, err := DecodeLineProtocolBatch([]byte(payload), 2, "ns"); err == nil {
+		t.Fatal("expected error for oversized batch")
+	}
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// logLevel backs every slog.Logger in this package, letting operators raise
+// or lower verbosity on a running deployment via PUT /debug/log-level
+// without a restart.
+var logLevel = new(slog.LevelVar)
+
+// logger is the package-wide structured logger. Call sites attach a
+// "component" attribute via logger.With(...) instead of building their own
+// handler, so format and level stay centrally configurable.
+var logger = newLogger("text")
+
+func newLogger(format string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: logLevel}
+
+	var handler slog.Handler
+	if strings.EqualFold(format, "json") {
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	} else {
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	}
+	return slog.New(handler)
+}
+
+// ConfigureLogging sets the package-wide log format ("json" for
+// production, anything else falls back to text for local development)
+// and initial level ("debug", "info", "warn" or "error"). Call it before
+// NewAPI so every component logger picks up the configured handler.
+func ConfigureLogging(format string, level string) error {
+	logger = newLogger(format)
+
+	if strings.TrimSpace(level) == "" {
+		return nil
+	}
+	parsed, err := parseLogLevel(level)
+	if err != nil {
+		return err
+	}
+	logLevel.Set(parsed)
+	return nil
+}
+
+// Logger returns the package-wide structured logger configured by
+// ConfigureLogging, so cmd/server can log its own request-scoped work
+// (the retention worker) through the same handler and level instead of
+// building a separate, unconfigured one.
+func Logger() *slog.Logger {
+	return logger
+}
+
+// parseLogLevel maps the level names accepted by ConfigureLogging and
+// PUT /debug/log-level to their slog.Level.
+func parseLogLevel(raw string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("unknown log level %q", raw)
+	}
+}
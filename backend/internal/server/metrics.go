@@ -0,0 +1,144 @@
+package server
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// promRegistry is a dedicated registry rather than the global default, so
+// tests that construct multiple APIs don't trip duplicate-registration
+// panics and scrapes only ever see enviro_* metrics, not Go runtime noise.
+var promRegistry = prometheus.NewRegistry()
+
+var (
+	insightsRecomputeDuration = promauto.With(promRegistry).NewHistogram(prometheus.HistogramOpts{
+		Name:    "enviro_insights_recompute_duration_seconds",
+		Help:    "Duration of InsightsScheduler.recompute calls.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	insightsRecomputeTotal = promauto.With(promRegistry).NewCounterVec(prometheus.CounterOpts{
+		Name: "enviro_insights_recompute_total",
+		Help: "Count of InsightsScheduler recompute runs by trigger and result.",
+	}, []string{"trigger", "result"})
+
+	insightsLastGeneratedTimestamp = promauto.With(promRegistry).NewGauge(prometheus.GaugeOpts{
+		Name: "enviro_insights_last_generated_timestamp_seconds",
+		Help: "Unix timestamp of the most recently generated InsightsSnapshot.",
+	})
+
+	insightsStoreLatestFailuresTotal = promauto.With(promRegistry).NewCounter(prometheus.CounterOpts{
+		Name: "enviro_insights_store_latest_failures_total",
+		Help: "Count of store.Latest errors encountered during insights recompute.",
+	})
+
+	insightsAnalyzeFailuresTotal = promauto.With(promRegistry).NewCounter(prometheus.CounterOpts{
+		Name: "enviro_insights_analyze_failures_total",
+		Help: "Count of analyzer.Analyze errors encountered during insights recompute.",
+	})
+
+	readingsOutOfOrderTotal = promauto.With(promRegistry).NewCounter(prometheus.CounterOpts{
+		Name: "enviro_readings_out_of_order_total",
+		Help: "Count of ingested readings whose timestamp is older than the previous reading.",
+	})
+
+	readingsDuplicateTimestampTotal = promauto.With(promRegistry).NewCounter(prometheus.CounterOpts{
+		Name: "enviro_readings_duplicate_timestamp_total",
+		Help: "Count of ingested readings sharing a timestamp with the previous reading.",
+	})
+
+	streamSubscribersGauge = promauto.With(promRegistry).NewGauge(prometheus.GaugeOpts{
+		Name: "enviro_stream_subscribers",
+		Help: "Current number of connected /api/stream subscribers.",
+	})
+
+	rateLimitRejectedTotal = promauto.With(promRegistry).NewCounterVec(prometheus.CounterOpts{
+		Name: "enviro_rate_limit_rejected_total",
+		Help: "Count of requests rejected by a requestLimiter, by route.",
+	}, []string{"route"})
+
+	configReloadTotal = promauto.With(promRegistry).NewCounterVec(prometheus.CounterOpts{
+		Name: "enviro_config_reload_total",
+		Help: "Count of InsightsSchedulerConfig hot-reload attempts from a watched config file, by result.",
+	}, []string{"result"})
+
+	analyzerCallsTotal = promauto.With(promRegistry).NewCounterVec(prometheus.CounterOpts{
+		Name: "enviro_analyzer_calls_total",
+		Help: "Count of cachedAlertAnalyzer calls forwarded to the wrapped analyzer, by source and result.",
+	}, []string{"source", "result"})
+
+	analyzerCacheHitsTotal = promauto.With(promRegistry).NewCounterVec(prometheus.CounterOpts{
+		Name: "enviro_analyzer_cache_hits_total",
+		Help: "Count of cachedAlertAnalyzer.Analyze calls served from cache without forwarding, by source.",
+	}, []string{"source"})
+
+	openAIRequestsTotal = promauto.With(promRegistry).NewCounterVec(prometheus.CounterOpts{
+		Name: "enviro_openai_requests_total",
+		Help: "Count of openAIAlertAnalyzer.Analyze calls, by result.",
+	}, []string{"result"})
+
+	openAIRequestDuration = promauto.With(promRegistry).NewHistogram(prometheus.HistogramOpts{
+		Name:    "enviro_openai_request_duration_seconds",
+		Help:    "Duration of openAIAlertAnalyzer.Analyze calls to the OpenAI API.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	alertsBySeverityTotal = promauto.With(promRegistry).NewCounterVec(prometheus.CounterOpts{
+		Name: "enviro_alerts_total",
+		Help: "Count of alerts produced by normalizeAlerts, by severity.",
+	}, []string{"severity"})
+
+	ingestRequestsTotal = promauto.With(promRegistry).NewCounterVec(prometheus.CounterOpts{
+		Name: "enviro_ingest_requests_total",
+		Help: "Count of /api/ingest* requests by caller API-key hash and response status.",
+	}, []string{"key_hash", "status"})
+
+	ingestRequestDuration = promauto.With(promRegistry).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "enviro_ingest_request_duration_seconds",
+		Help:    "Latency of /api/ingest* requests by caller API-key hash and response status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"key_hash", "status"})
+
+	retentionDeletedRowsTotal = promauto.With(promRegistry).NewCounter(prometheus.CounterOpts{
+		Name: "enviro_retention_deleted_rows_total",
+		Help: "Count of rows deleted across all of the retention worker's DeleteOlderThan sweeps.",
+	})
+
+	retentionLastRunTimestamp = promauto.With(promRegistry).NewGauge(prometheus.GaugeOpts{
+		Name: "enviro_retention_last_run_timestamp_seconds",
+		Help: "Unix timestamp of the most recently completed retention worker cleanup pass.",
+	})
+
+	openAICallsTotal = promauto.With(promRegistry).NewCounterVec(prometheus.CounterOpts{
+		Name: "enviro_openai_calls_total",
+		Help: "Count of openAIAlertAnalyzer.Analyze calls by model and outcome.",
+	}, []string{"model", "outcome"})
+
+	openAICallLatency = promauto.With(promRegistry).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "enviro_openai_call_latency_seconds",
+		Help:    "Latency of openAIAlertAnalyzer.Analyze calls by model.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"model"})
+
+	openAITokensTotal = promauto.With(promRegistry).NewCounterVec(prometheus.CounterOpts{
+		Name: "enviro_openai_tokens_total",
+		Help: "Count of OpenAI tokens consumed by openAIAlertAnalyzer, by model and token type (input/output).",
+	}, []string{"model", "type"})
+
+	pgPoolConnsGauge = promauto.With(promRegistry).NewGaugeVec(prometheus.GaugeOpts{
+		Name: "enviro_pg_pool_connections",
+		Help: "pgxpool.Stat() connection counts, by state (total, acquired, idle, max).",
+	}, []string{"state"})
+)
+
+// RecordRetentionRun records the outcome of one retention worker cleanup
+// pass. It's exported because the retention loop that calls it
+// (startRetentionWorker) lives in cmd/server, outside this package.
+func RecordRetentionRun(deletedRows int64, ranAt time.Time) {
+	if deletedRows > 0 {
+		retentionDeletedRowsTotal.Add(float64(deletedRows))
+	}
+	retentionLastRunTimestamp.Set(float64(ranAt.Unix()))
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// WithMetricsRegistry overrides the registry MetricsHandler serves, so
+// tests can scrape a local *prometheus.Registry instead of the package-wide
+// promRegistry that every API instance records metrics against.
+func WithMetricsRegistry(registry *prometheus.Registry) APIOption {
+	return func(api *API) {
+		api.metricsRegistry = registry
+	}
+}
+
+// WithMetricsBearerToken requires the admin metrics listener's requests to
+// carry `Authorization: Bearer <token>`, since METRICS_ADDR is meant to run
+// unauthenticated on a private network but operators may still want it
+// gated when that network isn't fully trusted.
+func WithMetricsBearerToken(token string) APIOption {
+	return func(api *API) {
+		api.metricsBearerToken = token
+	}
+}
+
+// MetricsHandler serves api.metricsRegistry in Prometheus exposition
+// format. It's meant to be mounted on the separate admin listener
+// (METRICS_ADDR) rather than the public API mux, so scraping doesn't share
+// a port or authentication scheme with ingest/read traffic.
+func (api *API) MetricsHandler() http.Handler {
+	handler := promhttp.HandlerFor(api.metricsRegistry, promhttp.HandlerOpts{})
+	if api.metricsBearerToken == "" {
+		return handler
+	}
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		token := strings.TrimPrefix(request.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(api.metricsBearerToken)) != 1 {
+			writeError(response, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		handler.ServeHTTP(response, request)
+	})
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/common/expfmt"
+)
+
+// buildVersion identifies the running binary in the Prometheus exposition
+// output. Override it at build time with:
+//
+//	go build -ldflags "-X envirostation/backend/internal/server.buildVersion=1.2.3"
+var buildVersion = "dev"
+
+// promMetricUnits maps each SensorReading field to the Prometheus metric
+// name its latest value is exposed under.
+var promMetricUnits = map[string]string{
+	"temperature": "enviro_temperature_celsius",
+	"pressure":    "enviro_pressure_pascals",
+	"humidity":    "enviro_humidity_percent",
+	"oxidised":    "enviro_oxidised_ohms",
+	"reduced":     "enviro_reduced_ohms",
+	"nh3":         "enviro_nh3_ppm",
+	"pm1":         "enviro_pm1_ugm3",
+	"pm2":         "enviro_pm2_ugm3",
+	"pm10":        "enviro_pm10_ugm3",
+}
+
+// handleMetricsExposition serves the latest reading and ops-event counters
+// as Prometheus text exposition format, so an existing Prometheus/Grafana
+// stack can scrape the station directly instead of polling /api/readings.
+func (api *API) handleMetricsExposition(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		writeError(response, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !api.authorizeReadRequest(response, request) {
+		return
+	}
+
+	var builder strings.Builder
+
+	fmt.Fprintf(&builder, "enviro_build_info{version=%q} 1\n", buildVersion)
+
+	api.opsMu.Lock()
+	deviceConnected := api.deviceState(defaultDeviceID).connected
+	api.opsMu.Unlock()
+
+	if deviceConnected {
+		builder.WriteString("enviro_device_connected 1\n")
+	} else {
+		builder.WriteString("enviro_device_connected 0\n")
+	}
+
+	latest, err := api.store.Latest(request.Context(), 1)
+	if err != nil {
+		writeError(response, http.StatusInternalServerError, "failed to read data")
+		return
+	}
+	if len(latest) > 0 {
+		reading := latest[len(latest)-1]
+		for _, metric := range queryMetricKeys {
+			fmt.Fprintf(&builder, "%s %g\n", promMetricUnits[metric], queryMetricAccessors[metric](reading))
+		}
+		fmt.Fprintf(&builder, "enviro_last_reading_timestamp_seconds %d\n", reading.Timestamp)
+	}
+
+	if api.opsEventStore != nil {
+		if counter, ok := api.opsEventStore.(opsEventCounter); ok {
+			counts, countsErr := counter.OpsEventCounts(request.Context())
+			if countsErr == nil {
+				kinds := make([]string, 0, len(counts))
+				for kind := range counts {
+					kinds = append(kinds, kind)
+				}
+				sort.Strings(kinds)
+				for _, kind := range kinds {
+					fmt.Fprintf(&builder, "enviro_ops_events_total{kind=%q} %d\n", kind, counts[kind])
+				}
+			}
+		}
+	}
+
+	if api.subscriptions != nil {
+		names := make([]string, 0)
+		sinkMetrics := api.subscriptions.subscriptionMetrics()
+		for name := range sinkMetrics {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			counts := sinkMetrics[name]
+			fmt.Fprintf(&builder, "enviro_subscription_dropped_total{sink=%q} %d\n", name, counts[0])
+			fmt.Fprintf(&builder, "enviro_subscription_failed_total{sink=%q} %d\n", name, counts[1])
+		}
+	}
+
+	writeClientGolangMetrics(&builder)
+
+	response.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	response.WriteHeader(http.StatusOK)
+	_, _ = response.Write([]byte(builder.String()))
+}
+
+// writeClientGolangMetrics appends the enviro_* metrics registered through
+// client_golang (insights recompute timing/results, ingest ordering
+// anomalies, stream subscriber count, rate-limit rejections) to builder, in
+// the same Prometheus text exposition format as the hand-rolled metrics
+// above it.
+func writeClientGolangMetrics(builder *strings.Builder) {
+	families, err := promRegistry.Gather()
+	if err != nil {
+		return
+	}
+
+	encoder := expfmt.NewEncoder(builder, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, family := range families {
+		_ = encoder.Encode(family)
+	}
+}
@@ -0,0 +1,232 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// mqttFieldTopics maps each SensorReading field to the subtopic it is
+// published under, so a home-automation hub can subscribe to individual
+// measurements (e.g. "enviro/station-1/pm2") instead of parsing a JSON blob.
+var mqttFieldTopics = map[string]func(SensorReading) float64{
+	"temperature": func(reading SensorReading) float64 { return reading.Temperature },
+	"pressure":    func(reading SensorReading) float64 { return reading.Pressure },
+	"humidity":    func(reading SensorReading) float64 { return reading.Humidity },
+	"oxidised":    func(reading SensorReading) float64 { return reading.Oxidised },
+	"reduced":     func(reading SensorReading) float64 { return reading.Reduced },
+	"nh3":         func(reading SensorReading) float64 { return reading.Nh3 },
+	"pm1":         func(reading SensorReading) float64 { return reading.PM1 },
+	"pm2":         func(reading SensorReading) float64 { return reading.PM2 },
+	"pm10":        func(reading SensorReading) float64 { return reading.PM10 },
+}
+
+// mqttFieldOrder fixes the publish order so output and tests are
+// deterministic rather than depending on map iteration order.
+var mqttFieldOrder = []string{
+	"temperature", "pressure", "humidity", "oxidised", "reduced", "nh3", "pm1", "pm2", "pm10",
+}
+
+// MQTTSubscriber publishes each reading field to its own topic under a base
+// topic, plus the raw InsightsSnapshot JSON to "<base>/insights", using a
+// minimal hand-rolled MQTT 3.1.1 client so the station doesn't need a
+// broker-client dependency just to publish.
+type MQTTSubscriber struct {
+	broker    string
+	clientID  string
+	baseTopic string
+	qos       byte
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewMQTTSubscriber returns a subscriber that connects to an MQTT broker at
+// broker (host:port) and publishes under baseTopic. qos must be 0 or 1;
+// QoS 2 is not supported by this minimal client and is treated as 1.
+func NewMQTTSubscriber(broker string, clientID string, baseTopic string, qos byte) *MQTTSubscriber {
+	if qos > 1 {
+		qos = 1
+	}
+	return &MQTTSubscriber{
+		broker:    broker,
+		clientID:  clientID,
+		baseTopic: baseTopic,
+		qos:       qos,
+	}
+}
+
+func (subscriber *MQTTSubscriber) Name() string {
+	return "mqtt:" + subscriber.broker + subscriber.baseTopic
+}
+
+func (subscriber *MQTTSubscriber) Publish(ctx context.Context, reading SensorReading) error {
+	for _, field := range mqttFieldOrder {
+		value := mqttFieldTopics[field](reading)
+		topic := subscriber.baseTopic + "/" + field
+		payload := strconv.FormatFloat(value, 'f', -1, 64)
+		if err := subscriber.publish(ctx, topic, []byte(payload)); err != nil {
+			return fmt.Errorf("publish %s: %w", field, err)
+		}
+	}
+	return nil
+}
+
+func (subscriber *MQTTSubscriber) PublishInsights(ctx context.Context, snapshot InsightsSnapshot) error {
+	payload, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("marshal snapshot: %w", err)
+	}
+	return subscriber.publish(ctx, subscriber.baseTopic+"/insights", payload)
+}
+
+// publish sends one PUBLISH packet, connecting (or reconnecting) first if
+// necessary. The connection is kept open across calls and torn down on any
+// error so the next publish reconnects rather than writing to a dead socket.
+func (subscriber *MQTTSubscriber) publish(ctx context.Context, topic string, payload []byte) error {
+	subscriber.mu.Lock()
+	defer subscriber.mu.Unlock()
+
+	if subscriber.conn == nil {
+		conn, err := subscriber.connect(ctx)
+		if err != nil {
+			return err
+		}
+		subscriber.conn = conn
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = subscriber.conn.SetWriteDeadline(deadline)
+	} else {
+		_ = subscriber.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	}
+
+	if _, err := subscriber.conn.Write(encodeMQTTPublish(topic, payload, subscriber.qos)); err != nil {
+		subscriber.conn.Close()
+		subscriber.conn = nil
+		return fmt.Errorf("write publish: %w", err)
+	}
+
+	if subscriber.qos > 0 {
+		ack := make([]byte, 4)
+		_ = subscriber.conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+		if _, err := readFull(subscriber.conn, ack); err != nil {
+			subscriber.conn.Close()
+			subscriber.conn = nil
+			return fmt.Errorf("read puback: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (subscriber *MQTTSubscriber) connect(ctx context.Context) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: 10 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", subscriber.broker)
+	if err != nil {
+		return nil, fmt.Errorf("dial broker: %w", err)
+	}
+
+	if _, err := conn.Write(encodeMQTTConnect(subscriber.clientID)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("write connect: %w", err)
+	}
+
+	ack := make([]byte, 4)
+	_ = conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	if _, err := readFull(conn, ack); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("read connack: %w", err)
+	}
+	if ack[0]>>4 != mqttPacketConnAck || ack[3] != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("connect refused, return code %d", ack[3])
+	}
+
+	return conn, nil
+}
+
+const (
+	mqttPacketConnect = 1
+	mqttPacketConnAck = 2
+	mqttPacketPublish = 3
+)
+
+// encodeMQTTConnect builds a minimal MQTT 3.1.1 CONNECT packet with a clean
+// session and no credentials, suitable for a publish-only client.
+func encodeMQTTConnect(clientID string) []byte {
+	var variableHeader bytes.Buffer
+	writeMQTTString(&variableHeader, "MQTT")
+	variableHeader.WriteByte(4)    // protocol level 4 = MQTT 3.1.1
+	variableHeader.WriteByte(0x02) // connect flags: clean session
+	variableHeader.Write([]byte{0, 60})
+
+	var payload bytes.Buffer
+	writeMQTTString(&payload, clientID)
+
+	var packet bytes.Buffer
+	packet.WriteByte(mqttPacketConnect << 4)
+	writeMQTTRemainingLength(&packet, variableHeader.Len()+payload.Len())
+	packet.Write(variableHeader.Bytes())
+	packet.Write(payload.Bytes())
+	return packet.Bytes()
+}
+
+// encodeMQTTPublish builds an MQTT PUBLISH packet. For qos 1 the packet
+// identifier is fixed at 1 since publishes are sent one at a time and
+// acknowledged before the next is written.
+func encodeMQTTPublish(topic string, body []byte, qos byte) []byte {
+	var variableHeader bytes.Buffer
+	writeMQTTString(&variableHeader, topic)
+	if qos > 0 {
+		variableHeader.Write([]byte{0, 1})
+	}
+
+	var packet bytes.Buffer
+	packet.WriteByte((mqttPacketPublish << 4) | (qos << 1))
+	writeMQTTRemainingLength(&packet, variableHeader.Len()+len(body))
+	packet.Write(variableHeader.Bytes())
+	packet.Write(body)
+	return packet.Bytes()
+}
+
+func writeMQTTString(buffer *bytes.Buffer, value string) {
+	var length [2]byte
+	binary.BigEndian.PutUint16(length[:], uint16(len(value)))
+	buffer.Write(length[:])
+	buffer.WriteString(value)
+}
+
+// writeMQTTRemainingLength encodes length using the MQTT variable-length
+// integer scheme (7 bits per byte, high bit set while more bytes follow).
+func writeMQTTRemainingLength(buffer *bytes.Buffer, length int) {
+	for {
+		encodedByte := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			encodedByte |= 0x80
+		}
+		buffer.WriteByte(encodedByte)
+		if length == 0 {
+			return
+		}
+	}
+}
+
+func readFull(conn net.Conn, buffer []byte) (int, error) {
+	total := 0
+	for total < len(buffer) {
+		n, err := conn.Read(buffer[total:])
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+)
+
+// TLSConfig configures the optional mutual-TLS listener: how strictly
+// client certificates are required (ClientAuth, one of the standard
+// tls.ClientAuthType values), which CAs sign them (ClientCAs), and which
+// certificate subjects are allowed to authenticate as a device in place
+// of an X-API-Key (AllowedCommonNames, AllowedOUs).
+type TLSConfig struct {
+	// Certificates is the server's own identity, presented during the TLS
+	// handshake. Required for API.Listen to serve TLS at all.
+	Certificates       []tls.Certificate
+	ClientAuth         tls.ClientAuthType
+	ClientCAs          *x509.CertPool
+	AllowedCommonNames []string
+	AllowedOUs         []string
+}
+
+// WithTLS enables mutual TLS. A request presenting a client certificate
+// (already verified against ClientCAs by the TLS handshake, per
+// ClientAuth) whose subject CommonName or OrganizationalUnit matches an
+// allow-list entry bypasses the X-API-Key check on /api/ingest* entirely,
+// attributing the reading to a device derived from the certificate
+// subject instead. A request without a matching certificate falls back
+// to the existing X-API-Key/device-registry path, so mTLS can be adopted
+// by some devices without breaking the rest of the fleet.
+func WithTLS(cfg TLSConfig) APIOption {
+	return func(api *API) {
+		api.tlsConfig = &cfg
+	}
+}
+
+// StdTLSConfig builds the *tls.Config the production listener should
+// serve with, reflecting the ClientAuth mode and ClientCAs configured via
+// WithTLS. It returns nil when WithTLS was never called, distinguishing
+// "serve plain TLS" from "serve mTLS" for main.go's listener setup.
+func (api *API) StdTLSConfig() *tls.Config {
+	if api.tlsConfig == nil {
+		return nil
+	}
+	return &tls.Config{
+		Certificates: api.tlsConfig.Certificates,
+		ClientAuth:   api.tlsConfig.ClientAuth,
+		ClientCAs:    api.tlsConfig.ClientCAs,
+	}
+}
+
+// authorizeClientCert reports whether request presented a client
+// certificate whose Subject CommonName or an OrganizationalUnit appears
+// in the configured allow-lists, returning a device id derived from the
+// certificate's CommonName when it does. Certificate validity itself is
+// already enforced by the TLS handshake (ClientAuth/ClientCAs); this only
+// decides which verified certificates are also authorized as a device.
+func (api *API) authorizeClientCert(request *http.Request) (deviceID string, ok bool) {
+	if api.tlsConfig == nil || request.TLS == nil || len(request.TLS.PeerCertificates) == 0 {
+		return "", false
+	}
+
+	cert := request.TLS.PeerCertificates[0]
+	if nameAllowed(cert.Subject.CommonName, api.tlsConfig.AllowedCommonNames) {
+		return "cert:" + cert.Subject.CommonName, true
+	}
+	for _, ou := range cert.Subject.OrganizationalUnit {
+		if nameAllowed(ou, api.tlsConfig.AllowedOUs) {
+			return "cert:" + cert.Subject.CommonName, true
+		}
+	}
+	return "", false
+}
+
+func nameAllowed(name string, allowed []string) bool {
+	if name == "" {
+		return false
+	}
+	for _, candidate := range allowed {
+		if candidate == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Listen creates the TCP listener Enviro Station serves from, wrapping it
+// in TLS when WithTLS has configured a TLSConfig. Passing a port of 0
+// (e.g. "127.0.0.1:0") binds an OS-assigned ephemeral port, whose actual
+// address is then available from ListenAddr -- letting tests drive the
+// server without racing a fixed port.
+func (api *API) Listen(addr string) (net.Listener, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig := api.StdTLSConfig(); tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	api.listenAddr = listener.Addr().String()
+	return listener, nil
+}
+
+// ListenAddr returns the address API.Listen actually bound to, including
+// the OS-assigned port when the caller requested an ephemeral one. It is
+// empty until Listen has been called.
+func (api *API) ListenAddr() string {
+	return api.listenAddr
+}
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testCA is a throwaway certificate authority used to sign leaf
+// certificates for the mTLS tests below.
+type testCA struct {
+	cert       *x509.Certificate
+	privateKey *ecdsa.PrivateKey
+	certDER    []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ca key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		t.Fatalf("create ca certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		t.Fatalf("parse ca certificate: %v", err)
+	}
+
+	return &testCA{cert: cert, privateKey: privateKey, certDER: certDER}
+}
+
+func (ca *testCA) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+// issueLeaf signs a client certificate for commonName/organizationalUnit
+// under ca, returning it as a tls.Certificate ready for
+// tls.Config.Certificates.
+func (ca *testCA) issueLeaf(t *testing.T, commonName string, organizationalUnit string) tls.Certificate {
+	t.Helper()
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate leaf key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject: pkix.Name{
+			CommonName:         commonName,
+			OrganizationalUnit: []string{organizationalUnit},
+		},
+		NotBefore:   time.Now().Add(-time.Hour),
+		NotAfter:    time.Now().Add(time.Hour),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &privateKey.PublicKey, ca.privateKey)
+	if err != nil {
+		t.Fatalf("create leaf certificate: %v", err)
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{certDER},
+		PrivateKey:  privateKey,
+	}
+}
+
+func TestMTLSClientAuthMatrix(t *testing.T) {
+	ca := newTestCA(t)
+	allowedClientCert := ca.issueLeaf(t, "roof-sensor-1", "devices")
+	unknownClientCert := ca.issueLeaf(t, "unregistered-sensor", "other")
+
+	tests := []struct {
+		name           string
+		clientAuth     tls.ClientAuthType
+		clientCert     *tls.Certificate
+		sendAPIKey     bool
+		expectDeviceID string
+		expectStatus   int
+	}{
+		{
+			name:           "no client cert falls back to api key",
+			clientAuth:     tls.NoClientCert,
+			clientCert:     nil,
+			sendAPIKey:     true,
+			expectDeviceID: defaultDeviceID,
+			expectStatus:   http.StatusAccepted,
+		},
+		{
+			name:           "requested cert with allowed CN bypasses api key",
+			clientAuth:     tls.RequestClientCert,
+			clientCert:     &allowedClientCert,
+			sendAPIKey:     false,
+			expectDeviceID: "cert:roof-sensor-1",
+			expectStatus:   http.StatusAccepted,
+		},
+		{
+			name:           "verify if given with no cert falls back to api key",
+			clientAuth:     tls.VerifyClientCertIfGiven,
+			clientCert:     nil,
+			sendAPIKey:     true,
+			expectDeviceID: defaultDeviceID,
+			expectStatus:   http.StatusAccepted,
+		},
+		{
+			name:           "require and verify with allowed CN bypasses api key",
+			clientAuth:     tls.RequireAndVerifyClientCert,
+			clientCert:     &allowedClientCert,
+			sendAPIKey:     false,
+			expectDeviceID: "cert:roof-sensor-1",
+			expectStatus:   http.StatusAccepted,
+		},
+		{
+			name:           "require and verify with un-allow-listed CN falls back to api key",
+			clientAuth:     tls.RequireAndVerifyClientCert,
+			clientCert:     &unknownClientCert,
+			sendAPIKey:     true,
+			expectDeviceID: defaultDeviceID,
+			expectStatus:   http.StatusAccepted,
+		},
+		{
+			name:           "require and verify with un-allow-listed CN and no api key is unauthorized",
+			clientAuth:     tls.RequireAndVerifyClientCert,
+			clientCert:     &unknownClientCert,
+			sendAPIKey:     false,
+			expectDeviceID: "",
+			expectStatus:   http.StatusUnauthorized,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			store := &fakeStore{}
+			api := NewAPI(store, "secret", WithTLS(TLSConfig{
+				ClientAuth:         testCase.clientAuth,
+				ClientCAs:          ca.pool(),
+				AllowedCommonNames: []string{"roof-sensor-1"},
+			}))
+
+			testServer := httptest.NewUnstartedServer(api.Handler())
+			testServer.TLS = &tls.Config{
+				ClientAuth: testCase.clientAuth,
+				ClientCAs:  ca.pool(),
+			}
+			testServer.StartTLS()
+			defer testServer.Close()
+
+			clientTLSConfig := &tls.Config{
+				RootCAs: x509.NewCertPool(),
+			}
+			clientTLSConfig.RootCAs.AddCert(testServer.Certificate())
+			if testCase.clientCert != nil {
+				clientTLSConfig.Certificates = []tls.Certificate{*testCase.clientCert}
+			}
+			client := &http.Client{
+				Transport: &http.Transport{TLSClientConfig: clientTLSConfig},
+			}
+
+			request, err := http.NewRequest(http.MethodPost, testServer.URL+"/api/ingest", jsonReadingBody())
+			if err != nil {
+				t.Fatalf("build request: %v", err)
+			}
+			if testCase.sendAPIKey {
+				request.Header.Set("X-API-Key", "secret")
+			}
+
+			response, err := client.Do(request)
+			if err != nil {
+				t.Fatalf("do request: %v", err)
+			}
+			defer response.Body.Close()
+
+			if response.StatusCode != testCase.expectStatus {
+				t.Fatalf("expected status %d, got %d", testCase.expectStatus, response.StatusCode)
+			}
+			if testCase.expectStatus != http.StatusAccepted {
+				return
+			}
+			if len(store.added) != 1 {
+				t.Fatalf("expected 1 persisted reading, got %d", len(store.added))
+			}
+			if store.added[0].DeviceID != testCase.expectDeviceID {
+				t.Fatalf("expected device id %q, got %q", testCase.expectDeviceID, store.added[0].DeviceID)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ollamaAlertAnalyzer calls a local Ollama server's chat API in JSON mode,
+// so a privacy-conscious home user can run insights entirely offline with
+// no API key and no telemetry leaving the network.
+type ollamaAlertAnalyzer struct {
+	httpClient *http.Client
+	baseURL    string
+	model      string
+	maxAlerts  int
+}
+
+// NewOllamaAlertAnalyzer returns an AlertAnalyzer backed by a local Ollama
+// instance's /api/chat endpoint. baseURL defaults to Ollama's default
+// listen address.
+func NewOllamaAlertAnalyzer(baseURL string, model string, maxAlerts int) AlertAnalyzer {
+	trimmedBaseURL := strings.TrimSpace(baseURL)
+	if trimmedBaseURL == "" {
+		trimmedBaseURL = "http://localhost:11434"
+	}
+
+	trimmedModel := strings.TrimSpace(model)
+	if trimmedModel == "" {
+		trimmedModel = "llama3.1"
+	}
+
+	return &ollamaAlertAnalyzer{
+		// Request deadline is controlled by the caller context timeout.
+		httpClient: &http.Client{},
+		baseURL:    strings.TrimRight(trimmedBaseURL, "/"),
+		model:      trimmedModel,
+		maxAlerts:  clampMaxAlerts(maxAlerts),
+	}
+}
+
+func (analyzer *ollamaAlertAnalyzer) Source() string {
+	return "ollama"
+}
+
+func (analyzer *ollamaAlertAnalyzer) Analyze(ctx context.Context, readings []SensorReading) ([]Alert, error) {
+	if len(readings) == 0 {
+		return []Alert{}, nil
+	}
+
+	payload, err := json.Marshal(buildAlertSummary(readings))
+	if err != nil {
+		return nil, fmt.Errorf("marshal summary: %w", err)
+	}
+
+	requestPayload := map[string]any{
+		"model": analyzer.model,
+		"messages": []map[string]any{
+			{"role": "system", "content": systemPrompt(analyzer.maxAlerts)},
+			{"role": "user", "content": "Analyze this telemetry summary and return insights only as JSON.\n" + string(payload)},
+		},
+		"format": "json",
+		"stream": false,
+	}
+
+	requestBody, err := json.Marshal(requestPayload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		analyzer.baseURL+"/api/chat",
+		bytes.NewReader(requestBody),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := analyzer.httpClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	if response.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("ollama status %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var modelResponse struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal(body, &modelResponse); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	text := strings.TrimSpace(modelResponse.Message.Content)
+	if text == "" {
+		return nil, fmt.Errorf("ollama response did not include message content")
+	}
+
+	alerts, err := parseAlertsEnvelope(text, analyzer.maxAlerts)
+	if err != nil {
+		return nil, err
+	}
+	if len(alerts) == 0 {
+		return []Alert{fallbackStableAlert(readings)}, nil
+	}
+
+	return alerts, nil
+}
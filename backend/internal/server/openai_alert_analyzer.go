@@ -0,0 +1,219 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// openAIAlertAnalyzer calls OpenAI's Responses API with a strict JSON
+// schema so the model's output can be decoded straight into Alerts. model
+// is an atomic.Value rather than a plain string so SetModel can retune
+// OPENAI_INSIGHTS_MODEL from a SIGHUP reload (see API.Reload) while
+// Analyze calls are in flight on other goroutines.
+type openAIAlertAnalyzer struct {
+	httpClient *http.Client
+	baseURL    string
+	apiKey     string
+	model      atomic.Value // string
+	maxAlerts  int
+}
+
+// NewOpenAIAlertAnalyzer returns an AlertAnalyzer backed by OpenAI's
+// Responses API. baseURL defaults to the public OpenAI endpoint, so an
+// OpenAI-compatible proxy can be substituted by overriding it.
+func NewOpenAIAlertAnalyzer(apiKey string, model string, baseURL string, maxAlerts int) AlertAnalyzer {
+	trimmedModel := strings.TrimSpace(model)
+	if trimmedModel == "" {
+		trimmedModel = "gpt-5-mini"
+	}
+
+	trimmedBaseURL := strings.TrimSpace(baseURL)
+	if trimmedBaseURL == "" {
+		trimmedBaseURL = "https://api.openai.com/v1"
+	}
+
+	analyzer := &openAIAlertAnalyzer{
+		// Request deadline is controlled by the caller context timeout.
+		httpClient: &http.Client{},
+		baseURL:    strings.TrimRight(trimmedBaseURL, "/"),
+		apiKey:     strings.TrimSpace(apiKey),
+		maxAlerts:  clampMaxAlerts(maxAlerts),
+	}
+	analyzer.model.Store(trimmedModel)
+	return analyzer
+}
+
+func (analyzer *openAIAlertAnalyzer) Source() string {
+	return "openai"
+}
+
+func (analyzer *openAIAlertAnalyzer) currentModel() string {
+	return analyzer.model.Load().(string)
+}
+
+// SetModel swaps the model used by subsequent Analyze calls, satisfying
+// modelReloadable so API.Reload can retune OPENAI_INSIGHTS_MODEL without a
+// restart. A blank model is ignored.
+func (analyzer *openAIAlertAnalyzer) SetModel(model string) {
+	trimmedModel := strings.TrimSpace(model)
+	if trimmedModel == "" {
+		return
+	}
+	analyzer.model.Store(trimmedModel)
+}
+
+func (analyzer *openAIAlertAnalyzer) Analyze(
+	ctx context.Context,
+	readings []SensorReading,
+) (alerts []Alert, err error) {
+	start := time.Now()
+	model := analyzer.currentModel()
+	defer func() {
+		openAIRequestDuration.Observe(time.Since(start).Seconds())
+		openAICallLatency.WithLabelValues(model).Observe(time.Since(start).Seconds())
+		result := "success"
+		outcome := "success"
+		if err != nil {
+			result = "failure"
+			outcome = "failure"
+		}
+		openAIRequestsTotal.WithLabelValues(result).Inc()
+		openAICallsTotal.WithLabelValues(model, outcome).Inc()
+	}()
+
+	if len(readings) == 0 {
+		return []Alert{}, nil
+	}
+
+	payload, err := json.Marshal(buildAlertSummary(readings))
+	if err != nil {
+		return nil, fmt.Errorf("marshal summary: %w", err)
+	}
+
+	requestPayload := map[string]any{
+		"model": model,
+		"input": []map[string]any{
+			{
+				"role": "system",
+				"content": []map[string]any{
+					{
+						"type": "input_text",
+						"text": systemPrompt(analyzer.maxAlerts),
+					},
+				},
+			},
+			{
+				"role": "user",
+				"content": []map[string]any{
+					{
+						"type": "input_text",
+						"text": "Analyze this telemetry summary and return insights only as JSON.\n" + string(payload),
+					},
+				},
+			},
+		},
+		"text": map[string]any{
+			"format": map[string]any{
+				"type":   "json_schema",
+				"name":   "enviro_alerts",
+				"strict": true,
+				"schema": alertSchema(analyzer.maxAlerts),
+			},
+		},
+	}
+
+	requestBody, err := json.Marshal(requestPayload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		analyzer.baseURL+"/responses",
+		bytes.NewReader(requestBody),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	request.Header.Set("Authorization", "Bearer "+analyzer.apiKey)
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := analyzer.httpClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	if response.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("openai status %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var modelResponse struct {
+		OutputText string `json:"output_text"`
+		Output     []struct {
+			Content []struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"content"`
+		} `json:"output"`
+		Usage struct {
+			InputTokens  int64 `json:"input_tokens"`
+			OutputTokens int64 `json:"output_tokens"`
+		} `json:"usage"`
+	}
+
+	if err = json.Unmarshal(body, &modelResponse); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	if modelResponse.Usage.InputTokens > 0 {
+		openAITokensTotal.WithLabelValues(model, "input").Add(float64(modelResponse.Usage.InputTokens))
+	}
+	if modelResponse.Usage.OutputTokens > 0 {
+		openAITokensTotal.WithLabelValues(model, "output").Add(float64(modelResponse.Usage.OutputTokens))
+	}
+
+	text := strings.TrimSpace(modelResponse.OutputText)
+	if text == "" {
+		for _, output := range modelResponse.Output {
+			for _, content := range output.Content {
+				if content.Type == "output_text" || content.Type == "text" {
+					text = strings.TrimSpace(content.Text)
+					if text != "" {
+						break
+					}
+				}
+			}
+			if text != "" {
+				break
+			}
+		}
+	}
+
+	if text == "" {
+		return nil, fmt.Errorf("openai response did not include text output")
+	}
+
+	parsed, err := parseAlertsEnvelope(text, analyzer.maxAlerts)
+	if err != nil {
+		return nil, err
+	}
+	if len(parsed) == 0 {
+		return []Alert{fallbackStableAlert(readings)}, nil
+	}
+
+	return parsed, nil
+}
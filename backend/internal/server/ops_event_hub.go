@@ -0,0 +1,117 @@
+package server
+
+import (
+	"log/slog"
+	"sync"
+)
+
+const opsEventSubscriberBuffer = 32
+
+// opsEventHistorySize bounds how many recent OpsEvents opsEventHub retains
+// for replay, mirroring streamHub/alertStreamHub.
+const opsEventHistorySize = 64
+
+type opsEventStreamEvent struct {
+	ID    int64
+	Event OpsEvent
+}
+
+type opsEventSubscriber struct {
+	events chan opsEventStreamEvent
+}
+
+// opsEventHub fans newly recorded OpsEvents out to streaming subscribers
+// (currently /api/readings/stream's "ops_event" channel), mirroring
+// streamHub/alertStreamHub's replay-by-event-id semantics.
+type opsEventHub struct {
+	mu          sync.RWMutex
+	subscribers map[*opsEventSubscriber]struct{}
+	history     []opsEventStreamEvent
+	nextEventID int64
+	log         *slog.Logger
+}
+
+func newOpsEventHub() *opsEventHub {
+	return &opsEventHub{
+		subscribers: make(map[*opsEventSubscriber]struct{}),
+		log:         logger.With("component", "ops_event_stream"),
+	}
+}
+
+// subscribe registers a new subscriber, optionally replaying events newer
+// than sinceEventID from the in-memory history.
+func (hub *opsEventHub) subscribe(sinceEventID int64) (*opsEventSubscriber, func()) {
+	subscriber := &opsEventSubscriber{events: make(chan opsEventStreamEvent, opsEventSubscriberBuffer)}
+
+	hub.mu.Lock()
+	hub.subscribers[subscriber] = struct{}{}
+	var replay []opsEventStreamEvent
+	if sinceEventID > 0 {
+		for _, event := range hub.history {
+			if event.ID > sinceEventID {
+				replay = append(replay, event)
+			}
+		}
+	}
+	hub.mu.Unlock()
+
+	for _, event := range replay {
+		select {
+		case subscriber.events <- event:
+		default:
+		}
+	}
+
+	unsubscribe := func() {
+		hub.mu.Lock()
+		if _, exists := hub.subscribers[subscriber]; exists {
+			delete(hub.subscribers, subscriber)
+			close(subscriber.events)
+		}
+		hub.mu.Unlock()
+	}
+
+	return subscriber, unsubscribe
+}
+
+// publish fans event out to every subscriber. A subscriber whose buffer is
+// full is evicted rather than allowed to block the caller (persistOpsEvent
+// runs off the ingest path already, but the hub keeps the same
+// never-block contract as streamHub for consistency).
+func (hub *opsEventHub) publish(event OpsEvent) {
+	hub.mu.Lock()
+	hub.nextEventID++
+	streamEvent := opsEventStreamEvent{ID: hub.nextEventID, Event: event}
+	hub.history = append(hub.history, streamEvent)
+	if len(hub.history) > opsEventHistorySize {
+		hub.history = append([]opsEventStreamEvent(nil), hub.history[len(hub.history)-opsEventHistorySize:]...)
+	}
+
+	subscribers := make([]*opsEventSubscriber, 0, len(hub.subscribers))
+	for subscriber := range hub.subscribers {
+		subscribers = append(subscribers, subscriber)
+	}
+	hub.mu.Unlock()
+
+	for _, subscriber := range subscribers {
+		select {
+		case subscriber.events <- streamEvent:
+		default:
+			hub.evict(subscriber)
+		}
+	}
+}
+
+func (hub *opsEventHub) evict(subscriber *opsEventSubscriber) {
+	hub.mu.Lock()
+	_, exists := hub.subscribers[subscriber]
+	if exists {
+		delete(hub.subscribers, subscriber)
+		close(subscriber.events)
+	}
+	hub.mu.Unlock()
+
+	if exists {
+		hub.log.Debug("ops event stream subscriber evicted")
+	}
+}
@@ -0,0 +1,238 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteOpsEventMigration is one versioned, idempotent schema change
+// applied in order, tracked in schema_migrations the same way
+// PostgresStore tracks its migrations. It's kept inline here, rather than
+// as embedded .sql files, since the ops_events schema is a single small
+// table rather than a whole store's worth of SQL.
+type sqliteOpsEventMigration struct {
+	version string
+	sql     string
+}
+
+var sqliteOpsEventMigrations = []sqliteOpsEventMigration{
+	{
+		version: "0001_create_ops_events",
+		sql: `
+CREATE TABLE ops_events (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  timestamp INTEGER NOT NULL,
+  kind TEXT NOT NULL,
+  title TEXT NOT NULL,
+  detail TEXT NOT NULL
+);
+CREATE INDEX idx_ops_events_timestamp ON ops_events (timestamp);
+CREATE INDEX idx_ops_events_kind ON ops_events (kind);
+`,
+	},
+}
+
+// SQLiteOpsEventStore persists OpsEvents to a local SQLite database via
+// the CGO-free modernc.org/sqlite driver, for single-node deployments
+// that want durable ops history without standing up Postgres.
+type SQLiteOpsEventStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteOpsEventStore opens (creating if necessary) a SQLite database
+// at path, applies any pending schema migrations, and returns a ready
+// OpsEventStore.
+func NewSQLiteOpsEventStore(path string) (*SQLiteOpsEventStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite: %w", err)
+	}
+	// modernc.org/sqlite serializes writers internally; capping the pool
+	// at one connection avoids SQLITE_BUSY under concurrent ops writes.
+	db.SetMaxOpenConns(1)
+
+	store := &SQLiteOpsEventStore{db: db}
+	if err := store.migrate(context.Background()); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate schema: %w", err)
+	}
+
+	return store, nil
+}
+
+func (store *SQLiteOpsEventStore) Close() error {
+	return store.db.Close()
+}
+
+func (store *SQLiteOpsEventStore) migrate(ctx context.Context) error {
+	const migrationTableQuery = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+  version TEXT PRIMARY KEY,
+  applied_at INTEGER NOT NULL
+);`
+
+	if _, err := store.db.ExecContext(ctx, migrationTableQuery); err != nil {
+		return err
+	}
+
+	for _, migration := range sqliteOpsEventMigrations {
+		applied, err := store.isMigrationApplied(ctx, migration.version)
+		if err != nil {
+			return err
+		}
+		if applied {
+			continue
+		}
+
+		tx, err := store.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, migration.sql); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if _, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`,
+			migration.version,
+			time.Now().UnixMilli(),
+		); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (store *SQLiteOpsEventStore) isMigrationApplied(ctx context.Context, version string) (bool, error) {
+	const query = `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = ?)`
+	var exists bool
+	err := store.db.QueryRowContext(ctx, query, version).Scan(&exists)
+	return exists, err
+}
+
+func (store *SQLiteOpsEventStore) AddOpsEvent(ctx context.Context, event OpsEvent) error {
+	const query = `INSERT INTO ops_events (timestamp, kind, title, detail) VALUES (?, ?, ?, ?)`
+	_, err := store.db.ExecContext(ctx, query, event.Timestamp, event.Kind, event.Title, event.Detail)
+	return err
+}
+
+func (store *SQLiteOpsEventStore) LatestOpsEvents(ctx context.Context, limit int) ([]OpsEvent, error) {
+	page, err := store.QueryOpsEvents(ctx, OpsEventQuery{Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	return page.Events, nil
+}
+
+// QueryOpsEvents returns events matching query, newest first. A page
+// exactly query.Limit long implies there may be older events; paginate
+// backward through history by passing the returned NextCursor as the next
+// request's BeforeID.
+func (store *SQLiteOpsEventStore) QueryOpsEvents(ctx context.Context, query OpsEventQuery) (OpsEventPage, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = 30
+	}
+	if limit > maxOpsEventsLimit {
+		limit = maxOpsEventsLimit
+	}
+
+	var conditions []string
+	var args []any
+
+	if query.Since > 0 {
+		conditions = append(conditions, "timestamp > ?")
+		args = append(args, query.Since)
+	}
+	if query.Kind != "" {
+		conditions = append(conditions, "kind = ?")
+		args = append(args, query.Kind)
+	}
+	if query.BeforeID > 0 {
+		conditions = append(conditions, "id < ?")
+		args = append(args, query.BeforeID)
+	}
+
+	sqlQuery := "SELECT id, timestamp, kind, title, detail FROM ops_events"
+	if len(conditions) > 0 {
+		sqlQuery += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	sqlQuery += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := store.db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return OpsEventPage{}, err
+	}
+	defer rows.Close()
+
+	page := OpsEventPage{Events: make([]OpsEvent, 0, limit)}
+	for rows.Next() {
+		var event OpsEvent
+		if err := rows.Scan(&event.ID, &event.Timestamp, &event.Kind, &event.Title, &event.Detail); err != nil {
+			return OpsEventPage{}, err
+		}
+		page.Events = append(page.Events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return OpsEventPage{}, err
+	}
+
+	if len(page.Events) == limit {
+		page.NextCursor = strconv.FormatInt(page.Events[len(page.Events)-1].ID, 10)
+	}
+
+	return page, nil
+}
+
+// OpsEventCounts returns the total number of persisted ops events grouped
+// by kind, for the Prometheus /metrics endpoint's enviro_ops_events_total
+// counter.
+func (store *SQLiteOpsEventStore) OpsEventCounts(ctx context.Context) (map[string]int, error) {
+	rows, err := store.db.QueryContext(ctx, `SELECT kind, COUNT(*) FROM ops_events GROUP BY kind`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var kind string
+		var count int
+		if err := rows.Scan(&kind, &count); err != nil {
+			return nil, err
+		}
+		counts[kind] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// PruneOpsEvents deletes events older than olderThanTimestamp (unix
+// milliseconds), returning the number of rows removed.
+func (store *SQLiteOpsEventStore) PruneOpsEvents(ctx context.Context, olderThanTimestamp int64) (int64, error) {
+	result, err := store.db.ExecContext(ctx, `DELETE FROM ops_events WHERE timestamp < ?`, olderThanTimestamp)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+var _ OpsEventStore = (*SQLiteOpsEventStore)(nil)
+var _ opsEventCounter = (*SQLiteOpsEventStore)(nil)
+var _ opsEventQuerier = (*SQLiteOpsEventStore)(nil)
+var _ opsEventPruner = (*SQLiteOpsEventStore)(nil)
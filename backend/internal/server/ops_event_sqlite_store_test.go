@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func newTestSQLiteOpsEventStore(t *testing.T) *SQLiteOpsEventStore {
+	t.Helper()
+
+	store, err := NewSQLiteOpsEventStore(filepath.Join(t.TempDir(), "ops.db"))
+	if err != nil {
+		t.Fatalf("create sqlite ops event store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	return store
+}
+
+func TestSQLiteOpsEventStoreAddAndLatest(t *testing.T) {
+	store := newTestSQLiteOpsEventStore(t)
+	ctx := context.Background()
+
+	if err := store.AddOpsEvent(ctx, OpsEvent{Timestamp: 100, Kind: "device_connected", Title: "Connected", Detail: "ok"}); err != nil {
+		t.Fatalf("add ops event: %v", err)
+	}
+	if err := store.AddOpsEvent(ctx, OpsEvent{Timestamp: 200, Kind: "device_disconnected", Title: "Disconnected", Detail: "timeout"}); err != nil {
+		t.Fatalf("add ops event: %v", err)
+	}
+
+	events, err := store.LatestOpsEvents(ctx, 10)
+	if err != nil {
+		t.Fatalf("latest ops events: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	if events[0].Kind != "device_disconnected" {
+		t.Fatalf("expected most recent event first, got %q", events[0].Kind)
+	}
+}
+
+func TestSQLiteOpsEventStoreQueryFiltersByKindAndSince(t *testing.T) {
+	store := newTestSQLiteOpsEventStore(t)
+	ctx := context.Background()
+
+	for index := 0; index < 3; index++ {
+		kind := "device_connected"
+		if index%2 == 1 {
+			kind = "device_disconnected"
+		}
+		if err := store.AddOpsEvent(ctx, OpsEvent{Timestamp: int64(100 * (index + 1)), Kind: kind, Title: "t", Detail: "d"}); err != nil {
+			t.Fatalf("add ops event %d: %v", index, err)
+		}
+	}
+
+	page, err := store.QueryOpsEvents(ctx, OpsEventQuery{Kind: "device_disconnected", Limit: 10})
+	if err != nil {
+		t.Fatalf("query ops events: %v", err)
+	}
+	if len(page.Events) != 1 {
+		t.Fatalf("expected 1 matching event, got %d", len(page.Events))
+	}
+
+	page, err = store.QueryOpsEvents(ctx, OpsEventQuery{Since: 100, Limit: 10})
+	if err != nil {
+		t.Fatalf("query ops events: %v", err)
+	}
+	if len(page.Events) != 2 {
+		t.Fatalf("expected 2 events newer than since=100, got %d", len(page.Events))
+	}
+}
+
+func TestSQLiteOpsEventStoreQueryPaginatesWithCursor(t *testing.T) {
+	store := newTestSQLiteOpsEventStore(t)
+	ctx := context.Background()
+
+	for index := 0; index < 5; index++ {
+		if err := store.AddOpsEvent(ctx, OpsEvent{Timestamp: int64(index), Kind: "device_connected", Title: "t", Detail: "d"}); err != nil {
+			t.Fatalf("add ops event %d: %v", index, err)
+		}
+	}
+
+	firstPage, err := store.QueryOpsEvents(ctx, OpsEventQuery{Limit: 2})
+	if err != nil {
+		t.Fatalf("query first page: %v", err)
+	}
+	if len(firstPage.Events) != 2 || firstPage.NextCursor == "" {
+		t.Fatalf("expected a full page with a next cursor, got %+v", firstPage)
+	}
+
+	secondPage, err := store.QueryOpsEvents(ctx, OpsEventQuery{Limit: 2, BeforeID: firstPage.Events[len(firstPage.Events)-1].ID})
+	if err != nil {
+		t.Fatalf("query second page: %v", err)
+	}
+	if len(secondPage.Events) != 2 {
+		t.Fatalf("expected 2 events in second page, got %d", len(secondPage.Events))
+	}
+	if secondPage.Events[0].ID >= firstPage.Events[len(firstPage.Events)-1].ID {
+		t.Fatalf("expected second page to continue strictly before the first page's last id")
+	}
+}
+
+func TestSQLiteOpsEventStorePruneDeletesOldEvents(t *testing.T) {
+	store := newTestSQLiteOpsEventStore(t)
+	ctx := context.Background()
+
+	if err := store.AddOpsEvent(ctx, OpsEvent{Timestamp: 100, Kind: "device_connected", Title: "t", Detail: "d"}); err != nil {
+		t.Fatalf("add ops event: %v", err)
+	}
+	if err := store.AddOpsEvent(ctx, OpsEvent{Timestamp: 9999, Kind: "device_connected", Title: "t", Detail: "d"}); err != nil {
+		t.Fatalf("add ops event: %v", err)
+	}
+
+	deleted, err := store.PruneOpsEvents(ctx, 5000)
+	if err != nil {
+		t.Fatalf("prune ops events: %v", err)
+	}
+	if deleted != 1 {
+		t.Fatalf("expected 1 pruned event, got %d", deleted)
+	}
+
+	events, err := store.LatestOpsEvents(ctx, 10)
+	if err != nil {
+		t.Fatalf("latest ops events: %v", err)
+	}
+	if len(events) != 1 || events[0].Timestamp != 9999 {
+		t.Fatalf("expected only the newer event to remain, got %+v", events)
+	}
+}
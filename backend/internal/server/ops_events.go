@@ -11,6 +11,11 @@ type OpsEvent struct {
 	Kind      string `json:"kind"`
 	Title     string `json:"title"`
 	Detail    string `json:"detail"`
+	// DeviceID is set on device_connected/device_disconnected events to
+	// identify which registered device (see device_registry.go) the
+	// connectivity transition applies to. Empty for event kinds that
+	// aren't device-scoped (stream_client_dropped, rule_alert, ...).
+	DeviceID string `json:"device_id,omitempty"`
 }
 
 type OpsEventStore interface {
@@ -18,14 +23,63 @@ type OpsEventStore interface {
 	LatestOpsEvents(ctx context.Context, limit int) ([]OpsEvent, error)
 }
 
+// opsEventCounter is an optional OpsEventStore capability exposing total
+// event counts grouped by kind, used by the Prometheus /metrics endpoint.
+type opsEventCounter interface {
+	OpsEventCounts(ctx context.Context) (map[string]int, error)
+}
+
+// OpsEventQuery filters and paginates QueryOpsEvents. Since and Kind
+// narrow the result set; BeforeID resumes a descending-by-id scan after
+// the last row of a previous page (the page's NextCursor), for paging
+// backward through history. Zero values mean "no filter".
+type OpsEventQuery struct {
+	Since    int64
+	Kind     string
+	Limit    int
+	BeforeID int64
+}
+
+// OpsEventPage is one page of a QueryOpsEvents result. NextCursor is
+// empty once there are no further (older) pages.
+type OpsEventPage struct {
+	Events     []OpsEvent
+	NextCursor string
+}
+
+// opsEventQuerier is an optional OpsEventStore capability exposing
+// filtered, cursor-paginated queries, used by /api/ops/events when
+// since/kind/cursor narrow the request beyond a plain LatestOpsEvents.
+type opsEventQuerier interface {
+	QueryOpsEvents(ctx context.Context, query OpsEventQuery) (OpsEventPage, error)
+}
+
+// opsEventPruner is an optional OpsEventStore capability that deletes
+// events older than a retention cutoff, invoked periodically from the
+// device monitor loop when OpsConfig.RetentionPeriod is set.
+type opsEventPruner interface {
+	PruneOpsEvents(ctx context.Context, olderThanTimestamp int64) (int64, error)
+}
+
 type OpsConfig struct {
 	DeviceOfflineTimeout time.Duration
 	MonitorInterval      time.Duration
+	// RetentionPeriod bounds how long persisted ops events are kept, for
+	// OpsEventStore implementations that support pruning (opsEventPruner).
+	// Zero disables pruning.
+	RetentionPeriod time.Duration
+	// IngestSessionTTL bounds how long an opened-but-uncommitted chunked
+	// ingest session (see ingest_session.go) stays resumable before
+	// startIngestSessionMonitor prunes it. Zero or negative falls back to
+	// defaultIngestSessionTTL.
+	IngestSessionTTL time.Duration
 }
 
 func DefaultOpsConfig() OpsConfig {
 	return OpsConfig{
 		DeviceOfflineTimeout: 45 * time.Second,
 		MonitorInterval:      5 * time.Second,
+		RetentionPeriod:      30 * 24 * time.Hour,
+		IngestSessionTTL:     defaultIngestSessionTTL,
 	}
 }
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// poolStater is an optional Store capability exposing pgxpool connection
+// stats, implemented by PostgresStore, used to populate
+// enviro_pg_pool_connections on a fixed interval.
+type poolStater interface {
+	PoolStat() *pgxpool.Stat
+}
+
+// startPoolStatsReporter periodically samples stater's pool stats into
+// pgPoolConnsGauge until ctx is cancelled.
+func (api *API) startPoolStatsReporter(ctx context.Context, stater poolStater) {
+	report := func() {
+		stat := stater.PoolStat()
+		pgPoolConnsGauge.WithLabelValues("total").Set(float64(stat.TotalConns()))
+		pgPoolConnsGauge.WithLabelValues("acquired").Set(float64(stat.AcquiredConns()))
+		pgPoolConnsGauge.WithLabelValues("idle").Set(float64(stat.IdleConns()))
+		pgPoolConnsGauge.WithLabelValues("max").Set(float64(stat.MaxConns()))
+	}
+
+	go func() {
+		report()
+
+		ticker := time.NewTicker(15 * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				report()
+			}
+		}
+	}()
+}
@@ -18,6 +18,19 @@ import (
 
 type PostgresStore struct {
 	pool *pgxpool.Pool
+
+	// rollupCtx/cancelRollup bound the background rollup scheduler's
+	// lifetime to the store's, independent of whatever short-lived ctx
+	// NewPostgresStore was called with (typically a setup timeout) --
+	// cancelled by Close so the goroutine doesn't leak past it.
+	rollupCtx    context.Context
+	cancelRollup context.CancelFunc
+
+	// subscriptionBroker fans out every ingested reading to the dynamic,
+	// API-registered subscriptions (see dynamic_subscriptions.go), sharing
+	// rollupCtx's store-owned lifetime so delivery outlives any single
+	// request's context.
+	subscriptionBroker *dynamicSubscriptionBroker
 }
 
 //go:embed migrations/*.sql
@@ -49,9 +62,64 @@ func NewPostgresStore(ctx context.Context, databaseURL string, maxConns int32) (
 		return nil, fmt.Errorf("migrate schema: %w", err)
 	}
 
+	store.rollupCtx, store.cancelRollup = context.WithCancel(context.Background())
+	store.startRollupScheduler(store.rollupCtx)
+
+	store.subscriptionBroker = newDynamicSubscriptionBroker(store.rollupCtx, store.recordSubscriptionOverflow)
+	if err := store.loadSubscriptions(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("load subscriptions: %w", err)
+	}
+
 	return store, nil
 }
 
+// recordSubscriptionOverflow persists a drop-oldest overflow as an ops
+// event so it's visible in /api/ops/events, not just a counter that only
+// Prometheus sees. Fire-and-forget, mirroring persistOpsEvent's async
+// persist pattern, since it runs off the ingest path.
+func (store *PostgresStore) recordSubscriptionOverflow(subscriptionName string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		if err := store.AddOpsEvent(ctx, OpsEvent{
+			Timestamp: time.Now().UnixMilli(),
+			Kind:      "subscription_overflow",
+			Title:     "Subscription queue overflow",
+			Detail:    fmt.Sprintf("Subscription %q fell behind and dropped a reading.", subscriptionName),
+		}); err != nil {
+			logger.Error("subscription overflow ops event persist failed",
+				"component", "subscriptions", "subscription", subscriptionName, "error", err)
+		}
+	}()
+}
+
+// recordBulkCopyMetrics persists rows_copied/copy_duration_ms for a
+// completed addBatchCopy as an ops event, the same fire-and-forget
+// persist pattern recordSubscriptionOverflow uses, so bulk-load
+// throughput is visible in /api/ops/events without a dedicated
+// Prometheus series.
+func (store *PostgresStore) recordBulkCopyMetrics(rowsCopied int64, duration time.Duration) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		if err := store.AddOpsEvent(ctx, OpsEvent{
+			Timestamp: time.Now().UnixMilli(),
+			Kind:      "bulk_copy",
+			Title:     "Bulk insert via COPY",
+			Detail: fmt.Sprintf(
+				"rows_copied=%d copy_duration_ms=%d",
+				rowsCopied,
+				duration.Milliseconds(),
+			),
+		}); err != nil {
+			logger.Error("bulk copy ops event persist failed", "component", "storage", "error", err)
+		}
+	}()
+}
+
 func (store *PostgresStore) migrate(ctx context.Context) error {
 	const migrationTableQuery = `
 CREATE TABLE IF NOT EXISTS schema_migrations (
@@ -142,7 +210,23 @@ INSERT INTO sensor_readings (
 		reading.PM2,
 		reading.PM10,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+
+	store.subscriptionBroker.publish(reading)
+	return nil
+}
+
+// addBatchCopyThreshold is the batch size at or above which AddBatch switches
+// from queued INSERTs to CopyFrom, which avoids per-row statement
+// round-trips and typically gives an order-of-magnitude speedup for bulk
+// loads (the same burst-flush pattern Telegraf-style agents use).
+const addBatchCopyThreshold = 200
+
+var sensorReadingCopyColumns = []string{
+	"timestamp", "temperature", "pressure", "humidity",
+	"oxidised", "reduced", "nh3", "pm1", "pm2", "pm10",
 }
 
 func (store *PostgresStore) AddBatch(ctx context.Context, readings []SensorReading) error {
@@ -150,6 +234,55 @@ func (store *PostgresStore) AddBatch(ctx context.Context, readings []SensorReadi
 		return nil
 	}
 
+	if len(readings) >= addBatchCopyThreshold {
+		return store.addBatchCopy(ctx, readings)
+	}
+	return store.addBatchInsert(ctx, readings)
+}
+
+// addBatchCopy bulk-loads readings via pgx's CopyFrom (a COPY FROM STDIN
+// under the hood), bypassing per-row statement execution entirely. It
+// runs as a single COPY on the pool rather than inside the explicit
+// transaction addBatchInsert opens.
+func (store *PostgresStore) addBatchCopy(ctx context.Context, readings []SensorReading) error {
+	started := time.Now()
+
+	rows := make([][]any, len(readings))
+	for index, reading := range readings {
+		rows[index] = []any{
+			reading.Timestamp,
+			reading.Temperature,
+			reading.Pressure,
+			reading.Humidity,
+			reading.Oxidised,
+			reading.Reduced,
+			reading.Nh3,
+			reading.PM1,
+			reading.PM2,
+			reading.PM10,
+		}
+	}
+
+	rowsCopied, err := store.pool.CopyFrom(
+		ctx,
+		pgx.Identifier{"sensor_readings"},
+		sensorReadingCopyColumns,
+		pgx.CopyFromRows(rows),
+	)
+	if err != nil {
+		return fmt.Errorf("copy readings: %w", err)
+	}
+
+	store.recordBulkCopyMetrics(rowsCopied, time.Since(started))
+
+	for _, reading := range readings {
+		store.subscriptionBroker.publish(reading)
+	}
+
+	return nil
+}
+
+func (store *PostgresStore) addBatchInsert(ctx context.Context, readings []SensorReading) error {
 	const insertReadingQuery = `
 INSERT INTO sensor_readings (
   timestamp, temperature, pressure, humidity, oxidised, reduced, nh3, pm1, pm2, pm10
@@ -188,9 +321,21 @@ INSERT INTO sensor_readings (
 		return err
 	}
 
+	for _, reading := range readings {
+		store.subscriptionBroker.publish(reading)
+	}
+
 	return nil
 }
 
+func (store *PostgresStore) Count(ctx context.Context) (int, error) {
+	var count int
+	if err := store.pool.QueryRow(ctx, `SELECT COUNT(*) FROM sensor_readings`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count readings: %w", err)
+	}
+	return count, nil
+}
+
 func (store *PostgresStore) Latest(ctx context.Context, limit int) ([]SensorReading, error) {
 	if limit <= 0 {
 		limit = 100
@@ -240,29 +385,52 @@ LIMIT $1
 	return readings, nil
 }
 
-func (store *PostgresStore) DeleteOlderThan(ctx context.Context, cutoffTimestamp int64, limit int) (int64, error) {
+// LatestEach streams the most recent limit readings in chronological
+// order via visit, avoiding the need to buffer them into a slice.
+func (store *PostgresStore) LatestEach(ctx context.Context, limit int, visit func(SensorReading) error) error {
 	if limit <= 0 {
-		limit = 1000
+		limit = 100
 	}
 
 	const query = `
-WITH expired AS (
-  SELECT id
-  FROM sensor_readings
-  WHERE timestamp < $1
-  ORDER BY timestamp
-  LIMIT $2
-)
-DELETE FROM sensor_readings AS readings
-USING expired
-WHERE readings.id = expired.id
+SELECT timestamp, temperature, pressure, humidity, oxidised, reduced, nh3, pm1, pm2, pm10
+FROM (
+	SELECT id, timestamp, temperature, pressure, humidity, oxidised, reduced, nh3, pm1, pm2, pm10
+	FROM sensor_readings
+	ORDER BY id DESC
+	LIMIT $1
+) AS latest
+ORDER BY id ASC
 `
 
-	result, err := store.pool.Exec(ctx, query, cutoffTimestamp, limit)
+	rows, err := store.pool.Query(ctx, query, limit)
 	if err != nil {
-		return 0, err
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var reading SensorReading
+		if err := rows.Scan(
+			&reading.Timestamp,
+			&reading.Temperature,
+			&reading.Pressure,
+			&reading.Humidity,
+			&reading.Oxidised,
+			&reading.Reduced,
+			&reading.Nh3,
+			&reading.PM1,
+			&reading.PM2,
+			&reading.PM10,
+		); err != nil {
+			return err
+		}
+		if err := visit(reading); err != nil {
+			return err
+		}
 	}
-	return result.RowsAffected(), nil
+
+	return rows.Err()
 }
 
 func (store *PostgresStore) SaveInsightsSnapshot(ctx context.Context, snapshot InsightsSnapshot) error {
@@ -383,6 +551,31 @@ LIMIT $1
 	return events, nil
 }
 
+// OpsEventCounts returns the total number of persisted ops events grouped
+// by kind, for the Prometheus /metrics endpoint's enviro_ops_events_total
+// counter.
+func (store *PostgresStore) OpsEventCounts(ctx context.Context) (map[string]int, error) {
+	const query = `SELECT kind, COUNT(*) FROM ops_events GROUP BY kind`
+
+	rows, err := store.pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var kind string
+		var count int
+		if err := rows.Scan(&kind, &count); err != nil {
+			return nil, err
+		}
+		counts[kind] = count
+	}
+
+	return counts, rows.Err()
+}
+
 func (store *PostgresStore) Ping(ctx context.Context) error {
 	pingCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
@@ -390,9 +583,17 @@ func (store *PostgresStore) Ping(ctx context.Context) error {
 }
 
 func (store *PostgresStore) Close() {
+	store.cancelRollup()
 	store.pool.Close()
 }
 
+// PoolStat exposes the underlying pgxpool's connection counts, used by
+// startPoolStatsReporter to populate enviro_pg_pool_connections.
+func (store *PostgresStore) PoolStat() *pgxpool.Stat {
+	return store.pool.Stat()
+}
+
 var _ Store = (*PostgresStore)(nil)
 var _ InsightsSnapshotStore = (*PostgresStore)(nil)
 var _ OpsEventStore = (*PostgresStore)(nil)
+var _ ResolutionAwareStore = (*PostgresStore)(nil)
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+// newBenchmarkPostgresStore connects to TEST_DATABASE_URL, skipping the
+// benchmark when it isn't set -- there's no in-process Postgres available
+// in this repo's test environment, so addBatchInsert/addBatchCopy can only
+// be compared against a real server an operator points at explicitly.
+func newBenchmarkPostgresStore(b *testing.B) *PostgresStore {
+	b.Helper()
+
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		b.Skip("TEST_DATABASE_URL not set, skipping PostgresStore benchmark")
+	}
+
+	store, err := NewPostgresStore(context.Background(), databaseURL, 4)
+	if err != nil {
+		b.Fatalf("create postgres store: %v", err)
+	}
+	b.Cleanup(store.Close)
+
+	return store
+}
+
+func benchmarkReadings(count int) []SensorReading {
+	readings := make([]SensorReading, count)
+	now := time.Now().UnixMilli()
+	for index := range readings {
+		readings[index] = SensorReading{
+			Timestamp:   now + int64(index),
+			Temperature: 21.5,
+			Pressure:    1013.0,
+			Humidity:    45.0,
+			Oxidised:    1.1,
+			Reduced:     0.9,
+			Nh3:         0.7,
+			PM1:         4.0,
+			PM2:         5.0,
+			PM10:        7.0,
+		}
+	}
+	return readings
+}
+
+// BenchmarkAddBatchInsert measures the queued-INSERT path directly,
+// bypassing AddBatch's addBatchCopyThreshold dispatch so it runs at sizes
+// that would otherwise route to addBatchCopy.
+func BenchmarkAddBatchInsert(b *testing.B) {
+	store := newBenchmarkPostgresStore(b)
+	ctx := context.Background()
+	readings := benchmarkReadings(addBatchCopyThreshold)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := store.addBatchInsert(ctx, readings); err != nil {
+			b.Fatalf("addBatchInsert: %v", err)
+		}
+	}
+}
+
+// BenchmarkAddBatchCopy measures the CopyFrom path at the same batch size
+// as BenchmarkAddBatchInsert, so the two are directly comparable.
+func BenchmarkAddBatchCopy(b *testing.B) {
+	store := newBenchmarkPostgresStore(b)
+	ctx := context.Background()
+	readings := benchmarkReadings(addBatchCopyThreshold)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := store.addBatchCopy(ctx, readings); err != nil {
+			b.Fatalf("addBatchCopy: %v", err)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// promQLLookback bounds how far back an instant query (or a single step of
+// a range query) will look for the most recent sample, mirroring the
+// upstream Prometheus engine's 5m staleness window.
+const promQLLookback = 5 * time.Minute
+
+// PromQLEngine evaluates one of this service's fixed sensor-field
+// selectors (temperature, pm2, nh3, …) and returns Prometheus HTTP
+// API-compatible vector/matrix results. WithPromQL lets tests substitute a
+// fake; NewAPI otherwise wires promQLEvaluator automatically against any
+// Store that also implements readingsRangeStore.
+type PromQLEngine interface {
+	Query(ctx context.Context, query string, evalTimestamp int64) (promQLVector, int, error)
+	QueryRange(ctx context.Context, query string, fromTimestamp int64, toTimestamp int64, step time.Duration) (promQLMatrix, int, error)
+}
+
+// promQLSample is one (timestamp, value) point, matching the
+// [<unix_seconds>, "<value>"] pair the Prometheus HTTP API emits.
+type promQLSample struct {
+	Timestamp int64
+	Value     float64
+}
+
+// promQLVector is an instant vector: this service has exactly one series
+// per metric (there is no per-device label dimension), so it's never
+// longer than one element, but the shape matches Prometheus's "vector"
+// resultType for Grafana compatibility.
+type promQLVector []promQLVectorSample
+
+type promQLVectorSample struct {
+	Metric map[string]string
+	Sample promQLSample
+}
+
+// promQLMatrix is a range vector: one series per metric with its sampled
+// points over [from, to] at step.
+type promQLMatrix []promQLMatrixSeries
+
+type promQLMatrixSeries struct {
+	Metric  map[string]string
+	Samples []promQLSample
+}
+
+// promQLSelectorPattern accepts a bare metric name or a metric name
+// followed by an empty or single-label-free matcher block, e.g.
+// "temperature" or "temperature{}". This service has no label dimension
+// beyond the metric name itself (one sensor, one series per field), so
+// anything beyond that — binary operators, aggregations, label matchers —
+// is rejected as unsupported rather than silently ignored.
+var promQLSelectorPattern = regexp.MustCompile(`^([a-zA-Z_][a-zA-Z0-9_]*)\s*(\{\s*\})?$`)
+
+// parsePromQLSelector extracts the metric name from a PromQL-style query
+// string. It deliberately supports only the bare-selector subset of PromQL
+// described above; anything else returns an error the caller can surface
+// as a "bad_data" response, the same way a real Prometheus rejects a query
+// its parser can't handle.
+func parsePromQLSelector(query string) (string, error) {
+	trimmed := strings.TrimSpace(query)
+	matches := promQLSelectorPattern.FindStringSubmatch(trimmed)
+	if matches == nil {
+		return "", fmt.Errorf("unsupported PromQL expression %q: only a bare metric selector is supported (e.g. temperature or temperature{})", query)
+	}
+
+	metric := matches[1]
+	if _, ok := queryMetricAccessors[metric]; !ok {
+		return "", fmt.Errorf("unknown metric: %s", metric)
+	}
+	return metric, nil
+}
+
+// promQLEvaluator is the default PromQLEngine, backed directly by
+// Store.Range. It has no query planner to speak of: each selector already
+// names exactly one accessor, so "evaluating" a query is just picking the
+// freshest reading at or before the requested timestamp.
+type promQLEvaluator struct {
+	store readingsRangeStore
+}
+
+func newPromQLEvaluator(store readingsRangeStore) *promQLEvaluator {
+	return &promQLEvaluator{store: store}
+}
+
+func (evaluator *promQLEvaluator) Query(ctx context.Context, query string, evalTimestamp int64) (promQLVector, int, error) {
+	metric, err := parsePromQLSelector(query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	fromTimestamp := evalTimestamp - int64(promQLLookback.Seconds())
+	readings, err := evaluator.store.Range(ctx, fromTimestamp, evalTimestamp, maxReadingsLimit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	accessor := queryMetricAccessors[metric]
+	sample, ok := latestSampleAt(readings, accessor, evalTimestamp)
+	if !ok {
+		return promQLVector{}, len(readings), nil
+	}
+
+	return promQLVector{{
+		Metric: map[string]string{"__name__": metric},
+		Sample: sample,
+	}}, len(readings), nil
+}
+
+func (evaluator *promQLEvaluator) QueryRange(ctx context.Context, query string, fromTimestamp int64, toTimestamp int64, step time.Duration) (promQLMatrix, int, error) {
+	metric, err := parsePromQLSelector(query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	lookbackTimestamp := fromTimestamp - int64(promQLLookback.Seconds())
+	readings, err := evaluator.store.Range(ctx, lookbackTimestamp, toTimestamp, maxReadingsLimit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stepSeconds := int64(step.Seconds())
+	if stepSeconds < 1 {
+		stepSeconds = 1
+	}
+
+	accessor := queryMetricAccessors[metric]
+	samples := make([]promQLSample, 0, (toTimestamp-fromTimestamp)/stepSeconds+1)
+	for evalTimestamp := fromTimestamp; evalTimestamp <= toTimestamp; evalTimestamp += stepSeconds {
+		sample, ok := latestSampleAt(readings, accessor, evalTimestamp)
+		if !ok {
+			continue
+		}
+		samples = append(samples, sample)
+	}
+
+	if len(samples) == 0 {
+		return promQLMatrix{}, len(readings), nil
+	}
+
+	return promQLMatrix{{
+		Metric:  map[string]string{"__name__": metric},
+		Samples: samples,
+	}}, len(readings), nil
+}
+
+// latestSampleAt returns the most recent non-NaN reading at or before
+// evalTimestamp and no more than promQLLookback before it, matching
+// Prometheus's instant-vector lookup semantics.
+func latestSampleAt(readings []SensorReading, accessor func(SensorReading) float64, evalTimestamp int64) (promQLSample, bool) {
+	lookbackTimestamp := evalTimestamp - int64(promQLLookback.Seconds())
+	found := false
+	var latest SensorReading
+	for _, reading := range readings {
+		if reading.Timestamp > evalTimestamp || reading.Timestamp < lookbackTimestamp {
+			continue
+		}
+		if math.IsNaN(accessor(reading)) {
+			continue
+		}
+		if !found || reading.Timestamp > latest.Timestamp {
+			latest = reading
+			found = true
+		}
+	}
+	if !found {
+		return promQLSample{}, false
+	}
+	return promQLSample{Timestamp: latest.Timestamp, Value: accessor(latest)}, true
+}
+
+func (api *API) handlePromQLQuery(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		writeError(response, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !api.authorizeReadRequest(response, request) {
+		return
+	}
+	if api.promQLEngine == nil {
+		writePromQLError(response, http.StatusNotImplemented, "unavailable", fmt.Errorf("PromQL queries are not supported"))
+		return
+	}
+
+	query := request.URL.Query().Get("query")
+	if strings.TrimSpace(query) == "" {
+		writePromQLError(response, http.StatusBadRequest, "bad_data", fmt.Errorf("query is required"))
+		return
+	}
+
+	evalTimestamp := time.Now().Unix()
+	if rawTime := request.URL.Query().Get("time"); rawTime != "" {
+		parsedTime, err := parsePromQLTimestamp(rawTime)
+		if err != nil {
+			writePromQLError(response, http.StatusBadRequest, "bad_data", fmt.Errorf("time must be a unix timestamp in seconds"))
+			return
+		}
+		evalTimestamp = parsedTime
+	}
+
+	vector, samplesScanned, err := api.promQLEngine.Query(request.Context(), query, evalTimestamp)
+	if err != nil {
+		writePromQLError(response, http.StatusBadRequest, "bad_data", err)
+		return
+	}
+
+	data := map[string]any{
+		"resultType": "vector",
+		"result":     encodePromQLVector(vector),
+	}
+	if request.URL.Query().Get("stats") == "all" {
+		data["stats"] = promQLStats(samplesScanned)
+	}
+	writePromQLSuccess(response, data)
+}
+
+func (api *API) handlePromQLQueryRange(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		writeError(response, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !api.authorizeReadRequest(response, request) {
+		return
+	}
+	if api.promQLEngine == nil {
+		writePromQLError(response, http.StatusNotImplemented, "unavailable", fmt.Errorf("PromQL queries are not supported"))
+		return
+	}
+
+	query := request.URL.Query().Get("query")
+	if strings.TrimSpace(query) == "" {
+		writePromQLError(response, http.StatusBadRequest, "bad_data", fmt.Errorf("query is required"))
+		return
+	}
+
+	fromTimestamp, err := parsePromQLTimestamp(request.URL.Query().Get("start"))
+	if err != nil {
+		writePromQLError(response, http.StatusBadRequest, "bad_data", fmt.Errorf("start must be a unix timestamp in seconds"))
+		return
+	}
+	toTimestamp, err := parsePromQLTimestamp(request.URL.Query().Get("end"))
+	if err != nil {
+		writePromQLError(response, http.StatusBadRequest, "bad_data", fmt.Errorf("end must be a unix timestamp in seconds"))
+		return
+	}
+	if fromTimestamp >= toTimestamp {
+		writePromQLError(response, http.StatusBadRequest, "bad_data", fmt.Errorf("start must be less than end"))
+		return
+	}
+
+	step, err := parseQueryStep(request)
+	if err != nil {
+		writePromQLError(response, http.StatusBadRequest, "bad_data", err)
+		return
+	}
+
+	matrix, samplesScanned, err := api.promQLEngine.QueryRange(request.Context(), query, fromTimestamp, toTimestamp, step)
+	if err != nil {
+		writePromQLError(response, http.StatusBadRequest, "bad_data", err)
+		return
+	}
+
+	data := map[string]any{
+		"resultType": "matrix",
+		"result":     encodePromQLMatrix(matrix),
+	}
+	if request.URL.Query().Get("stats") == "all" {
+		data["stats"] = promQLStats(samplesScanned)
+	}
+	writePromQLSuccess(response, data)
+}
+
+// handlePromQLLabels answers /api/v1/labels with the one label every
+// series carries. There is no per-device or per-sensor label dimension in
+// this deployment (one sensor, one series per field), so "__name__" is the
+// whole answer.
+func (api *API) handlePromQLLabels(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		writeError(response, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !api.authorizeReadRequest(response, request) {
+		return
+	}
+
+	writeJSON(response, http.StatusOK, map[string]any{"status": "success", "data": []string{"__name__"}})
+}
+
+func (api *API) handlePromQLSeries(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		writeError(response, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !api.authorizeReadRequest(response, request) {
+		return
+	}
+
+	matchers := request.URL.Query()["match[]"]
+	if len(matchers) == 0 {
+		writePromQLError(response, http.StatusBadRequest, "bad_data", fmt.Errorf("match[] is required"))
+		return
+	}
+
+	seen := make(map[string]struct{}, len(matchers))
+	series := make([]map[string]string, 0, len(matchers))
+	for _, matcher := range matchers {
+		metric, err := parsePromQLSelector(matcher)
+		if err != nil {
+			writePromQLError(response, http.StatusBadRequest, "bad_data", err)
+			return
+		}
+		if _, duplicate := seen[metric]; duplicate {
+			continue
+		}
+		seen[metric] = struct{}{}
+		series = append(series, map[string]string{"__name__": metric})
+	}
+
+	writeJSON(response, http.StatusOK, map[string]any{"status": "success", "data": series})
+}
+
+func parsePromQLTimestamp(raw string) (int64, error) {
+	value, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+	if err != nil {
+		return 0, err
+	}
+	return int64(value), nil
+}
+
+func encodePromQLVector(vector promQLVector) []map[string]any {
+	result := make([]map[string]any, 0, len(vector))
+	for _, sample := range vector {
+		result = append(result, map[string]any{
+			"metric": sample.Metric,
+			"value":  encodePromQLSample(sample.Sample),
+		})
+	}
+	return result
+}
+
+func encodePromQLMatrix(matrix promQLMatrix) []map[string]any {
+	result := make([]map[string]any, 0, len(matrix))
+	for _, series := range matrix {
+		values := make([][2]any, 0, len(series.Samples))
+		for _, sample := range series.Samples {
+			values = append(values, encodePromQLSample(sample))
+		}
+		result = append(result, map[string]any{
+			"metric": series.Metric,
+			"values": values,
+		})
+	}
+	return result
+}
+
+func encodePromQLSample(sample promQLSample) [2]any {
+	return [2]any{sample.Timestamp, strconv.FormatFloat(sample.Value, 'f', -1, 64)}
+}
+
+// promQLStats reports the number of raw readings the store returned for
+// this query, mirroring (in miniature) the upstream engine's
+// data.stats.samplesQueriedTotal, which Grafana's "query stats" panel
+// reads when stats=all is requested.
+func promQLStats(samplesScanned int) map[string]any {
+	return map[string]any{"samplesQueriedTotal": samplesScanned}
+}
+
+func writePromQLSuccess(response http.ResponseWriter, data map[string]any) {
+	writeJSON(response, http.StatusOK, map[string]any{"status": "success", "data": data})
+}
+
+func writePromQLError(response http.ResponseWriter, statusCode int, errType string, err error) {
+	writeJSON(response, statusCode, map[string]any{
+		"status":    "error",
+		"errorType": errType,
+		"error":     err.Error(),
+	})
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandlePromQLQueryReturnsVector(t *testing.T) {
+	store := &fakeStore{
+		ranged: []SensorReading{
+			{Timestamp: 1738886400, PM2: 3.2},
+			{Timestamp: 1738886460, PM2: 4.1},
+		},
+	}
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	request := httptest.NewRequest(
+		http.MethodGet,
+		"/api/v1/query?query=pm2&time=1738886460",
+		nil,
+	)
+	response := httptest.NewRecorder()
+
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, response.Code, response.Body.String())
+	}
+	body := response.Body.String()
+	if !strings.Contains(body, `"status":"success"`) {
+		t.Fatalf("expected a success envelope, got %s", body)
+	}
+	if !strings.Contains(body, `"resultType":"vector"`) {
+		t.Fatalf("expected resultType vector, got %s", body)
+	}
+	if !strings.Contains(body, `"4.1"`) {
+		t.Fatalf("expected the latest pm2 sample, got %s", body)
+	}
+}
+
+func TestHandlePromQLQueryRangeReturnsMatrix(t *testing.T) {
+	store := &fakeStore{
+		ranged: []SensorReading{
+			{Timestamp: 1738886400, PM2: 2.0},
+			{Timestamp: 1738886460, PM2: 6.0},
+		},
+	}
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	request := httptest.NewRequest(
+		http.MethodGet,
+		"/api/v1/query_range?query=pm2&start=1738886400&end=1738886520&step=60s&stats=all",
+		nil,
+	)
+	response := httptest.NewRecorder()
+
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, response.Code, response.Body.String())
+	}
+	body := response.Body.String()
+	if !strings.Contains(body, `"resultType":"matrix"`) {
+		t.Fatalf("expected resultType matrix, got %s", body)
+	}
+	if !strings.Contains(body, `"samplesQueriedTotal"`) {
+		t.Fatalf("expected stats=all to include samplesQueriedTotal, got %s", body)
+	}
+}
+
+func TestHandlePromQLQueryRejectsUnknownMetric(t *testing.T) {
+	store := &fakeStore{}
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/query?query=co2", nil)
+	response := httptest.NewRecorder()
+
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), `"status":"error"`) {
+		t.Fatalf("expected an error envelope, got %s", response.Body.String())
+	}
+}
+
+func TestHandlePromQLQueryRejectsUnsupportedExpression(t *testing.T) {
+	store := &fakeStore{}
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/query?query=pm2+%2B+pm10", nil)
+	response := httptest.NewRecorder()
+
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, response.Code)
+	}
+}
+
+func TestHandlePromQLSeriesListsMatchedMetrics(t *testing.T) {
+	store := &fakeStore{}
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/series?match[]=pm2&match[]=temperature", nil)
+	response := httptest.NewRecorder()
+
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, response.Code, response.Body.String())
+	}
+	body := response.Body.String()
+	if !strings.Contains(body, `"__name__":"pm2"`) || !strings.Contains(body, `"__name__":"temperature"`) {
+		t.Fatalf("expected both matched metrics in series, got %s", body)
+	}
+}
+
+func TestHandlePromQLLabelsListsNameLabel(t *testing.T) {
+	store := &fakeStore{}
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/labels", nil)
+	response := httptest.NewRecorder()
+
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), `"__name__"`) {
+		t.Fatalf("expected __name__ in labels, got %s", response.Body.String())
+	}
+}
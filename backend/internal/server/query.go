@@ -0,0 +1,310 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// queryMetricAccessors exposes each SensorReading column that the
+// query/query_range subsystem can aggregate over, keyed by the same
+// names used throughout the ingest payloads.
+var queryMetricAccessors = map[string]func(SensorReading) float64{
+	"temperature": func(reading SensorReading) float64 { return reading.Temperature },
+	"pressure":    func(reading SensorReading) float64 { return reading.Pressure },
+	"humidity":    func(reading SensorReading) float64 { return reading.Humidity },
+	"oxidised":    func(reading SensorReading) float64 { return reading.Oxidised },
+	"reduced":     func(reading SensorReading) float64 { return reading.Reduced },
+	"nh3":         func(reading SensorReading) float64 { return reading.Nh3 },
+	"pm1":         func(reading SensorReading) float64 { return reading.PM1 },
+	"pm2":         func(reading SensorReading) float64 { return reading.PM2 },
+	"pm10":        func(reading SensorReading) float64 { return reading.PM10 },
+}
+
+var queryMetricKeys = sortedQueryMetricKeys()
+
+func sortedQueryMetricKeys() []string {
+	keys := make([]string, 0, len(queryMetricAccessors))
+	for key := range queryMetricAccessors {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+const defaultQueryStep = 60 * time.Second
+
+type metricBucket struct {
+	Timestamp int64    `json:"t"`
+	Avg       *float64 `json:"avg"`
+	Min       *float64 `json:"min"`
+	Max       *float64 `json:"max"`
+	Count     int      `json:"count"`
+}
+
+type metricStats struct {
+	Avg   *float64 `json:"avg"`
+	Min   *float64 `json:"min"`
+	Max   *float64 `json:"max"`
+	Count int      `json:"count"`
+}
+
+func (api *API) handleMetrics(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		writeError(response, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	writeJSON(response, http.StatusOK, map[string]any{"metrics": queryMetricKeys})
+}
+
+func (api *API) handleQueryRange(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		writeError(response, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	rangeStore, ok := api.store.(readingsRangeStore)
+	if !ok {
+		writeError(response, http.StatusNotImplemented, "range queries are not supported")
+		return
+	}
+
+	metrics, accessors, err := parseQueryMetrics(request)
+	if err != nil {
+		writeError(response, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	fromTimestamp, toTimestamp, err := parseQueryWindow(request)
+	if err != nil {
+		writeError(response, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	step, err := parseQueryStep(request)
+	if err != nil {
+		writeError(response, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	readings, err := rangeStore.Range(request.Context(), fromTimestamp, toTimestamp, maxReadingsLimit)
+	if err != nil {
+		writeError(response, http.StatusInternalServerError, "failed to read data")
+		return
+	}
+
+	stepSeconds := int64(step.Seconds())
+	if stepSeconds < 1 {
+		stepSeconds = 1
+	}
+	bucketCount := int((toTimestamp - fromTimestamp + stepSeconds - 1) / stepSeconds)
+	if bucketCount < 1 {
+		bucketCount = 1
+	}
+
+	series := make(map[string][]metricBucket, len(metrics))
+	for _, metric := range metrics {
+		series[metric] = bucketMetric(readings, accessors[metric], fromTimestamp, stepSeconds, bucketCount)
+	}
+
+	writeJSON(response, http.StatusOK, map[string]any{
+		"from":   fromTimestamp,
+		"to":     toTimestamp,
+		"step":   stepSeconds,
+		"series": series,
+	})
+}
+
+func (api *API) handleQuery(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		writeError(response, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	rangeStore, ok := api.store.(readingsRangeStore)
+	if !ok {
+		writeError(response, http.StatusNotImplemented, "range queries are not supported")
+		return
+	}
+
+	metrics, accessors, err := parseQueryMetrics(request)
+	if err != nil {
+		writeError(response, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	fromTimestamp, toTimestamp, err := parseQueryWindow(request)
+	if err != nil {
+		writeError(response, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	readings, err := rangeStore.Range(request.Context(), fromTimestamp, toTimestamp, maxReadingsLimit)
+	if err != nil {
+		writeError(response, http.StatusInternalServerError, "failed to read data")
+		return
+	}
+
+	stats := make(map[string]metricStats, len(metrics))
+	for _, metric := range metrics {
+		stats[metric] = statsForMetric(readings, accessors[metric])
+	}
+
+	writeJSON(response, http.StatusOK, map[string]any{
+		"from":  fromTimestamp,
+		"to":    toTimestamp,
+		"stats": stats,
+	})
+}
+
+func parseQueryMetrics(request *http.Request) ([]string, map[string]func(SensorReading) float64, error) {
+	rawMetrics := request.URL.Query()["metric"]
+	if len(rawMetrics) == 0 {
+		return nil, nil, fmt.Errorf("at least one metric is required")
+	}
+
+	metrics := make([]string, 0, len(rawMetrics))
+	accessors := make(map[string]func(SensorReading) float64, len(rawMetrics))
+	seen := make(map[string]struct{}, len(rawMetrics))
+
+	for _, metric := range rawMetrics {
+		accessor, ok := queryMetricAccessors[metric]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown metric: %s", metric)
+		}
+		if _, duplicate := seen[metric]; duplicate {
+			continue
+		}
+		seen[metric] = struct{}{}
+		metrics = append(metrics, metric)
+		accessors[metric] = accessor
+	}
+
+	return metrics, accessors, nil
+}
+
+func parseQueryWindow(request *http.Request) (int64, int64, error) {
+	rawFrom := request.URL.Query().Get("from")
+	rawTo := request.URL.Query().Get("to")
+	if rawFrom == "" || rawTo == "" {
+		return 0, 0, fmt.Errorf("from and to must be provided together")
+	}
+
+	fromTimestamp, err := parseReadingsTimestamp(rawFrom)
+	if err != nil {
+		return 0, 0, fmt.Errorf("from must be a valid unix timestamp")
+	}
+	toTimestamp, err := parseReadingsTimestamp(rawTo)
+	if err != nil {
+		return 0, 0, fmt.Errorf("to must be a valid unix timestamp")
+	}
+	if fromTimestamp >= toTimestamp {
+		return 0, 0, fmt.Errorf("from must be less than to")
+	}
+
+	return fromTimestamp, toTimestamp, nil
+}
+
+func parseQueryStep(request *http.Request) (time.Duration, error) {
+	rawStep := request.URL.Query().Get("step")
+	if rawStep == "" {
+		return defaultQueryStep, nil
+	}
+
+	step, err := time.ParseDuration(rawStep)
+	if err != nil || step <= 0 {
+		return 0, fmt.Errorf("step must be a valid duration")
+	}
+	return step, nil
+}
+
+// bucketMetric groups readings into bucketCount uniformly-spaced buckets of
+// stepSeconds starting at fromTimestamp, computing avg/min/max/count per
+// bucket and leaving gaps as explicit null buckets so sparse data still
+// plots correctly on the frontend.
+func bucketMetric(
+	readings []SensorReading,
+	accessor func(SensorReading) float64,
+	fromTimestamp int64,
+	stepSeconds int64,
+	bucketCount int,
+) []metricBucket {
+	sums := make([]float64, bucketCount)
+	mins := make([]float64, bucketCount)
+	maxs := make([]float64, bucketCount)
+	counts := make([]int, bucketCount)
+	for index := range mins {
+		mins[index] = math.Inf(1)
+		maxs[index] = math.Inf(-1)
+	}
+
+	for _, reading := range readings {
+		value := accessor(reading)
+		if math.IsNaN(value) {
+			continue
+		}
+
+		bucketIndex := int((reading.Timestamp - fromTimestamp) / stepSeconds)
+		if bucketIndex < 0 || bucketIndex >= bucketCount {
+			continue
+		}
+
+		sums[bucketIndex] += value
+		counts[bucketIndex]++
+		if value < mins[bucketIndex] {
+			mins[bucketIndex] = value
+		}
+		if value > maxs[bucketIndex] {
+			maxs[bucketIndex] = value
+		}
+	}
+
+	buckets := make([]metricBucket, bucketCount)
+	for index := 0; index < bucketCount; index++ {
+		bucket := metricBucket{Timestamp: fromTimestamp + int64(index)*stepSeconds}
+		if counts[index] > 0 {
+			avg := sums[index] / float64(counts[index])
+			minimum := mins[index]
+			maximum := maxs[index]
+			bucket.Avg = &avg
+			bucket.Min = &minimum
+			bucket.Max = &maximum
+			bucket.Count = counts[index]
+		}
+		buckets[index] = bucket
+	}
+
+	return buckets
+}
+
+func statsForMetric(readings []SensorReading, accessor func(SensorReading) float64) metricStats {
+	var sum float64
+	var count int
+	minimum := math.Inf(1)
+	maximum := math.Inf(-1)
+
+	for _, reading := range readings {
+		value := accessor(reading)
+		if math.IsNaN(value) {
+			continue
+		}
+		sum += value
+		count++
+		if value < minimum {
+			minimum = value
+		}
+		if value > maximum {
+			maximum = value
+		}
+	}
+
+	if count == 0 {
+		return metricStats{}
+	}
+
+	avg := sum / float64(count)
+	return metricStats{Avg: &avg, Min: &minimum, Max: &maximum, Count: count}
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleMetricsListsKnownMetrics(t *testing.T) {
+	store := &fakeStore{}
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	request := httptest.NewRequest(http.MethodGet, "/api/metrics", nil)
+	response := httptest.NewRecorder()
+
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), `"pm2"`) {
+		t.Fatalf("expected pm2 in metrics list, got %s", response.Body.String())
+	}
+}
+
+func TestHandleQueryRangeBucketsReadings(t *testing.T) {
+	store := &fakeStore{
+		ranged: []SensorReading{
+			{Timestamp: 1738886400, PM2: 2.0},
+			{Timestamp: 1738886410, PM2: 4.0},
+			{Timestamp: 1738886460, PM2: 10.0},
+		},
+	}
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	request := httptest.NewRequest(
+		http.MethodGet,
+		"/api/query_range?metric=pm2&from=1738886400&to=1738886610&step=60s",
+		nil,
+	)
+	response := httptest.NewRecorder()
+
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, response.Code, response.Body.String())
+	}
+	if !strings.Contains(response.Body.String(), `"avg":3`) {
+		t.Fatalf("expected first bucket avg of 3, got %s", response.Body.String())
+	}
+	if !strings.Contains(response.Body.String(), `"avg":null`) {
+		t.Fatalf("expected a null bucket for the gap, got %s", response.Body.String())
+	}
+}
+
+func TestHandleQueryRangeRejectsUnknownMetric(t *testing.T) {
+	store := &fakeStore{}
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	request := httptest.NewRequest(
+		http.MethodGet,
+		"/api/query_range?metric=co2&from=1738886400&to=1738886520",
+		nil,
+	)
+	response := httptest.NewRecorder()
+
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, response.Code)
+	}
+}
+
+func TestHandleQueryReturnsAggregateStats(t *testing.T) {
+	store := &fakeStore{
+		ranged: []SensorReading{
+			{Timestamp: 1738886400, PM2: 2.0},
+			{Timestamp: 1738886460, PM2: 6.0},
+		},
+	}
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	request := httptest.NewRequest(
+		http.MethodGet,
+		"/api/query?metric=pm2&from=1738886400&to=1738886520&stats=true",
+		nil,
+	)
+	response := httptest.NewRecorder()
+
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, response.Code, response.Body.String())
+	}
+	if !strings.Contains(response.Body.String(), `"avg":4`) {
+		t.Fatalf("expected aggregate avg of 4, got %s", response.Body.String())
+	}
+}
@@ -0,0 +1,215 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// readingsStreamEnvelope is the JSON payload pushed over
+// /api/readings/stream's WebSocket mode; SSE mode carries the same
+// event/id/data split via the id:/event:/data: lines instead.
+type readingsStreamEnvelope struct {
+	Event string `json:"event"`
+	ID    int64  `json:"id"`
+	Data  any    `json:"data"`
+}
+
+// handleReadingsStream serves a single combined live feed of readings
+// (event: reading), ops events (event: ops_event), and fired alerts
+// (event: alert) -- as Server-Sent Events by default, or as a WebSocket
+// when the client sends Upgrade: websocket. ?fields= narrows reading
+// payloads the same way /api/stream does, and Last-Event-ID/?since=
+// replays readings missed since a previous connection from the stream
+// hub's history; ops_event and alert carry only what is published after
+// the client subscribes, with no replay, unlike /api/alerts/stream, which
+// replays alerts after Last-Event-ID/?since=.
+func (api *API) handleReadingsStream(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		writeError(response, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !api.authorizeReadRequest(response, request) {
+		return
+	}
+
+	sinceEventID, err := parseLastEventID(request)
+	if err != nil {
+		writeError(response, http.StatusBadRequest, "since must be a valid event id")
+		return
+	}
+
+	rawFields := request.URL.Query().Get("fields")
+	if rawFields == "" {
+		rawFields = request.URL.Query().Get("filter")
+	}
+	fields := parseStreamFields(rawFields)
+
+	readingSub, unsubscribeReadings := api.stream.subscribe(sinceEventID, fields)
+	defer unsubscribeReadings()
+	opsSub, unsubscribeOps := api.opsEventStream.subscribe(0)
+	defer unsubscribeOps()
+	alertSub, unsubscribeAlerts := api.alertStream.subscribe(0)
+	defer unsubscribeAlerts()
+
+	if isWebSocketUpgrade(request) {
+		api.serveReadingsWebSocket(response, request, readingSub, opsSub, alertSub)
+		return
+	}
+	api.serveReadingsSSE(response, request, readingSub, opsSub, alertSub)
+}
+
+func (api *API) serveReadingsSSE(response http.ResponseWriter, request *http.Request, readingSub *streamSubscriber, opsSub *opsEventSubscriber, alertSub *alertStreamSubscriber) {
+	flusher, ok := response.(http.Flusher)
+	if !ok {
+		writeError(response, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	response.Header().Set("Content-Type", "text/event-stream")
+	response.Header().Set("Cache-Control", "no-cache")
+	response.Header().Set("Connection", "keep-alive")
+	response.Header().Set("X-Accel-Buffering", "no")
+
+	controller := http.NewResponseController(response)
+	heartbeatTicker := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeatTicker.Stop()
+
+	for {
+		select {
+		case <-request.Context().Done():
+			return
+		case <-api.lifecycleCtx.Done():
+			_ = controller.SetWriteDeadline(time.Now().Add(streamWriteDeadline))
+			_, _ = io.WriteString(response, "event: server_shutdown\ndata: {}\n\n")
+			flusher.Flush()
+			return
+		case event, ok := <-readingSub.events:
+			if !ok {
+				// Evicted by the hub as a slow consumer.
+				return
+			}
+			payload, err := json.Marshal(projectReading(event.Reading, readingSub.fields))
+			if err != nil {
+				continue
+			}
+			_ = controller.SetWriteDeadline(time.Now().Add(streamWriteDeadline))
+			if _, err = fmt.Fprintf(response, "id: %d\nevent: reading\ndata: %s\n\n", event.ID, payload); err != nil {
+				api.stream.reportDropped()
+				return
+			}
+			flusher.Flush()
+		case event, ok := <-opsSub.events:
+			if !ok {
+				return
+			}
+			payload, err := json.Marshal(event.Event)
+			if err != nil {
+				continue
+			}
+			_ = controller.SetWriteDeadline(time.Now().Add(streamWriteDeadline))
+			if _, err = fmt.Fprintf(response, "id: %d\nevent: ops_event\ndata: %s\n\n", event.ID, payload); err != nil {
+				return
+			}
+			flusher.Flush()
+		case event, ok := <-alertSub.events:
+			if !ok {
+				return
+			}
+			payload, err := json.Marshal(map[string]any{"source": event.Source, "alerts": event.Alerts})
+			if err != nil {
+				continue
+			}
+			_ = controller.SetWriteDeadline(time.Now().Add(streamWriteDeadline))
+			if _, err = fmt.Fprintf(response, "id: %d\nevent: alert\ndata: %s\n\n", event.ID, payload); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeatTicker.C:
+			_ = controller.SetWriteDeadline(time.Now().Add(streamWriteDeadline))
+			if _, err := io.WriteString(response, ": ping\n\n"); err != nil {
+				api.stream.reportDropped()
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (api *API) serveReadingsWebSocket(response http.ResponseWriter, request *http.Request, readingSub *streamSubscriber, opsSub *opsEventSubscriber, alertSub *alertStreamSubscriber) {
+	ws, err := upgradeWebSocket(response, request)
+	if err != nil {
+		writeError(response, http.StatusBadRequest, "websocket upgrade failed")
+		return
+	}
+	defer ws.close()
+
+	closed := make(chan struct{})
+	go func() {
+		ws.waitClosed()
+		close(closed)
+	}()
+
+	heartbeatTicker := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeatTicker.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-request.Context().Done():
+			return
+		case <-api.lifecycleCtx.Done():
+			_ = ws.writeText([]byte(`{"event":"server_shutdown"}`))
+			return
+		case event, ok := <-readingSub.events:
+			if !ok {
+				return
+			}
+			payload, err := json.Marshal(readingsStreamEnvelope{
+				Event: "reading",
+				ID:    event.ID,
+				Data:  projectReading(event.Reading, readingSub.fields),
+			})
+			if err != nil {
+				continue
+			}
+			if err := ws.writeText(payload); err != nil {
+				api.stream.reportDropped()
+				return
+			}
+		case event, ok := <-opsSub.events:
+			if !ok {
+				return
+			}
+			payload, err := json.Marshal(readingsStreamEnvelope{Event: "ops_event", ID: event.ID, Data: event.Event})
+			if err != nil {
+				continue
+			}
+			if err := ws.writeText(payload); err != nil {
+				return
+			}
+		case event, ok := <-alertSub.events:
+			if !ok {
+				return
+			}
+			payload, err := json.Marshal(readingsStreamEnvelope{
+				Event: "alert",
+				ID:    event.ID,
+				Data:  map[string]any{"source": event.Source, "alerts": event.Alerts},
+			})
+			if err != nil {
+				continue
+			}
+			if err := ws.writeText(payload); err != nil {
+				return
+			}
+		case <-heartbeatTicker.C:
+			if err := ws.writeFrame(wsOpcodePing, nil); err != nil {
+				return
+			}
+		}
+	}
+}
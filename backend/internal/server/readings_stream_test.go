@@ -0,0 +1,205 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBodyRecorder wraps httptest.NewRecorder so a test goroutine can poll
+// the written body while the handler goroutine is still writing to it.
+// httptest.ResponseRecorder's Body is a plain *bytes.Buffer with no
+// synchronization of its own, so reading it from one goroutine while an SSE
+// handler writes from another is a data race; every access here goes
+// through mu instead.
+type syncBodyRecorder struct {
+	mu       sync.Mutex
+	recorder *httptest.ResponseRecorder
+}
+
+func newSyncBodyRecorder() *syncBodyRecorder {
+	return &syncBodyRecorder{recorder: httptest.NewRecorder()}
+}
+
+func (w *syncBodyRecorder) Header() http.Header {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.recorder.Header()
+}
+
+func (w *syncBodyRecorder) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.recorder.Write(data)
+}
+
+func (w *syncBodyRecorder) WriteHeader(statusCode int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.recorder.WriteHeader(statusCode)
+}
+
+func (w *syncBodyRecorder) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.recorder.Flush()
+}
+
+func (w *syncBodyRecorder) Body() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.recorder.Body.String()
+}
+
+func ingestTestReading(t *testing.T, handler http.Handler, timestamp string) {
+	t.Helper()
+
+	body := `{
+		"timestamp":"` + timestamp + `",
+		"temperature":"22.4",
+		"pressure":"101305",
+		"humidity":"40.1",
+		"oxidised":"1.2",
+		"reduced":"1.1",
+		"nh3":"0.7",
+		"pm1":"2",
+		"pm2":"3",
+		"pm10":"4"
+	}`
+
+	request := httptest.NewRequest(http.MethodPost, "/api/ingest", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-API-Key", "secret")
+	response := httptest.NewRecorder()
+
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, response.Code, response.Body.String())
+	}
+}
+
+func TestHandleReadingsStreamDeliversExactlyOneEventPerIngest(t *testing.T) {
+	store := &fakeStore{}
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	subscriber, unsubscribe := api.stream.subscribe(0, nil)
+	defer unsubscribe()
+
+	ingestTestReading(t, handler, "1738886400")
+
+	select {
+	case event := <-subscriber.events:
+		if event.Reading.Timestamp != 1738886400 {
+			t.Fatalf("expected timestamp 1738886400, got %d", event.Reading.Timestamp)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a published reading event")
+	}
+
+	select {
+	case event := <-subscriber.events:
+		t.Fatalf("expected exactly one event, got a second: %+v", event)
+	default:
+	}
+}
+
+func TestHandleReadingsStreamOversubscribedDoesNotBlockNextIngest(t *testing.T) {
+	store := &fakeStore{}
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	dropped := make(chan struct{}, 1)
+	api.stream.onSlowConsumerDropped = func() {
+		select {
+		case dropped <- struct{}{}:
+		default:
+		}
+	}
+
+	subscriber, unsubscribe := api.stream.subscribe(0, nil)
+	defer unsubscribe()
+
+	for i := 0; i < streamSubscriberBuffer+1; i++ {
+		api.stream.publish(SensorReading{Timestamp: int64(i)})
+	}
+
+	select {
+	case <-dropped:
+	case <-time.After(time.Second):
+		t.Fatal("expected the oversubscribed client to be evicted")
+	}
+
+	for {
+		if _, ok := <-subscriber.events; !ok {
+			break
+		}
+	}
+
+	ingestDone := make(chan struct{})
+	go func() {
+		ingestTestReading(t, handler, "1738886500")
+		close(ingestDone)
+	}()
+
+	select {
+	case <-ingestDone:
+	case <-time.After(time.Second):
+		t.Fatal("expected ingest to complete without blocking on the evicted subscriber")
+	}
+}
+
+func TestHandleReadingsStreamLastEventIDReplaysSuffix(t *testing.T) {
+	store := &fakeStore{}
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	api.stream.publish(SensorReading{Timestamp: 1})
+	api.stream.publish(SensorReading{Timestamp: 2})
+	api.stream.publish(SensorReading{Timestamp: 3})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	request := httptest.NewRequest(http.MethodGet, "/api/readings/stream", nil).WithContext(ctx)
+	request.Header.Set("Last-Event-ID", "1")
+	request.Header.Set("X-API-Key", "secret")
+	response := newSyncBodyRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(response, request)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		body := response.Body()
+		if strings.Count(body, "event: reading") >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for replay, got body: %s", body)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+
+	body := response.Body()
+	if !strings.Contains(body, `"timestamp":2`) {
+		t.Fatalf("expected replay to include timestamp 2, got: %s", body)
+	}
+	if !strings.Contains(body, `"timestamp":3`) {
+		t.Fatalf("expected replay to include timestamp 3, got: %s", body)
+	}
+	if strings.Contains(body, "id: 1\n") {
+		t.Fatalf("expected replay to resume after event id 1, got: %s", body)
+	}
+}
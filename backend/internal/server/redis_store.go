@@ -0,0 +1,224 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ReadingCodec encodes and decodes a SensorReading for storage in a single
+// Redis stream field, so RedisStore can trade JSON's portability for
+// msgpack's smaller entries without changing the stream layout.
+type ReadingCodec interface {
+	Encode(reading SensorReading) ([]byte, error)
+	Decode(data []byte) (SensorReading, error)
+}
+
+type jsonReadingCodec struct{}
+
+func (jsonReadingCodec) Encode(reading SensorReading) ([]byte, error) { return json.Marshal(reading) }
+
+func (jsonReadingCodec) Decode(data []byte) (SensorReading, error) {
+	var reading SensorReading
+	err := json.Unmarshal(data, &reading)
+	return reading, err
+}
+
+// MsgpackReadingCodec returns a ReadingCodec that encodes readings with
+// msgpack instead of the default JSON, for smaller stream entries.
+func MsgpackReadingCodec() ReadingCodec { return msgpackReadingCodec{} }
+
+type msgpackReadingCodec struct{}
+
+func (msgpackReadingCodec) Encode(reading SensorReading) ([]byte, error) {
+	return msgpack.Marshal(reading)
+}
+
+func (msgpackReadingCodec) Decode(data []byte) (SensorReading, error) {
+	var reading SensorReading
+	err := msgpack.Unmarshal(data, &reading)
+	return reading, err
+}
+
+// RedisStore is a Store backed by a Redis stream, letting multiple
+// enviro-station processes (e.g. one running the InsightsScheduler, another
+// only serving the read API) share reading history and the latest
+// InsightsSnapshot without each running its own database.
+type RedisStore struct {
+	client      redis.UniversalClient
+	keyPrefix   string
+	maxReadings int64
+	codec       ReadingCodec
+}
+
+type RedisStoreOption func(*RedisStore)
+
+// WithRedisCodec overrides the default JSON encoding of stream entries, for
+// example to use WithRedisCodec(msgpackReadingCodec{}) for smaller entries.
+func WithRedisCodec(codec ReadingCodec) RedisStoreOption {
+	return func(store *RedisStore) {
+		store.codec = codec
+	}
+}
+
+// NewRedisStore returns a Store backed by client, a Redis Stream at
+// "<keyPrefix>:readings" trimmed (approximately, via XADD MAXLEN ~) to
+// maxReadings entries, and a "<keyPrefix>:insights" key holding the latest
+// InsightsSnapshot as JSON.
+func NewRedisStore(client redis.UniversalClient, keyPrefix string, maxReadings int, options ...RedisStoreOption) *RedisStore {
+	if maxReadings <= 0 {
+		maxReadings = 10000
+	}
+
+	store := &RedisStore{
+		client:      client,
+		keyPrefix:   keyPrefix,
+		maxReadings: int64(maxReadings),
+		codec:       jsonReadingCodec{},
+	}
+	for _, option := range options {
+		option(store)
+	}
+	return store
+}
+
+func (store *RedisStore) readingsStreamKey() string {
+	return store.keyPrefix + ":readings"
+}
+
+func (store *RedisStore) insightsKey() string {
+	return store.keyPrefix + ":insights"
+}
+
+func (store *RedisStore) Add(ctx context.Context, reading SensorReading) error {
+	return store.AddBatch(ctx, []SensorReading{reading})
+}
+
+func (store *RedisStore) AddBatch(ctx context.Context, readings []SensorReading) error {
+	if len(readings) == 0 {
+		return nil
+	}
+
+	streamKey := store.readingsStreamKey()
+	pipeline := store.client.Pipeline()
+	for _, reading := range readings {
+		payload, err := store.codec.Encode(reading)
+		if err != nil {
+			return fmt.Errorf("encode reading: %w", err)
+		}
+
+		pipeline.XAdd(ctx, &redis.XAddArgs{
+			Stream: streamKey,
+			MaxLen: store.maxReadings,
+			Approx: true,
+			Values: map[string]any{"data": payload},
+		})
+	}
+
+	_, err := pipeline.Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("xadd readings: %w", err)
+	}
+	return nil
+}
+
+func (store *RedisStore) Count(ctx context.Context) (int, error) {
+	length, err := store.client.XLen(ctx, store.readingsStreamKey()).Result()
+	if err != nil {
+		return 0, fmt.Errorf("xlen readings: %w", err)
+	}
+	return int(length), nil
+}
+
+// Latest returns the most recent limit readings in chronological order,
+// read from the tail of the stream with XRevRange and reversed in place.
+func (store *RedisStore) Latest(ctx context.Context, limit int) ([]SensorReading, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	messages, err := store.client.XRevRangeN(ctx, store.readingsStreamKey(), "+", "-", int64(limit)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("xrevrange readings: %w", err)
+	}
+
+	readings := make([]SensorReading, 0, len(messages))
+	for _, message := range messages {
+		reading, err := store.decodeMessage(message)
+		if err != nil {
+			return nil, err
+		}
+		readings = append(readings, reading)
+	}
+
+	for left, right := 0, len(readings)-1; left < right; left, right = left+1, right-1 {
+		readings[left], readings[right] = readings[right], readings[left]
+	}
+
+	return readings, nil
+}
+
+func (store *RedisStore) decodeMessage(message redis.XMessage) (SensorReading, error) {
+	raw, ok := message.Values["data"]
+	if !ok {
+		return SensorReading{}, fmt.Errorf("stream entry %s missing data field", message.ID)
+	}
+
+	var payload []byte
+	switch typed := raw.(type) {
+	case string:
+		payload = []byte(typed)
+	case []byte:
+		payload = typed
+	default:
+		return SensorReading{}, fmt.Errorf("stream entry %s has unexpected data type %T", message.ID, raw)
+	}
+
+	reading, err := store.codec.Decode(payload)
+	if err != nil {
+		return SensorReading{}, fmt.Errorf("decode stream entry %s: %w", message.ID, err)
+	}
+	return reading, nil
+}
+
+func (store *RedisStore) Ping(ctx context.Context) error {
+	return store.client.Ping(ctx).Err()
+}
+
+func (store *RedisStore) Close() {
+	_ = store.client.Close()
+}
+
+func (store *RedisStore) SaveInsightsSnapshot(ctx context.Context, snapshot InsightsSnapshot) error {
+	payload, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("marshal snapshot: %w", err)
+	}
+
+	if err := store.client.Set(ctx, store.insightsKey(), payload, 0).Err(); err != nil {
+		return fmt.Errorf("set insights snapshot: %w", err)
+	}
+	return nil
+}
+
+func (store *RedisStore) LatestInsightsSnapshot(ctx context.Context) (InsightsSnapshot, bool, error) {
+	payload, err := store.client.Get(ctx, store.insightsKey()).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return InsightsSnapshot{}, false, nil
+		}
+		return InsightsSnapshot{}, false, fmt.Errorf("get insights snapshot: %w", err)
+	}
+
+	var snapshot InsightsSnapshot
+	if err := json.Unmarshal(payload, &snapshot); err != nil {
+		return InsightsSnapshot{}, false, fmt.Errorf("unmarshal insights snapshot: %w", err)
+	}
+	return snapshot, true, nil
+}
+
+var _ Store = (*RedisStore)(nil)
+var _ InsightsSnapshotStore = (*RedisStore)(nil)
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"time"
+)
+
+// requestIDContextKey is an unexported type so values stored under it can
+// never collide with a key set by another package using context.WithValue.
+type requestIDContextKey struct{}
+
+// WithRequestID returns a copy of ctx carrying requestID, retrievable by
+// RequestIDFromContext. main's request-ID middleware calls this for every
+// incoming request; background work that isn't driven by one request
+// (InsightsScheduler.recompute, the retention worker) mints its own ID
+// instead so its log lines still correlate to one run.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID stashed by WithRequestID, or
+// "" if ctx doesn't carry one.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey{}).(string)
+	return requestID
+}
+
+// NewRequestID mints a random UUIDv7: time-ordered so request IDs sort and
+// roughly bucket by arrival time in log aggregation, unlike UUIDv4. Used
+// whenever TRUST_REQUEST_ID is false or a request didn't supply its own
+// X-Request-ID.
+func NewRequestID() string {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+
+	millis := time.Now().UnixMilli()
+	id[0] = byte(millis >> 40)
+	id[1] = byte(millis >> 32)
+	id[2] = byte(millis >> 24)
+	id[3] = byte(millis >> 16)
+	id[4] = byte(millis >> 8)
+	id[5] = byte(millis)
+
+	id[6] = (id[6] & 0x0F) | 0x70 // version 7
+	id[8] = (id[8] & 0x3F) | 0x80 // RFC 4122 variant
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16])
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"log/slog"
 	"net"
 	"net/http"
 	"strings"
@@ -13,6 +14,7 @@ type requestLimiter struct {
 	limit   int
 	window  time.Duration
 	entries map[string]requestWindow
+	log     *slog.Logger
 }
 
 type requestWindow struct {
@@ -32,10 +34,13 @@ func newRequestLimiter(limit int, window time.Duration) *requestLimiter {
 		limit:   limit,
 		window:  window,
 		entries: map[string]requestWindow{},
+		log:     logger.With("component", "ratelimit"),
 	}
 }
 
-func (limiter *requestLimiter) Allow(key string, now time.Time) bool {
+// Allow reports whether a request identified by key may proceed, bumping
+// enviro_rate_limit_rejected_total{route} on rejection.
+func (limiter *requestLimiter) Allow(route string, key string, now time.Time) bool {
 	if key == "" {
 		key = "unknown"
 	}
@@ -50,6 +55,9 @@ func (limiter *requestLimiter) Allow(key string, now time.Time) bool {
 
 	if window.count >= limiter.limit {
 		limiter.entries[key] = window
+		rateLimitRejectedTotal.WithLabelValues(route).Inc()
+		limiter.log.Warn("request rejected",
+			"key", key, "limit", limiter.limit, "window", limiter.window, "route", route)
 		return false
 	}
 
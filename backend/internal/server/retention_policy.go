@@ -0,0 +1,189 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RetentionPolicy is a named retention/downsampling tier for PostgresStore,
+// modelled after InfluxDB's RetentionPolicyInfo: Duration bounds how long
+// raw sensor_readings governed by this policy are kept, Resolution (when
+// non-zero) asks the rollup scheduler in rollup.go to maintain a
+// downsampled copy of this policy in sensor_readings_rollup at that bucket
+// width, and Replication is carried through for schema parity with
+// Influx's multi-node policies -- this single-node PostgresStore doesn't
+// act on it itself.
+type RetentionPolicy struct {
+	Name        string
+	Duration    time.Duration
+	Resolution  time.Duration
+	Replication int
+}
+
+// DefaultRetentionPolicyName is the raw-resolution policy cmd/server's
+// retention worker keeps in sync with RETENTION_DAYS, so DeleteOlderThan
+// has a policy to act on even when no operator has defined one explicitly.
+const DefaultRetentionPolicyName = "default"
+
+// UpsertRetentionPolicy creates or updates a named retention policy.
+func (store *PostgresStore) UpsertRetentionPolicy(ctx context.Context, policy RetentionPolicy) error {
+	if policy.Name == "" {
+		return fmt.Errorf("retention policy name is required")
+	}
+	if policy.Duration <= 0 {
+		return fmt.Errorf("retention policy duration must be > 0")
+	}
+	if policy.Resolution < 0 {
+		return fmt.Errorf("retention policy resolution must be >= 0")
+	}
+
+	const query = `
+INSERT INTO retention_policies (name, duration_seconds, resolution_seconds, replication)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (name) DO UPDATE SET
+  duration_seconds = EXCLUDED.duration_seconds,
+  resolution_seconds = EXCLUDED.resolution_seconds,
+  replication = EXCLUDED.replication,
+  updated_at = NOW()
+`
+
+	_, err := store.pool.Exec(
+		ctx,
+		query,
+		policy.Name,
+		int64(policy.Duration.Seconds()),
+		int64(policy.Resolution.Seconds()),
+		policy.Replication,
+	)
+	return err
+}
+
+// ListRetentionPolicies returns every configured retention policy, ordered
+// by name.
+func (store *PostgresStore) ListRetentionPolicies(ctx context.Context) ([]RetentionPolicy, error) {
+	const query = `
+SELECT name, duration_seconds, resolution_seconds, replication
+FROM retention_policies
+ORDER BY name
+`
+
+	rows, err := store.pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []RetentionPolicy
+	for rows.Next() {
+		var policy RetentionPolicy
+		var durationSeconds, resolutionSeconds int64
+		if err := rows.Scan(&policy.Name, &durationSeconds, &resolutionSeconds, &policy.Replication); err != nil {
+			return nil, err
+		}
+		policy.Duration = time.Duration(durationSeconds) * time.Second
+		policy.Resolution = time.Duration(resolutionSeconds) * time.Second
+		policies = append(policies, policy)
+	}
+
+	return policies, rows.Err()
+}
+
+// DeleteRetentionPolicy removes a named policy. Its rollup rows go with it
+// via sensor_readings_rollup's ON DELETE CASCADE.
+func (store *PostgresStore) DeleteRetentionPolicy(ctx context.Context, name string) error {
+	const query = `DELETE FROM retention_policies WHERE name = $1`
+	_, err := store.pool.Exec(ctx, query, name)
+	return err
+}
+
+// DeleteOlderThan prunes raw sensor_readings against the longest configured
+// raw-resolution (Resolution == 0) policy's Duration, and prunes each
+// downsampled policy's sensor_readings_rollup rows against that policy's
+// own Duration. A deployment with no retention policies configured deletes
+// nothing, which is the honest behavior for a policy-driven sweep rather
+// than falling back to some implicit global cutoff. limit bounds each
+// individual DELETE so a large backlog is swept incrementally across
+// startRetentionWorker's ticks rather than in one long-running transaction.
+func (store *PostgresStore) DeleteOlderThan(ctx context.Context, limit int) (int64, error) {
+	if limit <= 0 {
+		limit = 1000
+	}
+
+	policies, err := store.ListRetentionPolicies(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var totalDeleted int64
+	now := time.Now()
+
+	var rawRetention time.Duration
+	for _, policy := range policies {
+		if policy.Resolution == 0 && policy.Duration > rawRetention {
+			rawRetention = policy.Duration
+		}
+	}
+	if rawRetention > 0 {
+		deleted, err := store.deleteRowsOlderThan(ctx, "sensor_readings", now.Add(-rawRetention).Unix(), limit)
+		if err != nil {
+			return totalDeleted, err
+		}
+		totalDeleted += deleted
+	}
+
+	for _, policy := range policies {
+		if policy.Resolution == 0 {
+			continue
+		}
+		deleted, err := store.deleteRollupOlderThan(ctx, policy.Name, now.Add(-policy.Duration).Unix(), limit)
+		if err != nil {
+			return totalDeleted, err
+		}
+		totalDeleted += deleted
+	}
+
+	return totalDeleted, nil
+}
+
+func (store *PostgresStore) deleteRowsOlderThan(ctx context.Context, table string, cutoffTimestamp int64, limit int) (int64, error) {
+	query := fmt.Sprintf(`
+WITH expired AS (
+  SELECT id
+  FROM %s
+  WHERE timestamp < $1
+  ORDER BY timestamp
+  LIMIT $2
+)
+DELETE FROM %s AS target
+USING expired
+WHERE target.id = expired.id
+`, table, table)
+
+	result, err := store.pool.Exec(ctx, query, cutoffTimestamp, limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+func (store *PostgresStore) deleteRollupOlderThan(ctx context.Context, policyName string, cutoffTimestamp int64, limit int) (int64, error) {
+	const query = `
+WITH expired AS (
+  SELECT id
+  FROM sensor_readings_rollup
+  WHERE policy = $1 AND bucket_start < $2
+  ORDER BY bucket_start
+  LIMIT $3
+)
+DELETE FROM sensor_readings_rollup AS target
+USING expired
+WHERE target.id = expired.id
+`
+
+	result, err := store.pool.Exec(ctx, query, policyName, cutoffTimestamp, limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
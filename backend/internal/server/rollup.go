@@ -0,0 +1,335 @@
+package server
+
+import (
+	"context"
+	"time"
+)
+
+// rollupSchedulerInterval is how often startRollupScheduler wakes up to
+// check whether any policy with a non-zero Resolution has complete
+// buckets ready to aggregate.
+const rollupSchedulerInterval = time.Minute
+
+// maxRollupBucketsPerSweep bounds how many buckets rollupPolicy will
+// backfill for a single policy in one pass, so a scheduler that's fallen
+// far behind (e.g. after downtime) catches up gradually across several
+// ticks instead of holding the pool busy with one long sweep.
+const maxRollupBucketsPerSweep = 180
+
+// startRollupScheduler launches the background goroutine that keeps
+// sensor_readings_rollup current for every policy with Resolution > 0. It
+// runs for ctx's lifetime, which NewPostgresStore derives independently of
+// its own setup ctx so the scheduler survives past a short setup timeout
+// and is only stopped by Close.
+func (store *PostgresStore) startRollupScheduler(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(rollupSchedulerInterval)
+		defer ticker.Stop()
+
+		store.runRollupSweep(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				store.runRollupSweep(ctx)
+			}
+		}
+	}()
+}
+
+// runRollupSweep aggregates new complete buckets for every policy with a
+// non-zero Resolution. A policy whose rollup fails is logged and skipped
+// rather than aborting the sweep, so one bad policy doesn't stall rollups
+// for the rest.
+func (store *PostgresStore) runRollupSweep(ctx context.Context) {
+	policies, err := store.ListRetentionPolicies(ctx)
+	if err != nil {
+		logger.Error("rollup sweep: list retention policies failed", "error", err)
+		return
+	}
+
+	for _, policy := range policies {
+		if policy.Resolution <= 0 {
+			continue
+		}
+		if err := store.rollupPolicy(ctx, policy); err != nil {
+			logger.Error("rollup sweep failed", "policy", policy.Name, "error", err)
+		}
+	}
+}
+
+// rollupPolicy aggregates every complete bucket of policy.Resolution width
+// that hasn't been rolled up yet, starting just after the latest existing
+// sensor_readings_rollup row for this policy (or the earliest raw reading,
+// if it's never been rolled up before), and stopping once it reaches a
+// bucket that isn't complete yet (its end is still in the future) or
+// maxRollupBucketsPerSweep, whichever comes first.
+func (store *PostgresStore) rollupPolicy(ctx context.Context, policy RetentionPolicy) error {
+	resolutionSeconds := int64(policy.Resolution.Seconds())
+	if resolutionSeconds <= 0 {
+		return nil
+	}
+
+	bucketStart, err := store.nextRollupBucketStart(ctx, policy.Name, resolutionSeconds)
+	if err != nil {
+		return err
+	}
+	if bucketStart == 0 {
+		return nil
+	}
+
+	now := time.Now().Unix()
+	for processed := 0; processed < maxRollupBucketsPerSweep; processed++ {
+		bucketEnd := bucketStart + resolutionSeconds
+		if bucketEnd > now {
+			return nil
+		}
+
+		if err := store.rollupBucket(ctx, policy.Name, bucketStart, bucketEnd); err != nil {
+			return err
+		}
+
+		bucketStart = bucketEnd
+	}
+
+	return nil
+}
+
+// nextRollupBucketStart returns the start of the next bucket rollupPolicy
+// should aggregate for policyName: just after the latest bucket already
+// present in sensor_readings_rollup, or the earliest raw reading's
+// timestamp floored to a resolutionSeconds boundary if this policy has
+// never been rolled up. It returns 0 if there's no raw data yet at all.
+func (store *PostgresStore) nextRollupBucketStart(ctx context.Context, policyName string, resolutionSeconds int64) (int64, error) {
+	const latestRollupQuery = `SELECT MAX(bucket_start) FROM sensor_readings_rollup WHERE policy = $1`
+	var latestBucketStart *int64
+	if err := store.pool.QueryRow(ctx, latestRollupQuery, policyName).Scan(&latestBucketStart); err != nil {
+		return 0, err
+	}
+	if latestBucketStart != nil {
+		return *latestBucketStart + resolutionSeconds, nil
+	}
+
+	const earliestReadingQuery = `SELECT MIN(timestamp) FROM sensor_readings`
+	var earliestTimestamp *int64
+	if err := store.pool.QueryRow(ctx, earliestReadingQuery).Scan(&earliestTimestamp); err != nil {
+		return 0, err
+	}
+	if earliestTimestamp == nil {
+		return 0, nil
+	}
+
+	return floorToBucket(*earliestTimestamp, resolutionSeconds), nil
+}
+
+// floorToBucket rounds timestamp down to the start of the resolutionSeconds
+// bucket it falls in.
+func floorToBucket(timestamp int64, resolutionSeconds int64) int64 {
+	return timestamp - (timestamp % resolutionSeconds)
+}
+
+// rollupBucket aggregates raw sensor_readings in [bucketStart, bucketEnd)
+// into a single sensor_readings_rollup row for policyName, averaging and
+// taking the min/max of every numeric column. An empty bucket (no raw rows
+// fell in the window, e.g. a gap in ingestion) is skipped rather than
+// written as an all-null row.
+func (store *PostgresStore) rollupBucket(ctx context.Context, policyName string, bucketStart int64, bucketEnd int64) error {
+	const aggregateQuery = `
+SELECT
+  COUNT(*),
+  AVG(temperature), MIN(temperature), MAX(temperature),
+  AVG(pressure), MIN(pressure), MAX(pressure),
+  AVG(humidity), MIN(humidity), MAX(humidity),
+  AVG(oxidised), MIN(oxidised), MAX(oxidised),
+  AVG(reduced), MIN(reduced), MAX(reduced),
+  AVG(nh3), MIN(nh3), MAX(nh3),
+  AVG(pm1), MIN(pm1), MAX(pm1),
+  AVG(pm2), MIN(pm2), MAX(pm2),
+  AVG(pm10), MIN(pm10), MAX(pm10)
+FROM sensor_readings
+WHERE timestamp >= $1 AND timestamp < $2
+`
+
+	var sampleCount int
+	var temperatureAvg, temperatureMin, temperatureMax *float64
+	var pressureAvg, pressureMin, pressureMax *float64
+	var humidityAvg, humidityMin, humidityMax *float64
+	var oxidisedAvg, oxidisedMin, oxidisedMax *float64
+	var reducedAvg, reducedMin, reducedMax *float64
+	var nh3Avg, nh3Min, nh3Max *float64
+	var pm1Avg, pm1Min, pm1Max *float64
+	var pm2Avg, pm2Min, pm2Max *float64
+	var pm10Avg, pm10Min, pm10Max *float64
+
+	err := store.pool.QueryRow(ctx, aggregateQuery, bucketStart, bucketEnd).Scan(
+		&sampleCount,
+		&temperatureAvg, &temperatureMin, &temperatureMax,
+		&pressureAvg, &pressureMin, &pressureMax,
+		&humidityAvg, &humidityMin, &humidityMax,
+		&oxidisedAvg, &oxidisedMin, &oxidisedMax,
+		&reducedAvg, &reducedMin, &reducedMax,
+		&nh3Avg, &nh3Min, &nh3Max,
+		&pm1Avg, &pm1Min, &pm1Max,
+		&pm2Avg, &pm2Min, &pm2Max,
+		&pm10Avg, &pm10Min, &pm10Max,
+	)
+	if err != nil {
+		return err
+	}
+	if sampleCount == 0 {
+		return nil
+	}
+
+	const upsertQuery = `
+INSERT INTO sensor_readings_rollup (
+  policy, bucket_start, sample_count,
+  temperature_avg, temperature_min, temperature_max,
+  pressure_avg, pressure_min, pressure_max,
+  humidity_avg, humidity_min, humidity_max,
+  oxidised_avg, oxidised_min, oxidised_max,
+  reduced_avg, reduced_min, reduced_max,
+  nh3_avg, nh3_min, nh3_max,
+  pm1_avg, pm1_min, pm1_max,
+  pm2_avg, pm2_min, pm2_max,
+  pm10_avg, pm10_min, pm10_max
+) VALUES (
+  $1, $2, $3,
+  $4, $5, $6,
+  $7, $8, $9,
+  $10, $11, $12,
+  $13, $14, $15,
+  $16, $17, $18,
+  $19, $20, $21,
+  $22, $23, $24,
+  $25, $26, $27,
+  $28, $29, $30
+)
+ON CONFLICT (policy, bucket_start) DO UPDATE SET
+  sample_count = EXCLUDED.sample_count,
+  temperature_avg = EXCLUDED.temperature_avg, temperature_min = EXCLUDED.temperature_min, temperature_max = EXCLUDED.temperature_max,
+  pressure_avg = EXCLUDED.pressure_avg, pressure_min = EXCLUDED.pressure_min, pressure_max = EXCLUDED.pressure_max,
+  humidity_avg = EXCLUDED.humidity_avg, humidity_min = EXCLUDED.humidity_min, humidity_max = EXCLUDED.humidity_max,
+  oxidised_avg = EXCLUDED.oxidised_avg, oxidised_min = EXCLUDED.oxidised_min, oxidised_max = EXCLUDED.oxidised_max,
+  reduced_avg = EXCLUDED.reduced_avg, reduced_min = EXCLUDED.reduced_min, reduced_max = EXCLUDED.reduced_max,
+  nh3_avg = EXCLUDED.nh3_avg, nh3_min = EXCLUDED.nh3_min, nh3_max = EXCLUDED.nh3_max,
+  pm1_avg = EXCLUDED.pm1_avg, pm1_min = EXCLUDED.pm1_min, pm1_max = EXCLUDED.pm1_max,
+  pm2_avg = EXCLUDED.pm2_avg, pm2_min = EXCLUDED.pm2_min, pm2_max = EXCLUDED.pm2_max,
+  pm10_avg = EXCLUDED.pm10_avg, pm10_min = EXCLUDED.pm10_min, pm10_max = EXCLUDED.pm10_max
+`
+
+	_, err = store.pool.Exec(
+		ctx,
+		upsertQuery,
+		policyName, bucketStart, sampleCount,
+		temperatureAvg, temperatureMin, temperatureMax,
+		pressureAvg, pressureMin, pressureMax,
+		humidityAvg, humidityMin, humidityMax,
+		oxidisedAvg, oxidisedMin, oxidisedMax,
+		reducedAvg, reducedMin, reducedMax,
+		nh3Avg, nh3Min, nh3Max,
+		pm1Avg, pm1Min, pm1Max,
+		pm2Avg, pm2Min, pm2Max,
+		pm10Avg, pm10Min, pm10Max,
+	)
+	return err
+}
+
+// LatestAtResolution serves Latest from whichever table is both coarse
+// enough for resolution and still accumulating data under a configured
+// policy: the raw sensor_readings table when resolution is 0 (or no
+// rollup policy's Resolution evenly divides it), or else the coarsest
+// sensor_readings_rollup policy that does (see finestRollupPolicyFor),
+// i.e. the one with the largest such Resolution. It satisfies the optional
+// ResolutionAwareStore capability (see store.go).
+func (store *PostgresStore) LatestAtResolution(ctx context.Context, limit int, resolution time.Duration) ([]SensorReading, error) {
+	if resolution <= 0 {
+		return store.Latest(ctx, limit)
+	}
+
+	policy, ok, err := store.finestRollupPolicyFor(ctx, resolution)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return store.Latest(ctx, limit)
+	}
+
+	return store.latestFromRollup(ctx, policy.Name, limit)
+}
+
+// finestRollupPolicyFor returns the rollup policy with the largest
+// Resolution that still evenly divides resolution, so a caller asking for
+// hourly data can be served by a 15-minute rollup but not a daily one.
+func (store *PostgresStore) finestRollupPolicyFor(ctx context.Context, resolution time.Duration) (RetentionPolicy, bool, error) {
+	policies, err := store.ListRetentionPolicies(ctx)
+	if err != nil {
+		return RetentionPolicy{}, false, err
+	}
+
+	var best RetentionPolicy
+	var found bool
+	for _, policy := range policies {
+		if policy.Resolution <= 0 || resolution%policy.Resolution != 0 {
+			continue
+		}
+		if !found || policy.Resolution > best.Resolution {
+			best = policy
+			found = true
+		}
+	}
+
+	return best, found, nil
+}
+
+func (store *PostgresStore) latestFromRollup(ctx context.Context, policyName string, limit int) ([]SensorReading, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	const query = `
+SELECT bucket_start,
+  temperature_avg, pressure_avg, humidity_avg,
+  oxidised_avg, reduced_avg, nh3_avg,
+  pm1_avg, pm2_avg, pm10_avg
+FROM sensor_readings_rollup
+WHERE policy = $1
+ORDER BY bucket_start DESC
+LIMIT $2
+`
+
+	rows, err := store.pool.Query(ctx, query, policyName, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	readings := make([]SensorReading, 0, limit)
+	for rows.Next() {
+		var reading SensorReading
+		if err := rows.Scan(
+			&reading.Timestamp,
+			&reading.Temperature,
+			&reading.Pressure,
+			&reading.Humidity,
+			&reading.Oxidised,
+			&reading.Reduced,
+			&reading.Nh3,
+			&reading.PM1,
+			&reading.PM2,
+			&reading.PM10,
+		); err != nil {
+			return nil, err
+		}
+		readings = append(readings, reading)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for left, right := 0, len(readings)-1; left < right; left, right = left+1, right-1 {
+		readings[left], readings[right] = readings[right], readings[left]
+	}
+
+	return readings, nil
+}
@@ -0,0 +1,486 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxStoredFiredAlerts bounds ruleEvaluator's in-memory fired-alert
+// history, the same way alertStreamHub and the stream hub cap their own
+// buffers rather than growing unboundedly across a long-lived process.
+const maxStoredFiredAlerts = 500
+
+// AlertRule is one user-defined, deterministic threshold rule evaluated
+// against every ingested reading, modeled after Prometheus alerting rules
+// scaled down to this service's fixed sensor fields: Expr is a boolean
+// condition (e.g. "pm2 > 25 and humidity < 40"), and For is how long that
+// condition must hold continuously, reading over reading, before the rule
+// fires. Unlike Prometheus, For is carried as its own record field rather
+// than embedded in Expr.
+type AlertRule struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Expr        string            `json:"expr"`
+	For         time.Duration     `json:"-"`
+	Severity    string            `json:"severity"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// alertRuleWire is AlertRule's JSON wire form: For travels as a duration
+// string ("10m") rather than a raw nanosecond count, matching how an
+// operator would write it in a rules file.
+type alertRuleWire struct {
+	ID          string            `json:"id,omitempty"`
+	Name        string            `json:"name"`
+	Expr        string            `json:"expr"`
+	For         string            `json:"for"`
+	Severity    string            `json:"severity,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+func (rule AlertRule) MarshalJSON() ([]byte, error) {
+	return json.Marshal(alertRuleWire{
+		ID:          rule.ID,
+		Name:        rule.Name,
+		Expr:        rule.Expr,
+		For:         rule.For.String(),
+		Severity:    rule.Severity,
+		Labels:      rule.Labels,
+		Annotations: rule.Annotations,
+	})
+}
+
+func (rule *AlertRule) UnmarshalJSON(data []byte) error {
+	var wire alertRuleWire
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+
+	var forDuration time.Duration
+	if strings.TrimSpace(wire.For) != "" {
+		parsed, err := time.ParseDuration(wire.For)
+		if err != nil {
+			return fmt.Errorf("for must be a valid duration: %w", err)
+		}
+		forDuration = parsed
+	}
+
+	*rule = AlertRule{
+		ID:          wire.ID,
+		Name:        wire.Name,
+		Expr:        wire.Expr,
+		For:         forDuration,
+		Severity:    wire.Severity,
+		Labels:      wire.Labels,
+		Annotations: wire.Annotations,
+	}
+	return nil
+}
+
+// FiredAlert is one instant a rule transitioned from pending/inactive to
+// firing. It's returned from GET /api/rules/alerts and mirrored into the
+// ops-events log (kind "rule_alert") so it shows up alongside device
+// connectivity history without a separate persisted schema.
+type FiredAlert struct {
+	RuleID      string            `json:"rule_id"`
+	Name        string            `json:"name"`
+	Severity    string            `json:"severity"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Value       float64           `json:"value"`
+	FiredAt     int64             `json:"fired_at"`
+}
+
+// RulesEngine evaluates AlertRules against every ingested reading and
+// keeps the fired-alert history queryable. WithRulesEngine lets tests
+// substitute a fake; NewAPI otherwise wires the default ruleEvaluator
+// automatically, so the /api/rules handlers always have somewhere to read
+// from and write to even before any rule has been added.
+type RulesEngine interface {
+	Rules() []AlertRule
+	AddRule(rule AlertRule) (AlertRule, error)
+	RemoveRule(id string) error
+	Alerts(limit int) []FiredAlert
+	OnReading(reading SensorReading)
+	OnBatch(readings []SensorReading)
+}
+
+type storedRule struct {
+	rule     AlertRule
+	compiled *compiledRuleExpr
+}
+
+// ruleEvaluator is the default RulesEngine: an in-memory rule set plus a
+// pending/firing state machine per rule, keyed by rule ID. It holds no
+// reference to the Store — every rule is evaluated directly against the
+// readings it's handed via OnReading/OnBatch, so there's no extra query
+// per ingest.
+type ruleEvaluator struct {
+	mu      sync.Mutex
+	rules   map[string]*storedRule
+	order   []string
+	pending map[string]int64
+	firing  map[string]bool
+	alerts  []FiredAlert
+	nextID  int64
+
+	// onFired is set by NewAPI to persist a firing transition through
+	// the ops-events store. Left nil in standalone tests of the engine
+	// itself.
+	onFired func(FiredAlert)
+}
+
+func newRuleEvaluator() *ruleEvaluator {
+	return &ruleEvaluator{
+		rules:   make(map[string]*storedRule),
+		pending: make(map[string]int64),
+		firing:  make(map[string]bool),
+	}
+}
+
+func (engine *ruleEvaluator) Rules() []AlertRule {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+
+	rules := make([]AlertRule, 0, len(engine.order))
+	for _, id := range engine.order {
+		rules = append(rules, engine.rules[id].rule)
+	}
+	return rules
+}
+
+func (engine *ruleEvaluator) AddRule(rule AlertRule) (AlertRule, error) {
+	if strings.TrimSpace(rule.Name) == "" {
+		return AlertRule{}, fmt.Errorf("rule name is required")
+	}
+	if rule.For < 0 {
+		return AlertRule{}, fmt.Errorf("rule for duration must not be negative")
+	}
+
+	compiled, err := compileRuleExpr(rule.Expr)
+	if err != nil {
+		return AlertRule{}, err
+	}
+
+	if strings.TrimSpace(rule.Severity) == "" {
+		rule.Severity = "warn"
+	}
+
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+
+	if strings.TrimSpace(rule.ID) == "" {
+		rule.ID = fmt.Sprintf("rule-%d", atomic.AddInt64(&engine.nextID, 1))
+	} else if _, exists := engine.rules[rule.ID]; exists {
+		return AlertRule{}, fmt.Errorf("rule %q already exists", rule.ID)
+	}
+
+	engine.rules[rule.ID] = &storedRule{rule: rule, compiled: compiled}
+	engine.order = append(engine.order, rule.ID)
+	return rule, nil
+}
+
+func (engine *ruleEvaluator) RemoveRule(id string) error {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+
+	if _, ok := engine.rules[id]; !ok {
+		return fmt.Errorf("rule %q not found", id)
+	}
+
+	delete(engine.rules, id)
+	delete(engine.pending, id)
+	delete(engine.firing, id)
+	for index, existingID := range engine.order {
+		if existingID == id {
+			engine.order = append(engine.order[:index], engine.order[index+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (engine *ruleEvaluator) Alerts(limit int) []FiredAlert {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+
+	if limit <= 0 || limit > len(engine.alerts) {
+		limit = len(engine.alerts)
+	}
+
+	alerts := make([]FiredAlert, 0, limit)
+	for index := len(engine.alerts) - 1; index >= 0 && len(alerts) < limit; index-- {
+		alerts = append(alerts, engine.alerts[index])
+	}
+	return alerts
+}
+
+func (engine *ruleEvaluator) OnReading(reading SensorReading) {
+	fired := engine.evaluate(reading)
+	for _, alert := range fired {
+		if engine.onFired != nil {
+			engine.onFired(alert)
+		}
+	}
+}
+
+func (engine *ruleEvaluator) OnBatch(readings []SensorReading) {
+	for _, reading := range readings {
+		engine.OnReading(reading)
+	}
+}
+
+// evaluate runs every rule against reading, advancing each rule's
+// pending/firing state, and returns any alerts that just transitioned
+// into firing on this reading.
+func (engine *ruleEvaluator) evaluate(reading SensorReading) []FiredAlert {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+
+	var fired []FiredAlert
+	for _, id := range engine.order {
+		stored := engine.rules[id]
+		conditionTrue, value := stored.compiled.evaluate(reading)
+
+		if !conditionTrue {
+			delete(engine.pending, id)
+			engine.firing[id] = false
+			continue
+		}
+
+		since, alreadyPending := engine.pending[id]
+		if !alreadyPending {
+			since = reading.Timestamp
+			engine.pending[id] = since
+		}
+
+		elapsed := time.Duration(reading.Timestamp-since) * time.Second
+		if elapsed < stored.rule.For || engine.firing[id] {
+			continue
+		}
+
+		engine.firing[id] = true
+		alert := FiredAlert{
+			RuleID:      stored.rule.ID,
+			Name:        stored.rule.Name,
+			Severity:    stored.rule.Severity,
+			Labels:      stored.rule.Labels,
+			Annotations: stored.rule.Annotations,
+			Value:       value,
+			FiredAt:     reading.Timestamp,
+		}
+		engine.alerts = append(engine.alerts, alert)
+		if len(engine.alerts) > maxStoredFiredAlerts {
+			engine.alerts = engine.alerts[len(engine.alerts)-maxStoredFiredAlerts:]
+		}
+		fired = append(fired, alert)
+	}
+	return fired
+}
+
+// --- threshold DSL ---
+
+type ruleComparator string
+
+const (
+	ruleOpGT  ruleComparator = ">"
+	ruleOpLT  ruleComparator = "<"
+	ruleOpGTE ruleComparator = ">="
+	ruleOpLTE ruleComparator = "<="
+	ruleOpEQ  ruleComparator = "=="
+	ruleOpNEQ ruleComparator = "!="
+)
+
+type ruleCondition struct {
+	metric     string
+	comparator ruleComparator
+	threshold  float64
+}
+
+// compiledRuleExpr is a parsed AlertRule.Expr: a left-to-right chain of
+// comparisons joined by "and"/"or", with no operator precedence beyond
+// evaluation order. That's deliberately all this DSL supports — it covers
+// every example in the feature request without a real expression parser.
+type compiledRuleExpr struct {
+	conditions   []ruleCondition
+	conjunctions []string
+}
+
+// compileRuleExpr parses expr into a compiledRuleExpr. Grammar:
+//
+//	expr := condition ((" and " | " or ") condition)*
+//	condition := METRIC OPERATOR NUMBER
+//
+// e.g. "pm2 > 25 and humidity < 40".
+func compileRuleExpr(expr string) (*compiledRuleExpr, error) {
+	tokens := strings.Fields(expr)
+	if len(tokens) == 0 || len(tokens)%4 != 3 {
+		return nil, fmt.Errorf("rule expr %q: expected METRIC OP VALUE [and|or METRIC OP VALUE ...]", expr)
+	}
+
+	compiled := &compiledRuleExpr{}
+	for index := 0; index < len(tokens); index += 4 {
+		metric := tokens[index]
+		if _, ok := queryMetricAccessors[metric]; !ok {
+			return nil, fmt.Errorf("rule expr %q: unknown metric %q", expr, metric)
+		}
+
+		comparator := ruleComparator(tokens[index+1])
+		switch comparator {
+		case ruleOpGT, ruleOpLT, ruleOpGTE, ruleOpLTE, ruleOpEQ, ruleOpNEQ:
+		default:
+			return nil, fmt.Errorf("rule expr %q: unsupported operator %q", expr, tokens[index+1])
+		}
+
+		threshold, err := strconv.ParseFloat(tokens[index+2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("rule expr %q: threshold %q is not a number", expr, tokens[index+2])
+		}
+		compiled.conditions = append(compiled.conditions, ruleCondition{
+			metric:     metric,
+			comparator: comparator,
+			threshold:  threshold,
+		})
+
+		if index+3 < len(tokens) {
+			conjunction := strings.ToLower(tokens[index+3])
+			if conjunction != "and" && conjunction != "or" {
+				return nil, fmt.Errorf("rule expr %q: expected \"and\" or \"or\", got %q", expr, tokens[index+3])
+			}
+			compiled.conjunctions = append(compiled.conjunctions, conjunction)
+		}
+	}
+
+	return compiled, nil
+}
+
+// evaluate reports whether the overall expression is true for reading,
+// plus the metric value of the last condition evaluated (the value
+// surfaced on the resulting FiredAlert).
+func (compiled *compiledRuleExpr) evaluate(reading SensorReading) (bool, float64) {
+	result := evaluateRuleCondition(compiled.conditions[0], reading)
+	value := queryMetricAccessors[compiled.conditions[0].metric](reading)
+
+	for index, conjunction := range compiled.conjunctions {
+		condition := compiled.conditions[index+1]
+		conditionTrue := evaluateRuleCondition(condition, reading)
+		if conjunction == "and" {
+			result = result && conditionTrue
+		} else {
+			result = result || conditionTrue
+		}
+		value = queryMetricAccessors[condition.metric](reading)
+	}
+
+	return result, value
+}
+
+func evaluateRuleCondition(condition ruleCondition, reading SensorReading) bool {
+	value := queryMetricAccessors[condition.metric](reading)
+	switch condition.comparator {
+	case ruleOpGT:
+		return value > condition.threshold
+	case ruleOpLT:
+		return value < condition.threshold
+	case ruleOpGTE:
+		return value >= condition.threshold
+	case ruleOpLTE:
+		return value <= condition.threshold
+	case ruleOpEQ:
+		return value == condition.threshold
+	case ruleOpNEQ:
+		return value != condition.threshold
+	default:
+		return false
+	}
+}
+
+// --- handlers ---
+
+func (api *API) handleRules(response http.ResponseWriter, request *http.Request) {
+	if !api.authorizeIngestRequest(response, request) {
+		return
+	}
+
+	switch request.Method {
+	case http.MethodGet:
+		writeJSON(response, http.StatusOK, map[string]any{"rules": api.rulesEngine.Rules()})
+	case http.MethodPost:
+		request.Body = http.MaxBytesReader(response, request.Body, maxIngestBodyBytes)
+		payload, err := io.ReadAll(request.Body)
+		if err != nil {
+			writeError(response, http.StatusBadRequest, "invalid request body")
+			return
+		}
+
+		var rule AlertRule
+		if err := json.Unmarshal(payload, &rule); err != nil {
+			writeError(response, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		stored, err := api.rulesEngine.AddRule(rule)
+		if err != nil {
+			writeError(response, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		writeJSON(response, http.StatusCreated, stored)
+	default:
+		writeError(response, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (api *API) handleRuleByID(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodDelete {
+		writeError(response, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !api.authorizeIngestRequest(response, request) {
+		return
+	}
+
+	id := strings.TrimPrefix(request.URL.Path, "/api/rules/")
+	if id == "" || id == "alerts" {
+		writeError(response, http.StatusBadRequest, "rule id is required")
+		return
+	}
+
+	if err := api.rulesEngine.RemoveRule(id); err != nil {
+		writeError(response, http.StatusNotFound, err.Error())
+		return
+	}
+
+	writeJSON(response, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+func (api *API) handleRuleAlerts(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		writeError(response, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !api.authorizeIngestRequest(response, request) {
+		return
+	}
+
+	limit := 50
+	if rawLimit := request.URL.Query().Get("limit"); rawLimit != "" {
+		parsedLimit, err := strconv.Atoi(rawLimit)
+		if err != nil || parsedLimit < 1 {
+			writeError(response, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = parsedLimit
+	}
+
+	writeJSON(response, http.StatusOK, map[string]any{"alerts": api.rulesEngine.Alerts(limit)})
+}
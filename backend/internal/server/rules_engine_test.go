@@ -0,0 +1,192 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRuleEvaluatorFiresAfterForDuration(t *testing.T) {
+	engine := newRuleEvaluator()
+	rule, err := engine.AddRule(AlertRule{
+		Name: "high pm2",
+		Expr: "pm2 > 25",
+		For:  60 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("AddRule failed: %v", err)
+	}
+
+	engine.OnReading(SensorReading{Timestamp: 1000, PM2: 30})
+	if alerts := engine.Alerts(0); len(alerts) != 0 {
+		t.Fatalf("expected no alert before the for duration elapses, got %d", len(alerts))
+	}
+
+	engine.OnReading(SensorReading{Timestamp: 1061, PM2: 32})
+	alerts := engine.Alerts(0)
+	if len(alerts) != 1 {
+		t.Fatalf("expected exactly one fired alert, got %d", len(alerts))
+	}
+	if alerts[0].RuleID != rule.ID || alerts[0].Value != 32 {
+		t.Fatalf("unexpected fired alert: %+v", alerts[0])
+	}
+
+	engine.OnReading(SensorReading{Timestamp: 1062, PM2: 10})
+	if engine.firing[rule.ID] {
+		t.Fatalf("expected rule to stop firing once the condition clears")
+	}
+}
+
+func TestRuleEvaluatorRejectsUnknownMetric(t *testing.T) {
+	engine := newRuleEvaluator()
+	if _, err := engine.AddRule(AlertRule{Name: "bad", Expr: "co2 > 400"}); err == nil {
+		t.Fatalf("expected an error for an unknown metric")
+	}
+}
+
+func TestAlertRuleJSONRoundTripsForDuration(t *testing.T) {
+	original := AlertRule{Name: "humid", Expr: "humidity > 80", For: 10 * time.Minute, Severity: "warn"}
+
+	payload, err := json.Marshal(original)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(string(payload), `"for":"10m0s"`) {
+		t.Fatalf("expected for to marshal as a duration string, got %s", payload)
+	}
+
+	var decoded AlertRule
+	if err := json.Unmarshal(payload, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if decoded.For != original.For {
+		t.Fatalf("expected for to round-trip, got %s", decoded.For)
+	}
+}
+
+func TestHandleRulesCreatesAndListsRules(t *testing.T) {
+	store := &fakeStore{}
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	createRequest := httptest.NewRequest(http.MethodPost, "/api/rules", bytes.NewBufferString(`{
+		"name": "high pm2",
+		"expr": "pm2 > 25",
+		"for": "1m"
+	}`))
+	createRequest.Header.Set("X-API-Key", "secret")
+	createResponse := httptest.NewRecorder()
+	handler.ServeHTTP(createResponse, createRequest)
+
+	if createResponse.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, createResponse.Code, createResponse.Body.String())
+	}
+
+	listRequest := httptest.NewRequest(http.MethodGet, "/api/rules", nil)
+	listRequest.Header.Set("X-API-Key", "secret")
+	listResponse := httptest.NewRecorder()
+	handler.ServeHTTP(listResponse, listRequest)
+
+	if listResponse.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, listResponse.Code)
+	}
+	if !strings.Contains(listResponse.Body.String(), `"high pm2"`) {
+		t.Fatalf("expected the created rule in the list, got %s", listResponse.Body.String())
+	}
+}
+
+func TestHandleRulesRequiresAPIKey(t *testing.T) {
+	store := &fakeStore{}
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	request := httptest.NewRequest(http.MethodGet, "/api/rules", nil)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, response.Code)
+	}
+}
+
+func TestHandleRuleByIDDeletesRule(t *testing.T) {
+	store := &fakeStore{}
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	stored, err := api.rulesEngine.AddRule(AlertRule{Name: "temp", Expr: "temperature > 30"})
+	if err != nil {
+		t.Fatalf("AddRule failed: %v", err)
+	}
+
+	deleteRequest := httptest.NewRequest(http.MethodDelete, "/api/rules/"+stored.ID, nil)
+	deleteRequest.Header.Set("X-API-Key", "secret")
+	deleteResponse := httptest.NewRecorder()
+	handler.ServeHTTP(deleteResponse, deleteRequest)
+
+	if deleteResponse.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, deleteResponse.Code, deleteResponse.Body.String())
+	}
+	if len(api.rulesEngine.Rules()) != 0 {
+		t.Fatalf("expected the rule to be removed")
+	}
+}
+
+func TestFiredAlertsArePersistedAsOpsEventsAndListedViaAPI(t *testing.T) {
+	store := &fakeOpsStore{fakeStore: &fakeStore{}}
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	if _, err := api.rulesEngine.AddRule(AlertRule{
+		Name:     "high pm2",
+		Expr:     "pm2 > 25",
+		Severity: "critical",
+	}); err != nil {
+		t.Fatalf("AddRule failed: %v", err)
+	}
+
+	ingestRequest := httptest.NewRequest(http.MethodPost, "/api/ingest", bytes.NewBufferString(`{
+		"timestamp":"1738886400",
+		"temperature":"22.4",
+		"pressure":"101305",
+		"humidity":"40.1",
+		"oxidised":"1.2",
+		"reduced":"1.1",
+		"nh3":"0.7",
+		"pm1":"2",
+		"pm2":"30",
+		"pm10":"4"
+	}`))
+	ingestRequest.Header.Set("Content-Type", "application/json")
+	ingestRequest.Header.Set("X-API-Key", "secret")
+	ingestResponse := httptest.NewRecorder()
+	handler.ServeHTTP(ingestResponse, ingestRequest)
+
+	if ingestResponse.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d", http.StatusAccepted, ingestResponse.Code)
+	}
+
+	deadline := time.Now().Add(400 * time.Millisecond)
+	for time.Now().Before(deadline) && !store.hasEventKind("rule_alert") {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !store.hasEventKind("rule_alert") {
+		t.Fatalf("expected a rule_alert ops event to be persisted")
+	}
+
+	alertsRequest := httptest.NewRequest(http.MethodGet, "/api/rules/alerts", nil)
+	alertsRequest.Header.Set("X-API-Key", "secret")
+	alertsResponse := httptest.NewRecorder()
+	handler.ServeHTTP(alertsResponse, alertsRequest)
+
+	if alertsResponse.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, alertsResponse.Code)
+	}
+	if !strings.Contains(alertsResponse.Body.String(), `"high pm2"`) {
+		t.Fatalf("expected the fired alert in the alerts list, got %s", alertsResponse.Body.String())
+	}
+}
@@ -1,26 +1,31 @@
 package server
 
 import (
+	"compress/gzip"
 	"context"
-	"crypto/subtle"
+	"crypto/ed25519"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 const (
-	maxIngestBodyBytes = 1 << 20
-	maxBatchBodyBytes  = 4 << 20
-	maxBatchSize       = 1000
-	maxReadingsLimit   = 100000
-	maxOpsEventsLimit  = 200
-	maxInsightsLimit   = 3
+	maxIngestBodyBytes       = 1 << 20
+	maxBatchBodyBytes        = 4 << 20
+	maxBatchSize             = 1000
+	maxReadingsLimit         = 100000
+	maxOpsEventsLimit        = 200
+	maxInsightsLimit         = 3
+	streamWriteDeadline      = 10 * time.Second
+	streamHeartbeatInterval  = 15 * time.Second
+	defaultReadingsChunkSize = 10000
 )
 
 type readingsRangeStore interface {
@@ -30,18 +35,38 @@ type readingsRangeStore interface {
 type API struct {
 	store                   Store
 	ingestAPIKey            string
+	databaseURL             string
 	trustProxyIP            bool
 	stream                  *streamHub
+	alertStream             *alertStreamHub
+	opsEventStream          *opsEventHub
+	subscriptions           *subscriptionHub
+	pendingSubscribers      []Subscriber
 	alertAnalyzer           AlertAnalyzer
 	insightsEngine          InsightsEngine
 	insightsSchedulerConfig InsightsSchedulerConfig
 	opsEventStore           OpsEventStore
+	subscriptionStore       SubscriptionStore
 	opsConfig               OpsConfig
-
-	opsMu            sync.Mutex
-	deviceStateKnown bool
-	deviceConnected  bool
-	lastDeviceSeenAt time.Time
+	jwtPublicKey            ed25519.PublicKey
+	readAuthEnabled         bool
+	readScopes              []string
+	metricsRegistry         *prometheus.Registry
+	metricsBearerToken      string
+	promQLEngine            PromQLEngine
+	rulesEngine             RulesEngine
+	ingestSessionStore      IngestSessionStore
+	deviceRegistry          DeviceRegistry
+	deviceBootstrapToken    string
+	adminAPIKey             string
+	tlsConfig               *TLSConfig
+	listenAddr              string
+
+	opsMu        sync.Mutex
+	deviceStates map[string]*deviceConnectivityState
+
+	lifecycleCtx    context.Context
+	lifecycleCancel context.CancelFunc
 }
 
 type APIOption func(*API)
@@ -70,36 +95,182 @@ func WithTrustProxyIP(enabled bool) APIOption {
 	}
 }
 
+// WithDatabaseURL records the DATABASE_URL the Store was built from, so a
+// later Reload can reject a reload that tries to change it (swapping
+// stores at runtime isn't supported).
+func WithDatabaseURL(databaseURL string) APIOption {
+	return func(api *API) {
+		api.databaseURL = databaseURL
+	}
+}
+
 func WithOpsConfig(config OpsConfig) APIOption {
 	return func(api *API) {
 		api.opsConfig = config
 	}
 }
 
+// WithOpsEventStore explicitly sets the OpsEventStore used for device
+// connectivity history, independent of the main readings Store (e.g.
+// SQLiteOpsEventStore alongside a Redis or in-memory readings store). If
+// unset, NewAPI falls back to using store itself when it also implements
+// OpsEventStore (as PostgresStore does).
+func WithOpsEventStore(opsEventStore OpsEventStore) APIOption {
+	return func(api *API) {
+		api.opsEventStore = opsEventStore
+	}
+}
+
+// WithSubscribers forks every ingested reading and insights snapshot to the
+// given external sinks (webhooks, MQTT, InfluxDB), each retried
+// independently so a slow or unreachable sink can't block ingest.
+func WithSubscribers(subscribers ...Subscriber) APIOption {
+	return func(api *API) {
+		api.pendingSubscribers = append(api.pendingSubscribers, subscribers...)
+	}
+}
+
+// WithJWTPublicKey enables bearer-token authentication: requests carrying
+// an `Authorization: Bearer <jwt>` header are verified against this
+// ed25519 public key in addition to (or instead of) the legacy
+// X-API-Key model.
+func WithJWTPublicKey(publicKey ed25519.PublicKey) APIOption {
+	return func(api *API) {
+		api.jwtPublicKey = publicKey
+	}
+}
+
+// WithAuthRequired gates the read-only endpoints (/api/readings,
+// /api/stream, /api/insights, /api/ops/events) behind a bearer JWT
+// carrying at least one of scopes. With no scopes, any token that
+// verifies against WithJWTPublicKey is accepted.
+func WithAuthRequired(scopes ...string) APIOption {
+	return func(api *API) {
+		api.readAuthEnabled = true
+		api.readScopes = scopes
+	}
+}
+
+// WithPromQL overrides the PromQLEngine backing /api/v1/query and
+// /api/v1/query_range, mainly so tests can inject a fake. Without this
+// option, NewAPI wires up the default promQLEvaluator against store
+// itself whenever store implements readingsRangeStore.
+func WithPromQL(engine PromQLEngine) APIOption {
+	return func(api *API) {
+		api.promQLEngine = engine
+	}
+}
+
+// WithRulesEngine overrides the RulesEngine evaluated against every
+// ingested reading, mainly so tests can inject a fake. Without this
+// option, NewAPI wires up the default ruleEvaluator with no rules
+// configured yet.
+func WithRulesEngine(engine RulesEngine) APIOption {
+	return func(api *API) {
+		api.rulesEngine = engine
+	}
+}
+
 func NewAPI(store Store, ingestAPIKey string, options ...APIOption) *API {
 	normalizedIngestAPIKey := strings.TrimSpace(ingestAPIKey)
+	lifecycleCtx, lifecycleCancel := context.WithCancel(context.Background())
 	api := &API{
 		store:                   store,
 		ingestAPIKey:            normalizedIngestAPIKey,
 		stream:                  newStreamHub(),
+		alertStream:             newAlertStreamHub(),
+		opsEventStream:          newOpsEventHub(),
 		insightsSchedulerConfig: DefaultInsightsSchedulerConfig(),
 		opsConfig:               DefaultOpsConfig(),
+		metricsRegistry:         promRegistry,
+		lifecycleCtx:            lifecycleCtx,
+		lifecycleCancel:         lifecycleCancel,
+		deviceStates:            make(map[string]*deviceConnectivityState),
 	}
 	for _, option := range options {
 		option(api)
 	}
 
+	api.stream.onSlowConsumerDropped = func() {
+		api.persistOpsEvent(
+			"",
+			"stream_client_dropped",
+			"Stream client dropped",
+			"A stream subscriber was evicted for overflowing its buffer or missing a write deadline.",
+			time.Now().UnixMilli(),
+		)
+	}
+
+	api.subscriptions = newSubscriptionHub(api.lifecycleCtx, api.pendingSubscribers)
+
 	if api.insightsEngine == nil && api.alertAnalyzer != nil {
 		scheduler := NewInsightsScheduler(store, api.alertAnalyzer, api.insightsSchedulerConfig)
-		scheduler.Start(context.Background())
+		scheduler.onSnapshot = func(snapshot InsightsSnapshot) {
+			api.subscriptions.publishSnapshot(snapshot)
+			api.alertStream.publish(snapshot.Insights, snapshot.Source)
+		}
+		scheduler.Start(api.lifecycleCtx)
 		api.insightsEngine = scheduler
 	}
 
-	if opsStore, ok := store.(OpsEventStore); ok {
-		api.opsEventStore = opsStore
+	if stater, ok := store.(poolStater); ok {
+		api.startPoolStatsReporter(api.lifecycleCtx, stater)
+	}
+
+	if api.promQLEngine == nil {
+		if rangeStore, ok := store.(readingsRangeStore); ok {
+			api.promQLEngine = newPromQLEvaluator(rangeStore)
+		}
+	}
+
+	if api.rulesEngine == nil {
+		api.rulesEngine = newRuleEvaluator()
+	}
+	if evaluator, ok := api.rulesEngine.(*ruleEvaluator); ok {
+		evaluator.onFired = func(alert FiredAlert) {
+			api.persistOpsEvent(
+				"",
+				"rule_alert",
+				alert.Name,
+				fmt.Sprintf("Rule %q fired with value %.2f.", alert.Name, alert.Value),
+				alert.FiredAt*1000,
+			)
+		}
+	}
+
+	if api.ingestSessionStore == nil {
+		if sessionStore, ok := store.(IngestSessionStore); ok {
+			api.ingestSessionStore = sessionStore
+		} else {
+			api.ingestSessionStore = newMemoryIngestSessionStore()
+		}
+	}
+	api.startIngestSessionMonitor(api.lifecycleCtx)
+
+	if api.deviceRegistry == nil {
+		if registry, ok := store.(DeviceRegistry); ok {
+			api.deviceRegistry = registry
+		} else {
+			api.deviceRegistry = newMemoryDeviceRegistry()
+		}
+	}
+
+	if api.opsEventStore == nil {
+		if opsStore, ok := store.(OpsEventStore); ok {
+			api.opsEventStore = opsStore
+		}
+	}
+
+	if api.subscriptionStore == nil {
+		if subStore, ok := store.(SubscriptionStore); ok {
+			api.subscriptionStore = subStore
+		}
+	}
+	if api.opsEventStore != nil {
 		api.initializeDeviceState()
-		api.startDeviceMonitor(context.Background())
+		api.startDeviceMonitor(api.lifecycleCtx)
 		api.persistOpsEvent(
+			"",
 			"backend_restarted",
 			"Backend restarted",
 			"Ops event monitoring is active.",
@@ -110,17 +281,107 @@ func NewAPI(store Store, ingestAPIKey string, options ...APIOption) *API {
 	return api
 }
 
+// Shutdown stops the background work tied to the API's lifetime (the
+// insights scheduler, device monitor, ops-event pruning, and subscription
+// fan-out) and signals active SSE subscribers so handleStream and
+// handleAlertsStream can flush a terminal "server_shutdown" event before
+// main closes their connections via httpServer.Shutdown. Safe to call at
+// most once.
+func (api *API) Shutdown() {
+	api.lifecycleCancel()
+}
+
+// WatchInsightsConfigFile hot-reloads the insights scheduler's thresholds
+// from the JSON file at path whenever it changes. It's a no-op unless an
+// AlertAnalyzer was configured via WithAlertAnalyzer, since that's the
+// only case where api.insightsEngine is a concrete *InsightsScheduler.
+func (api *API) WatchInsightsConfigFile(ctx context.Context, path string) error {
+	scheduler, ok := api.insightsEngine.(*InsightsScheduler)
+	if !ok {
+		return nil
+	}
+	return scheduler.WatchConfigFile(ctx, path)
+}
+
 func (api *API) Handler() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/health", api.handleHealth)
 	mux.HandleFunc("/ready", api.handleReady)
-	mux.HandleFunc("/api/ingest", api.handleIngest)
-	mux.HandleFunc("/api/ingest/batch", api.handleIngestBatch)
+	mux.HandleFunc("/api/ingest", withIngestMetrics(api.handleIngest))
+	mux.HandleFunc("/api/ingest/batch", withIngestMetrics(api.handleIngestBatch))
+	mux.HandleFunc("/api/ingest/line", withIngestMetrics(api.handleIngestLine))
+	mux.HandleFunc("/api/ingest/session", withIngestMetrics(api.handleIngestSessionOpen))
+	mux.HandleFunc("/api/ingest/session/", withIngestMetrics(api.handleIngestSessionByID))
 	mux.HandleFunc("/api/readings", api.handleReadings)
+	mux.HandleFunc("/api/query_range", api.handleQueryRange)
+	mux.HandleFunc("/api/query", api.handleQuery)
+	mux.HandleFunc("/api/v1/query_range", api.handlePromQLQueryRange)
+	mux.HandleFunc("/api/v1/query", api.handlePromQLQuery)
+	mux.HandleFunc("/api/v1/labels", api.handlePromQLLabels)
+	mux.HandleFunc("/api/v1/series", api.handlePromQLSeries)
+	mux.HandleFunc("/api/metrics", api.handleMetrics)
+	mux.HandleFunc("/metrics", api.handleMetricsExposition)
 	mux.HandleFunc("/api/stream", api.handleStream)
+	mux.HandleFunc("/api/alerts/stream", api.handleAlertsStream)
+	mux.HandleFunc("/api/readings/stream", api.handleReadingsStream)
 	mux.HandleFunc("/api/insights", api.handleInsights)
+	mux.HandleFunc("/api/insights/anomaly-stats", api.handleInsightsAnomalyStats)
 	mux.HandleFunc("/api/ops/events", api.handleOpsEvents)
-	return mux
+	mux.HandleFunc("/api/rules/alerts", api.handleRuleAlerts)
+	mux.HandleFunc("/api/rules", api.handleRules)
+	mux.HandleFunc("/api/rules/", api.handleRuleByID)
+	mux.HandleFunc("/api/subscriptions", api.handleSubscriptions)
+	mux.HandleFunc("/api/subscriptions/", api.handleSubscriptionByID)
+	mux.HandleFunc("/api/devices/register", api.handleDeviceRegister)
+	mux.HandleFunc("/api/devices", api.handleDevicesList)
+	mux.HandleFunc("/api/devices/", api.handleDeviceByID)
+	mux.HandleFunc("/debug/log-level", api.handleDebugLogLevel)
+	return withGzip(mux)
+}
+
+// withGzip transparently gzip-compresses responses when the client sends
+// Accept-Encoding: gzip. The SSE streams are excluded: gzip's internal
+// buffering would defeat the low-latency flushing handleStream and
+// handleAlertsStream rely on.
+func withGzip(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == "/api/stream" || request.URL.Path == "/api/alerts/stream" ||
+			request.URL.Path == "/api/readings/stream" ||
+			!strings.Contains(request.Header.Get("Accept-Encoding"), "gzip") {
+			next.ServeHTTP(response, request)
+			return
+		}
+
+		response.Header().Set("Content-Encoding", "gzip")
+		response.Header().Add("Vary", "Accept-Encoding")
+
+		gzipWriter := gzip.NewWriter(response)
+		defer gzipWriter.Close()
+
+		next.ServeHTTP(&gzipResponseWriter{ResponseWriter: response, gzipWriter: gzipWriter}, request)
+	})
+}
+
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gzipWriter *gzip.Writer
+}
+
+func (writer *gzipResponseWriter) Write(data []byte) (int, error) {
+	return writer.gzipWriter.Write(data)
+}
+
+func (writer *gzipResponseWriter) Flush() {
+	writer.gzipWriter.Flush()
+	if flusher, ok := writer.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying ResponseWriter
+// for optional capabilities (e.g. SetWriteDeadline) it doesn't implement.
+func (writer *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return writer.ResponseWriter
 }
 
 func (api *API) handleHealth(response http.ResponseWriter, request *http.Request) {
@@ -152,7 +413,8 @@ func (api *API) handleIngest(response http.ResponseWriter, request *http.Request
 		return
 	}
 
-	if !api.authorizeIngestRequest(response, request) {
+	deviceID, ok := api.authorizeIngestRequestForDevice(response, request)
+	if !ok {
 		return
 	}
 
@@ -168,17 +430,23 @@ func (api *API) handleIngest(response http.ResponseWriter, request *http.Request
 		writeError(response, http.StatusBadRequest, err.Error())
 		return
 	}
+	reading.DeviceID = deviceID
 
 	if err := api.store.Add(request.Context(), reading); err != nil {
+		logger.Error("ingest persist failed", "component", "ingest", "requestID", RequestIDFromContext(request.Context()), "error", err)
 		writeError(response, http.StatusInternalServerError, "failed to persist reading")
 		return
 	}
 
-	api.onTelemetryReceived(time.Now())
+	api.onTelemetryReceived(deviceID, time.Now())
 	api.stream.publish(reading)
+	api.subscriptions.publish(reading)
 	if api.insightsEngine != nil {
 		api.insightsEngine.OnReading(reading)
 	}
+	if api.rulesEngine != nil {
+		api.rulesEngine.OnReading(reading)
+	}
 	writeJSON(response, http.StatusAccepted, map[string]string{"status": "accepted"})
 }
 
@@ -188,7 +456,8 @@ func (api *API) handleIngestBatch(response http.ResponseWriter, request *http.Re
 		return
 	}
 
-	if !api.authorizeIngestRequest(response, request) {
+	deviceID, ok := api.authorizeIngestRequestForDevice(response, request)
+	if !ok {
 		return
 	}
 
@@ -204,21 +473,29 @@ func (api *API) handleIngestBatch(response http.ResponseWriter, request *http.Re
 		writeError(response, http.StatusBadRequest, err.Error())
 		return
 	}
+	for index := range readings {
+		readings[index].DeviceID = deviceID
+	}
 
 	if err := api.store.AddBatch(request.Context(), readings); err != nil {
+		logger.Error("ingest batch persist failed", "component", "ingest", "requestID", RequestIDFromContext(request.Context()), "error", err)
 		writeError(response, http.StatusInternalServerError, "failed to persist readings")
 		return
 	}
 
 	if len(readings) > 0 {
-		api.onTelemetryReceived(time.Now())
+		api.onTelemetryReceived(deviceID, time.Now())
 	}
 	for _, reading := range readings {
 		api.stream.publish(reading)
+		api.subscriptions.publish(reading)
 	}
 	if api.insightsEngine != nil {
 		api.insightsEngine.OnBatch(readings)
 	}
+	if api.rulesEngine != nil {
+		api.rulesEngine.OnBatch(readings)
+	}
 
 	writeJSON(response, http.StatusAccepted, map[string]any{
 		"status":   "accepted",
@@ -226,11 +503,79 @@ func (api *API) handleIngestBatch(response http.ResponseWriter, request *http.Re
 	})
 }
 
+func (api *API) handleIngestLine(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		writeError(response, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	deviceID, ok := api.authorizeIngestRequestForDevice(response, request)
+	if !ok {
+		return
+	}
+
+	request.Body = http.MaxBytesReader(response, request.Body, maxBatchBodyBytes)
+	payload, err := io.ReadAll(request.Body)
+	if err != nil {
+		writeError(response, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	precision := request.URL.Query().Get("precision")
+	if precision == "" {
+		precision = DefaultLineProtocolPrecision
+	}
+
+	readings, err := DecodeLineProtocolBatch(payload, maxBatchSize, precision)
+	if err != nil {
+		writeError(response, http.StatusBadRequest, err.Error())
+		return
+	}
+	for index := range readings {
+		readings[index].DeviceID = deviceID
+	}
+
+	if err := api.store.AddBatch(request.Context(), readings); err != nil {
+		logger.Error("ingest line persist failed", "component", "ingest", "requestID", RequestIDFromContext(request.Context()), "error", err)
+		writeError(response, http.StatusInternalServerError, "failed to persist readings")
+		return
+	}
+
+	api.onTelemetryReceived(deviceID, time.Now())
+	for _, reading := range readings {
+		api.stream.publish(reading)
+		api.subscriptions.publish(reading)
+	}
+	if api.insightsEngine != nil {
+		api.insightsEngine.OnBatch(readings)
+	}
+	if api.rulesEngine != nil {
+		api.rulesEngine.OnBatch(readings)
+	}
+
+	writeJSON(response, http.StatusAccepted, map[string]any{"ingested": len(readings)})
+}
+
 func (api *API) handleReadings(response http.ResponseWriter, request *http.Request) {
 	if request.Method != http.MethodGet {
 		writeError(response, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
+	if !api.authorizeReadRequest(response, request) {
+		return
+	}
+
+	chunkSize := defaultReadingsChunkSize
+	if rawChunkSize := request.URL.Query().Get("chunk_size"); rawChunkSize != "" {
+		parsedChunkSize, err := strconv.Atoi(rawChunkSize)
+		if err != nil || parsedChunkSize < 1 {
+			writeError(response, http.StatusBadRequest, "chunk_size must be a positive integer")
+			return
+		}
+		chunkSize = parsedChunkSize
+	}
+	csvFormat := wantsCSVFormat(request)
+	deviceIDFilter := strings.TrimSpace(request.URL.Query().Get("device_id"))
 
 	rawFrom := request.URL.Query().Get("from")
 	rawTo := request.URL.Query().Get("to")
@@ -286,7 +631,19 @@ func (api *API) handleReadings(response http.ResponseWriter, request *http.Reque
 			return
 		}
 
-		writeJSON(response, http.StatusOK, map[string]any{"readings": readings})
+		iterate := filterReadingsByDevice(func(visit func(SensorReading) error) error {
+			for _, reading := range readings {
+				if err := visit(reading); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, deviceIDFilter)
+		if csvFormat {
+			streamReadingsCSV(response, chunkSize, len(readings) >= maxPoints, iterate)
+		} else {
+			streamReadings(response, chunkSize, len(readings) >= maxPoints, iterate)
+		}
 		return
 	}
 
@@ -304,15 +661,122 @@ func (api *API) handleReadings(response http.ResponseWriter, request *http.Reque
 		limit = parsedLimit
 	}
 
+	if streamStore, ok := api.store.(readingsStreamStore); ok {
+		totalCount, countErr := api.store.Count(request.Context())
+		truncated := countErr == nil && totalCount > limit
+
+		iterate := filterReadingsByDevice(func(visit func(SensorReading) error) error {
+			return streamStore.LatestEach(request.Context(), limit, visit)
+		}, deviceIDFilter)
+		if csvFormat {
+			streamReadingsCSV(response, chunkSize, truncated, iterate)
+		} else {
+			streamReadings(response, chunkSize, truncated, iterate)
+		}
+		return
+	}
+
 	readings, err := api.store.Latest(request.Context(), limit)
 	if err != nil {
 		writeError(response, http.StatusInternalServerError, "failed to read data")
 		return
 	}
+	if deviceIDFilter != "" {
+		filtered := make([]SensorReading, 0, len(readings))
+		for _, reading := range readings {
+			if reading.DeviceID == deviceIDFilter {
+				filtered = append(filtered, reading)
+			}
+		}
+		readings = filtered
+	}
+
+	if csvFormat {
+		totalCount, countErr := api.store.Count(request.Context())
+		truncated := countErr == nil && totalCount > limit
+
+		streamReadingsCSV(response, chunkSize, truncated, func(visit func(SensorReading) error) error {
+			for _, reading := range readings {
+				if err := visit(reading); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		return
+	}
 
 	writeJSON(response, http.StatusOK, map[string]any{"readings": readings})
 }
 
+// filterReadingsByDevice wraps iterate so visit only sees readings whose
+// DeviceID matches deviceID, letting /api/readings?device_id=... reuse
+// the same streaming iterate path as the unfiltered case. An empty
+// deviceID is a no-op passthrough.
+func filterReadingsByDevice(
+	iterate func(visit func(SensorReading) error) error,
+	deviceID string,
+) func(visit func(SensorReading) error) error {
+	if deviceID == "" {
+		return iterate
+	}
+	return func(visit func(SensorReading) error) error {
+		return iterate(func(reading SensorReading) error {
+			if reading.DeviceID != deviceID {
+				return nil
+			}
+			return visit(reading)
+		})
+	}
+}
+
+// streamReadings writes readings as a JSON array without buffering the
+// full result set, flushing every chunkSize rows so large responses don't
+// sit fully in memory. X-Enviro-Count is only known once iteration
+// completes, so it is sent as an HTTP trailer; X-Enviro-Truncated is
+// known upfront and sent as a regular header.
+func streamReadings(
+	response http.ResponseWriter,
+	chunkSize int,
+	truncated bool,
+	iterate func(visit func(SensorReading) error) error,
+) {
+	response.Header().Set("Content-Type", "application/json")
+	response.Header().Set("Trailer", "X-Enviro-Count")
+	response.Header().Set("X-Enviro-Truncated", strconv.FormatBool(truncated))
+	response.WriteHeader(http.StatusOK)
+
+	flusher, _ := response.(http.Flusher)
+	encoder := json.NewEncoder(response)
+
+	count := 0
+	_, _ = io.WriteString(response, `{"readings":[`)
+	err := iterate(func(reading SensorReading) error {
+		if count > 0 {
+			if _, err := io.WriteString(response, ","); err != nil {
+				return err
+			}
+		}
+		if err := encoder.Encode(reading); err != nil {
+			return err
+		}
+		count++
+		if flusher != nil && count%chunkSize == 0 {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("stream readings failed", "component", "readings", "rows", count, "error", err)
+	}
+	_, _ = io.WriteString(response, "]}")
+
+	response.Header().Set("X-Enviro-Count", strconv.Itoa(count))
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
+
 func parseReadingsTimestamp(rawValue string) (int64, error) {
 	parsedValue, err := strconv.ParseInt(rawValue, 10, 64)
 	if err != nil {
@@ -330,6 +794,9 @@ func (api *API) handleStream(response http.ResponseWriter, request *http.Request
 		writeError(response, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
+	if !api.authorizeReadRequest(response, request) {
+		return
+	}
 
 	flusher, ok := response.(http.Flusher)
 	if !ok {
@@ -342,26 +809,139 @@ func (api *API) handleStream(response http.ResponseWriter, request *http.Request
 	response.Header().Set("Connection", "keep-alive")
 	response.Header().Set("X-Accel-Buffering", "no")
 
-	channel, unsubscribe := api.stream.subscribe()
+	sinceEventID, err := parseLastEventID(request)
+	if err != nil {
+		writeError(response, http.StatusBadRequest, "since must be a valid event id")
+		return
+	}
+
+	rawFields := request.URL.Query().Get("fields")
+	if rawFields == "" {
+		rawFields = request.URL.Query().Get("filter")
+	}
+	fields := parseStreamFields(rawFields)
+
+	subscriber, unsubscribe := api.stream.subscribe(sinceEventID, fields)
+	defer unsubscribe()
+
+	controller := http.NewResponseController(response)
+
+	heartbeatTicker := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeatTicker.Stop()
+
+	for {
+		select {
+		case <-request.Context().Done():
+			return
+		case <-api.lifecycleCtx.Done():
+			_ = controller.SetWriteDeadline(time.Now().Add(streamWriteDeadline))
+			_, _ = io.WriteString(response, "event: server_shutdown\ndata: {}\n\n")
+			flusher.Flush()
+			return
+		case event, ok := <-subscriber.events:
+			if !ok {
+				// Evicted by the hub as a slow consumer.
+				return
+			}
+			payload, err := json.Marshal(projectReading(event.Reading, subscriber.fields))
+			if err != nil {
+				continue
+			}
+			_ = controller.SetWriteDeadline(time.Now().Add(streamWriteDeadline))
+			if _, err = fmt.Fprintf(response, "id: %d\nevent: reading\ndata: %s\n\n", event.ID, payload); err != nil {
+				api.stream.reportDropped()
+				return
+			}
+			flusher.Flush()
+		case <-heartbeatTicker.C:
+			_ = controller.SetWriteDeadline(time.Now().Add(streamWriteDeadline))
+			if _, err := io.WriteString(response, ": ping\n\n"); err != nil {
+				api.stream.reportDropped()
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// parseLastEventID resolves the event id a reconnecting EventSource client
+// should resume after. The standard Last-Event-ID header takes precedence,
+// since browsers set it automatically on reconnect; ?since= is kept for
+// callers that aren't going through the EventSource protocol.
+func parseLastEventID(request *http.Request) (int64, error) {
+	raw := request.Header.Get("Last-Event-ID")
+	if raw == "" {
+		raw = request.URL.Query().Get("since")
+	}
+	if raw == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(raw, 10, 64)
+}
+
+// handleAlertsStream serves newly produced Alerts as Server-Sent Events, so
+// dashboards can react to analyzer output without polling /api/insights.
+// Reconnecting clients resume via Last-Event-ID/?since= the same way
+// handleStream does.
+func (api *API) handleAlertsStream(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		writeError(response, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !api.authorizeReadRequest(response, request) {
+		return
+	}
+
+	flusher, ok := response.(http.Flusher)
+	if !ok {
+		writeError(response, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	response.Header().Set("Content-Type", "text/event-stream")
+	response.Header().Set("Cache-Control", "no-cache")
+	response.Header().Set("Connection", "keep-alive")
+	response.Header().Set("X-Accel-Buffering", "no")
+
+	sinceEventID, err := parseLastEventID(request)
+	if err != nil {
+		writeError(response, http.StatusBadRequest, "since must be a valid event id")
+		return
+	}
+
+	subscriber, unsubscribe := api.alertStream.subscribe(sinceEventID)
 	defer unsubscribe()
 
-	heartbeatTicker := time.NewTicker(25 * time.Second)
+	controller := http.NewResponseController(response)
+
+	heartbeatTicker := time.NewTicker(streamHeartbeatInterval)
 	defer heartbeatTicker.Stop()
 
 	for {
 		select {
 		case <-request.Context().Done():
 			return
-		case reading := <-channel:
-			payload, err := json.Marshal(reading)
+		case <-api.lifecycleCtx.Done():
+			_ = controller.SetWriteDeadline(time.Now().Add(streamWriteDeadline))
+			_, _ = io.WriteString(response, "event: server_shutdown\ndata: {}\n\n")
+			flusher.Flush()
+			return
+		case event, ok := <-subscriber.events:
+			if !ok {
+				// Evicted for overflowing its buffer.
+				return
+			}
+			payload, err := json.Marshal(map[string]any{"source": event.Source, "alerts": event.Alerts})
 			if err != nil {
 				continue
 			}
-			if _, err = fmt.Fprintf(response, "event: reading\ndata: %s\n\n", payload); err != nil {
+			_ = controller.SetWriteDeadline(time.Now().Add(streamWriteDeadline))
+			if _, err = fmt.Fprintf(response, "id: %d\nevent: alerts\ndata: %s\n\n", event.ID, payload); err != nil {
 				return
 			}
 			flusher.Flush()
 		case <-heartbeatTicker.C:
+			_ = controller.SetWriteDeadline(time.Now().Add(streamWriteDeadline))
 			if _, err := io.WriteString(response, ": ping\n\n"); err != nil {
 				return
 			}
@@ -375,6 +955,9 @@ func (api *API) handleInsights(response http.ResponseWriter, request *http.Reque
 		writeError(response, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
+	if !api.authorizeReadRequest(response, request) {
+		return
+	}
 
 	if api.insightsEngine == nil {
 		writeError(response, http.StatusServiceUnavailable, "insights engine is not configured")
@@ -411,19 +994,48 @@ func (api *API) handleInsights(response http.ResponseWriter, request *http.Reque
 	})
 }
 
+// handleInsightsAnomalyStats exposes InsightsScheduler's anomaly detector
+// running statistics (EWMA mean, MAD, last z-score, warmup status) per
+// metric, so operators can tune AnomalyDetectorConfig thresholds against
+// what the detector is actually seeing. It's a no-op 503 unless an
+// AlertAnalyzer was configured via WithAlertAnalyzer, since that's the
+// only case where api.insightsEngine is a concrete *InsightsScheduler.
+func (api *API) handleInsightsAnomalyStats(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		writeError(response, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !api.authorizeReadRequest(response, request) {
+		return
+	}
+
+	scheduler, ok := api.insightsEngine.(*InsightsScheduler)
+	if !ok {
+		writeError(response, http.StatusServiceUnavailable, "anomaly detector is not configured")
+		return
+	}
+
+	writeJSON(response, http.StatusOK, map[string]any{"metrics": scheduler.AnomalyStats()})
+}
+
 func (api *API) handleOpsEvents(response http.ResponseWriter, request *http.Request) {
 	if request.Method != http.MethodGet {
 		writeError(response, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
+	if !api.authorizeReadRequest(response, request) {
+		return
+	}
 
 	if api.opsEventStore == nil {
 		writeJSON(response, http.StatusOK, map[string]any{"events": []OpsEvent{}})
 		return
 	}
 
+	query := request.URL.Query()
+
 	limit := 30
-	if rawLimit := request.URL.Query().Get("limit"); rawLimit != "" {
+	if rawLimit := query.Get("limit"); rawLimit != "" {
 		parsedLimit, err := strconv.Atoi(rawLimit)
 		if err != nil || parsedLimit < 1 || parsedLimit > maxOpsEventsLimit {
 			writeError(
@@ -436,22 +1048,184 @@ func (api *API) handleOpsEvents(response http.ResponseWriter, request *http.Requ
 		limit = parsedLimit
 	}
 
-	events, err := api.opsEventStore.LatestOpsEvents(request.Context(), limit)
+	since := int64(0)
+	if rawSince := query.Get("since"); rawSince != "" {
+		parsedSince, err := strconv.ParseInt(rawSince, 10, 64)
+		if err != nil {
+			writeError(response, http.StatusBadRequest, "since must be a unix millisecond timestamp")
+			return
+		}
+		since = parsedSince
+	}
+
+	cursor := int64(0)
+	if rawCursor := query.Get("cursor"); rawCursor != "" {
+		parsedCursor, err := strconv.ParseInt(rawCursor, 10, 64)
+		if err != nil {
+			writeError(response, http.StatusBadRequest, "cursor must be an ops event id")
+			return
+		}
+		cursor = parsedCursor
+	}
+
+	kind := strings.TrimSpace(query.Get("kind"))
+
+	querier, canQuery := api.opsEventStore.(opsEventQuerier)
+	if !canQuery || (since == 0 && kind == "" && cursor == 0) {
+		events, err := api.opsEventStore.LatestOpsEvents(request.Context(), limit)
+		if err != nil {
+			writeError(response, http.StatusInternalServerError, "failed to load ops events")
+			return
+		}
+		writeJSON(response, http.StatusOK, map[string]any{"events": events})
+		return
+	}
+
+	page, err := querier.QueryOpsEvents(request.Context(), OpsEventQuery{
+		Since:    since,
+		Kind:     kind,
+		Limit:    limit,
+		BeforeID: cursor,
+	})
 	if err != nil {
 		writeError(response, http.StatusInternalServerError, "failed to load ops events")
 		return
 	}
 
-	writeJSON(response, http.StatusOK, map[string]any{"events": events})
+	writeJSON(response, http.StatusOK, map[string]any{"events": page.Events, "next_cursor": page.NextCursor})
 }
 
+// handleDebugLogLevel lets an operator raise or lower the package-wide
+// slog level on a running deployment (e.g. to debug on a deployed Pi)
+// without a redeploy. It shares the ingest credential since it's a
+// privileged mutation, not a read.
+func (api *API) handleDebugLogLevel(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPut {
+		writeError(response, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !api.authorizeIngestRequest(response, request) {
+		return
+	}
+
+	var body struct {
+		Level string `json:"level"`
+	}
+	request.Body = http.MaxBytesReader(response, request.Body, 1024)
+	if err := json.NewDecoder(request.Body).Decode(&body); err != nil {
+		writeError(response, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	level, err := parseLogLevel(body.Level)
+	if err != nil {
+		writeError(response, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	logLevel.Set(level)
+	writeJSON(response, http.StatusOK, map[string]string{"level": level.String()})
+}
+
+// authorizeIngestRequest reports whether request carries valid ingest
+// credentials, without telling the caller which device (if any) they
+// resolved to. Most callers want authorizeIngestRequestForDevice instead;
+// this is kept for handlers (like handleDebugLogLevel) that gate on the
+// ingest credential but have no per-device reading to stamp.
 func (api *API) authorizeIngestRequest(response http.ResponseWriter, request *http.Request) bool {
-	providedKey := request.Header.Get("X-API-Key")
-	if subtle.ConstantTimeCompare([]byte(providedKey), []byte(api.ingestAPIKey)) != 1 {
-		writeError(response, http.StatusUnauthorized, "unauthorized")
-		return false
+	_, ok := api.authorizeIngestRequestForDevice(response, request)
+	return ok
+}
+
+// authorizeIngestRequestForDevice authorizes request the same way
+// authorizeIngestRequest does, additionally resolving which device the
+// credential belongs to. Bearer-token auth and the legacy shared
+// ingestAPIKey both resolve to defaultDeviceID, so readings ingested
+// under those credentials keep working exactly as before device
+// registration existed. On failure it writes the error response itself
+// and returns ok=false.
+func (api *API) authorizeIngestRequestForDevice(response http.ResponseWriter, request *http.Request) (deviceID string, ok bool) {
+	if certDeviceID, certOK := api.authorizeClientCert(request); certOK {
+		return certDeviceID, true
+	}
+
+	if api.authorizeBearerToken(request, scopeIngest) {
+		return defaultDeviceID, true
+	}
+
+	if deviceID, ok := api.authorizeIngestKey(request.Context(), request.Header.Get("X-API-Key")); ok {
+		return deviceID, true
+	}
+
+	writeError(response, http.StatusUnauthorized, "unauthorized")
+	return "", false
+}
+
+// authorizeIngestKey checks providedKey against the shared ingestAPIKey and
+// the device registry, the same credential the X-API-Key header carries
+// over HTTP. It's factored out of authorizeIngestRequestForDevice so the
+// UDP ingest listener (udp_ingest.go), which has no http.Request to read a
+// header from, can authorize a datagram's shared-secret field the same
+// way.
+func (api *API) authorizeIngestKey(ctx context.Context, providedKey string) (deviceID string, ok bool) {
+	if providedKey == "" {
+		return "", false
+	}
+	if api.ingestAPIKey != "" && constantTimeEquals(providedKey, api.ingestAPIKey) {
+		return defaultDeviceID, true
+	}
+	if api.deviceRegistry != nil {
+		if device, found, err := api.deviceRegistry.AuthenticateDevice(ctx, providedKey); err == nil && found {
+			return device.ID, true
+		}
 	}
-	return true
+	return "", false
+}
+
+// authorizeAdminRequest gates the device-management read/write endpoints
+// (list, revoke, rotate) on a separate admin credential, so a compromised
+// device key can't be used to manage the fleet.
+func (api *API) authorizeAdminRequest(response http.ResponseWriter, request *http.Request) bool {
+	if constantTimeEquals(request.Header.Get("X-Admin-Key"), api.adminAPIKey) {
+		return true
+	}
+	writeError(response, http.StatusUnauthorized, "unauthorized")
+	return false
+}
+
+// authorizeBootstrapRequest gates POST /api/devices/register on the
+// bootstrap token, kept separate from the admin and ingest
+// credentials so it can be handed to a provisioning script without also
+// granting fleet-management access.
+func (api *API) authorizeBootstrapRequest(response http.ResponseWriter, request *http.Request) bool {
+	if constantTimeEquals(request.Header.Get("X-Bootstrap-Token"), api.deviceBootstrapToken) {
+		return true
+	}
+	writeError(response, http.StatusUnauthorized, "unauthorized")
+	return false
+}
+
+// deviceConnectivityState tracks one device's last-known connectivity, the
+// per-device analogue of the single scalar state the server used before
+// device registration existed.
+type deviceConnectivityState struct {
+	known     bool
+	connected bool
+	lastSeen  time.Time
+}
+
+// deviceState returns the connectivity state for deviceID, lazily
+// creating it on first use. Callers must hold api.opsMu.
+func (api *API) deviceState(deviceID string) *deviceConnectivityState {
+	if deviceID == "" {
+		deviceID = defaultDeviceID
+	}
+	state, ok := api.deviceStates[deviceID]
+	if !ok {
+		state = &deviceConnectivityState{}
+		api.deviceStates[deviceID] = state
+	}
+	return state
 }
 
 func (api *API) initializeDeviceState() {
@@ -464,28 +1238,36 @@ func (api *API) initializeDeviceState() {
 
 	events, err := api.opsEventStore.LatestOpsEvents(ctx, 20)
 	if err != nil {
-		log.Printf("ops events initialization failed: %v", err)
+		logger.Error("ops events initialization failed", "component", "ops", "error", err)
 		return
 	}
+
+	seen := make(map[string]bool)
+	api.opsMu.Lock()
+	defer api.opsMu.Unlock()
 	for _, event := range events {
-		lastEventTime := time.UnixMilli(event.Timestamp)
+		deviceID := event.DeviceID
+		if deviceID == "" {
+			deviceID = defaultDeviceID
+		}
+		if seen[deviceID] {
+			continue
+		}
 
-		api.opsMu.Lock()
+		lastEventTime := time.UnixMilli(event.Timestamp)
 		switch event.Kind {
 		case "device_connected":
-			api.deviceStateKnown = true
-			api.deviceConnected = true
-			api.lastDeviceSeenAt = lastEventTime
-			api.opsMu.Unlock()
-			return
+			state := api.deviceState(deviceID)
+			state.known = true
+			state.connected = true
+			state.lastSeen = lastEventTime
+			seen[deviceID] = true
 		case "device_disconnected":
-			api.deviceStateKnown = true
-			api.deviceConnected = false
-			api.lastDeviceSeenAt = lastEventTime
-			api.opsMu.Unlock()
-			return
-		default:
-			api.opsMu.Unlock()
+			state := api.deviceState(deviceID)
+			state.known = true
+			state.connected = false
+			state.lastSeen = lastEventTime
+			seen[deviceID] = true
 		}
 	}
 }
@@ -508,61 +1290,114 @@ func (api *API) startDeviceMonitor(ctx context.Context) {
 				return
 			case now := <-ticker.C:
 				api.evaluateDeviceDisconnect(now)
+				api.pruneOpsEvents(now)
 			}
 		}
 	}()
 }
 
-func (api *API) onTelemetryReceived(observedAt time.Time) {
+// pruneOpsEvents deletes ops events older than OpsConfig.RetentionPeriod,
+// when the configured opsEventStore supports it (opsEventPruner). It's a
+// no-op for stores that don't implement pruning, and when
+// RetentionPeriod is zero.
+func (api *API) pruneOpsEvents(now time.Time) {
+	if api.opsConfig.RetentionPeriod <= 0 {
+		return
+	}
+	pruner, ok := api.opsEventStore.(opsEventPruner)
+	if !ok {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		cutoff := now.Add(-api.opsConfig.RetentionPeriod).UnixMilli()
+		deleted, err := pruner.PruneOpsEvents(ctx, cutoff)
+		if err != nil {
+			logger.Error("ops event retention prune failed", "component", "ops", "error", err)
+			return
+		}
+		if deleted > 0 {
+			logger.Debug("pruned expired ops events", "component", "ops", "deleted", deleted, "cutoff", cutoff)
+		}
+	}()
+}
+
+func (api *API) onTelemetryReceived(deviceID string, observedAt time.Time) {
 	if api.opsEventStore == nil {
 		return
 	}
+	if deviceID == "" {
+		deviceID = defaultDeviceID
+	}
 
 	shouldLogConnected := false
 
 	api.opsMu.Lock()
-	if !api.deviceStateKnown || !api.deviceConnected {
+	state := api.deviceState(deviceID)
+	if !state.known || !state.connected {
 		shouldLogConnected = true
 	}
-	api.deviceStateKnown = true
-	api.deviceConnected = true
-	api.lastDeviceSeenAt = observedAt
+	state.known = true
+	state.connected = true
+	state.lastSeen = observedAt
 	api.opsMu.Unlock()
 
 	if shouldLogConnected {
 		api.persistOpsEvent(
+			deviceID,
 			"device_connected",
 			"Device connected",
 			"Telemetry ingest resumed.",
 			observedAt.UnixMilli(),
 		)
+		api.alertStream.publish([]Alert{{
+			Kind:     "insight",
+			Severity: "info",
+			Title:    "Device back online",
+			Message:  fmt.Sprintf("Telemetry ingest resumed for %s.", deviceID),
+		}}, "ops")
 	}
 }
 
 func (api *API) evaluateDeviceDisconnect(now time.Time) {
-	shouldLogDisconnected := false
+	var disconnected []string
 
 	api.opsMu.Lock()
-	if api.deviceStateKnown &&
-		api.deviceConnected &&
-		!api.lastDeviceSeenAt.IsZero() &&
-		now.Sub(api.lastDeviceSeenAt) >= api.opsConfig.DeviceOfflineTimeout {
-		api.deviceConnected = false
-		shouldLogDisconnected = true
+	for deviceID, state := range api.deviceStates {
+		if state.known &&
+			state.connected &&
+			!state.lastSeen.IsZero() &&
+			now.Sub(state.lastSeen) >= api.opsConfig.DeviceOfflineTimeout {
+			state.connected = false
+			disconnected = append(disconnected, deviceID)
+		}
 	}
 	api.opsMu.Unlock()
 
-	if shouldLogDisconnected {
-		api.persistOpsEvent(
-			"device_disconnected",
-			"Device disconnected",
-			fmt.Sprintf("No telemetry received for %s.", api.opsConfig.DeviceOfflineTimeout),
-			now.UnixMilli(),
-		)
+	for _, deviceID := range disconnected {
+		detail := fmt.Sprintf("No telemetry received from %s for %s.", deviceID, api.opsConfig.DeviceOfflineTimeout)
+		api.persistOpsEvent(deviceID, "device_disconnected", "Device disconnected", detail, now.UnixMilli())
+		api.alertStream.publish([]Alert{{
+			Kind:     "alert",
+			Severity: "warn",
+			Title:    "Device offline",
+			Message:  detail,
+		}}, "ops")
 	}
 }
 
-func (api *API) persistOpsEvent(kind string, title string, detail string, timestamp int64) {
+func (api *API) persistOpsEvent(deviceID string, kind string, title string, detail string, timestamp int64) {
+	api.opsEventStream.publish(OpsEvent{
+		Timestamp: timestamp,
+		Kind:      kind,
+		Title:     title,
+		Detail:    detail,
+		DeviceID:  deviceID,
+	})
+
 	if api.opsEventStore == nil {
 		return
 	}
@@ -576,8 +1411,9 @@ func (api *API) persistOpsEvent(kind string, title string, detail string, timest
 			Kind:      kind,
 			Title:     title,
 			Detail:    detail,
+			DeviceID:  deviceID,
 		}); err != nil {
-			log.Printf("ops event persist failed kind=%s: %v", kind, err)
+			logger.Error("ops event persist failed", "component", "ops", "kind", kind, "error", err)
 		}
 	}()
 }
@@ -2,7 +2,9 @@ package server
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/ed25519"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -43,6 +45,10 @@ func (store *fakeStore) AddBatch(_ context.Context, readings []SensorReading) er
 	return nil
 }
 
+func (store *fakeStore) Count(_ context.Context) (int, error) {
+	return len(store.added), nil
+}
+
 func (store *fakeStore) Latest(_ context.Context, limit int) ([]SensorReading, error) {
 	if store.latestErr != nil {
 		return nil, store.latestErr
@@ -253,6 +259,73 @@ func TestHandleIngestBatchAcceptsMultipleReadings(t *testing.T) {
 	}
 }
 
+func TestHandleIngestLineAcceptsMultipleLines(t *testing.T) {
+	store := &fakeStore{}
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	payload := "enviro temperature=22.3,pressure=1012.5,humidity=45.1,pm1=1.0,pm2=2.0,pm10=3.0,oxidised=0.1,reduced=0.05,nh3=0.02 1700000000000000000\n" +
+		"enviro temperature=22.4,pressure=1012.6,humidity=45.2,pm1=1.1,pm2=2.1,pm10=3.1,oxidised=0.11,reduced=0.06,nh3=0.03 1700000001000000000\n"
+
+	request := httptest.NewRequest(http.MethodPost, "/api/ingest/line", bytes.NewBufferString(payload))
+	request.Header.Set("X-API-Key", "secret")
+	response := httptest.NewRecorder()
+
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d", http.StatusAccepted, response.Code)
+	}
+	if len(store.added) != 2 {
+		t.Fatalf("expected two stored readings, got %d", len(store.added))
+	}
+}
+
+func TestHandleIngestLineHonorsPrecisionQueryParam(t *testing.T) {
+	store := &fakeStore{}
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	request := httptest.NewRequest(
+		http.MethodPost,
+		"/api/ingest/line?precision=ms",
+		bytes.NewBufferString("enviro temperature=22.3 1700000000000"),
+	)
+	request.Header.Set("X-API-Key", "secret")
+	response := httptest.NewRecorder()
+
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d", http.StatusAccepted, response.Code)
+	}
+	if len(store.added) != 1 {
+		t.Fatalf("expected one stored reading, got %d", len(store.added))
+	}
+	if store.added[0].Timestamp != 1700000000 {
+		t.Fatalf("expected millisecond timestamp to be converted to 1700000000, got %d", store.added[0].Timestamp)
+	}
+}
+
+func TestHandleIngestLineRejectsUnauthorized(t *testing.T) {
+	store := &fakeStore{}
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	request := httptest.NewRequest(
+		http.MethodPost,
+		"/api/ingest/line",
+		bytes.NewBufferString("enviro temperature=22.3 1700000000000000000"),
+	)
+	response := httptest.NewRecorder()
+
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, response.Code)
+	}
+}
+
 func TestHandleIngestBatchRejectsOversizedBatch(t *testing.T) {
 	store := &fakeStore{}
 	api := NewAPI(store, "secret")
@@ -704,3 +777,205 @@ func TestDeviceConnectivityEventsArePersisted(t *testing.T) {
 	waitForEvent("device_connected")
 	waitForEvent("device_disconnected")
 }
+
+func TestHandleReadingsStreamsRangeQueryAsJSONArray(t *testing.T) {
+	store := &fakeStore{
+		ranged: []SensorReading{
+			{Timestamp: 1738886400, PM2: 3.2, PM10: 6.4},
+			{Timestamp: 1738888200, PM2: 4.1, PM10: 7.2},
+		},
+	}
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	request := httptest.NewRequest(
+		http.MethodGet,
+		"/api/readings?from=1738886400000&to=1738889999000&max_points=10&chunk_size=1",
+		nil,
+	)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, response.Code)
+	}
+
+	var payload struct {
+		Readings []SensorReading `json:"readings"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+	if len(payload.Readings) != 2 {
+		t.Fatalf("expected 2 readings, got %d", len(payload.Readings))
+	}
+	if response.Header().Get("X-Enviro-Count") != "2" {
+		t.Fatalf("expected X-Enviro-Count=2, got %q", response.Header().Get("X-Enviro-Count"))
+	}
+	if response.Header().Get("X-Enviro-Truncated") != "false" {
+		t.Fatalf("expected X-Enviro-Truncated=false, got %q", response.Header().Get("X-Enviro-Truncated"))
+	}
+}
+
+func TestHandleReadingsRejectsInvalidChunkSize(t *testing.T) {
+	store := &fakeStore{}
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	request := httptest.NewRequest(http.MethodGet, "/api/readings?chunk_size=0", nil)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, response.Code)
+	}
+}
+
+type streamingFakeStore struct {
+	*fakeStore
+}
+
+func (store *streamingFakeStore) LatestEach(ctx context.Context, limit int, visit func(SensorReading) error) error {
+	readings, err := store.Latest(ctx, limit)
+	if err != nil {
+		return err
+	}
+	for _, reading := range readings {
+		if err := visit(reading); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestHandleReadingsStreamsLatestWhenSupportedByStore(t *testing.T) {
+	store := &streamingFakeStore{fakeStore: &fakeStore{
+		latest: []SensorReading{
+			{Timestamp: 1738886400, PM2: 1},
+			{Timestamp: 1738886460, PM2: 2},
+			{Timestamp: 1738886520, PM2: 3},
+		},
+	}}
+	store.added = make([]SensorReading, 5)
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	request := httptest.NewRequest(http.MethodGet, "/api/readings?limit=2", nil)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, response.Code)
+	}
+	if response.Header().Get("X-Enviro-Truncated") != "true" {
+		t.Fatalf("expected truncated=true when count exceeds limit, got %q", response.Header().Get("X-Enviro-Truncated"))
+	}
+}
+
+func TestWithGzipCompressesWhenAcceptEncodingAdvertised(t *testing.T) {
+	store := &fakeStore{}
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	request := httptest.NewRequest(http.MethodGet, "/api/readings?limit=1", nil)
+	request.Header.Set("Accept-Encoding", "gzip")
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, response.Code)
+	}
+	if response.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected gzip Content-Encoding, got %q", response.Header().Get("Content-Encoding"))
+	}
+
+	gzipReader, err := gzip.NewReader(response.Body)
+	if err != nil {
+		t.Fatalf("new gzip reader: %v", err)
+	}
+	defer gzipReader.Close()
+
+	var payload struct {
+		Readings []SensorReading `json:"readings"`
+	}
+	if err := json.NewDecoder(gzipReader).Decode(&payload); err != nil {
+		t.Fatalf("decode gzipped payload: %v", err)
+	}
+}
+
+func TestHandleReadingsReturnsCSVWhenRequested(t *testing.T) {
+	store := &fakeStore{
+		latest: []SensorReading{
+			{Timestamp: 1738886400, Temperature: 22.4, PM2: 3.2},
+		},
+	}
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	request := httptest.NewRequest(http.MethodGet, "/api/readings?limit=1&format=csv", nil)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, response.Code)
+	}
+	if response.Header().Get("Content-Type") != "text/csv" {
+		t.Fatalf("expected text/csv content type, got %q", response.Header().Get("Content-Type"))
+	}
+
+	lines := strings.Split(strings.TrimSpace(response.Body.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected header + 1 row, got %d lines: %q", len(lines), response.Body.String())
+	}
+	if lines[0] != "timestamp,temperature,pressure,humidity,oxidised,reduced,nh3,pm1,pm2,pm10" {
+		t.Fatalf("unexpected CSV header: %q", lines[0])
+	}
+}
+
+func TestHandleMetricsExpositionIncludesLatestReadingAndDeviceState(t *testing.T) {
+	store := &fakeStore{
+		latest: []SensorReading{
+			{Timestamp: 1738886400, Temperature: 22.4, PM2: 3.2},
+		},
+	}
+	api := NewAPI(store, "secret")
+	handler := api.Handler()
+
+	request := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, response.Code)
+	}
+
+	body := response.Body.String()
+	if !strings.Contains(body, "enviro_temperature_celsius 22.4") {
+		t.Fatalf("expected temperature metric in body, got %q", body)
+	}
+	if !strings.Contains(body, "enviro_last_reading_timestamp_seconds 1738886400") {
+		t.Fatalf("expected last reading timestamp metric in body, got %q", body)
+	}
+	if !strings.Contains(body, "enviro_device_connected 0") {
+		t.Fatalf("expected device_connected metric in body, got %q", body)
+	}
+}
+
+func TestHandleMetricsExpositionRequiresReadScopeWhenEnabled(t *testing.T) {
+	publicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	store := &fakeStore{}
+	api := NewAPI(store, "secret", WithJWTPublicKey(publicKey), WithAuthRequired(scopeRead))
+	handler := api.Handler()
+
+	request := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, response.Code)
+	}
+}
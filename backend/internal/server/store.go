@@ -3,16 +3,36 @@ package server
 import (
 	"context"
 	"sync"
+	"time"
 )
 
 type Store interface {
 	Add(ctx context.Context, reading SensorReading) error
+	AddBatch(ctx context.Context, readings []SensorReading) error
 	Count(ctx context.Context) (int, error)
 	Latest(ctx context.Context, limit int) ([]SensorReading, error)
 	Ping(ctx context.Context) error
 	Close()
 }
 
+// readingsStreamStore is an optional Store capability that emits readings
+// one at a time via visit instead of buffering the whole result into a
+// slice, letting handlers stream very large responses without holding
+// them all in memory at once.
+type readingsStreamStore interface {
+	LatestEach(ctx context.Context, limit int, visit func(SensorReading) error) error
+}
+
+// ResolutionAwareStore is an optional Store capability for backends that
+// maintain downsampled rollups alongside raw readings (see PostgresStore's
+// retention-policy rollups in retention_policy.go/rollup.go):
+// LatestAtResolution transparently selects raw data or the coarsest rollup
+// that still evenly covers resolution, instead of callers always reading
+// raw history regardless of how coarse a view they actually need.
+type ResolutionAwareStore interface {
+	LatestAtResolution(ctx context.Context, limit int, resolution time.Duration) ([]SensorReading, error)
+}
+
 type MemoryStore struct {
 	mu          sync.RWMutex
 	maxReadings int
@@ -34,6 +54,7 @@ func (store *MemoryStore) Add(_ context.Context, reading SensorReading) error {
 	store.mu.Lock()
 	defer store.mu.Unlock()
 
+	store.checkOrdering(reading)
 	store.readings = append(store.readings, reading)
 	if len(store.readings) > store.maxReadings {
 		store.readings = append([]SensorReading(nil), store.readings[len(store.readings)-store.maxReadings:]...)
@@ -42,6 +63,41 @@ func (store *MemoryStore) Add(_ context.Context, reading SensorReading) error {
 	return nil
 }
 
+func (store *MemoryStore) AddBatch(_ context.Context, readings []SensorReading) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for _, reading := range readings {
+		store.checkOrdering(reading)
+		store.readings = append(store.readings, reading)
+	}
+
+	if len(store.readings) > store.maxReadings {
+		store.readings = append([]SensorReading(nil), store.readings[len(store.readings)-store.maxReadings:]...)
+	}
+
+	return nil
+}
+
+// checkOrdering bumps the out-of-order/duplicate-timestamp counters for
+// reading against the current tail, mirroring how a Prometheus scrape loop
+// flags samples that arrive out of sequence. Must be called with store.mu
+// held, and before reading is appended so the tail still reflects the
+// previous reading.
+func (store *MemoryStore) checkOrdering(reading SensorReading) {
+	if len(store.readings) == 0 {
+		return
+	}
+
+	tail := store.readings[len(store.readings)-1]
+	switch {
+	case reading.Timestamp < tail.Timestamp:
+		readingsOutOfOrderTotal.Inc()
+	case reading.Timestamp == tail.Timestamp:
+		readingsDuplicateTimestampTotal.Inc()
+	}
+}
+
 func (store *MemoryStore) Count(_ context.Context) (int, error) {
 	store.mu.RLock()
 	defer store.mu.RUnlock()
@@ -62,6 +118,24 @@ func (store *MemoryStore) Latest(_ context.Context, limit int) ([]SensorReading,
 	return output, nil
 }
 
+func (store *MemoryStore) LatestEach(_ context.Context, limit int, visit func(SensorReading) error) error {
+	store.mu.RLock()
+	if limit <= 0 || limit > len(store.readings) {
+		limit = len(store.readings)
+	}
+	start := len(store.readings) - limit
+	snapshot := make([]SensorReading, limit)
+	copy(snapshot, store.readings[start:])
+	store.mu.RUnlock()
+
+	for _, reading := range snapshot {
+		if err := visit(reading); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (store *MemoryStore) Ping(_ context.Context) error {
 	return nil
 }
@@ -1,47 +1,201 @@
 package server
 
-import "sync"
+import (
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+const streamSubscriberBuffer = 64
+
+// streamHistorySize bounds how many recent events streamHub retains for
+// replay when a client reconnects with ?since=<event_id>.
+const streamHistorySize = 256
+
+type streamEvent struct {
+	ID      int64
+	Reading SensorReading
+}
+
+type streamSubscriber struct {
+	events chan streamEvent
+	fields map[string]struct{}
+}
 
 type streamHub struct {
 	mu          sync.RWMutex
-	subscribers map[chan SensorReading]struct{}
+	subscribers map[*streamSubscriber]struct{}
+	history     []streamEvent
+	nextEventID int64
+	log         *slog.Logger
+
+	// onSlowConsumerDropped, when set, is invoked whenever a subscriber is
+	// evicted for overflowing its buffer or missing a write deadline.
+	onSlowConsumerDropped func()
 }
 
 func newStreamHub() *streamHub {
-	return &streamHub{subscribers: make(map[chan SensorReading]struct{})}
+	return &streamHub{
+		subscribers: make(map[*streamSubscriber]struct{}),
+		log:         logger.With("component", "stream"),
+	}
 }
 
-func (hub *streamHub) subscribe() (chan SensorReading, func()) {
-	channel := make(chan SensorReading, 64)
+// subscribe registers a new subscriber, optionally replaying events newer
+// than sinceEventID from the in-memory history and projecting published
+// readings down to fields (nil/empty means "all fields").
+func (hub *streamHub) subscribe(sinceEventID int64, fields map[string]struct{}) (*streamSubscriber, func()) {
+	subscriber := &streamSubscriber{
+		events: make(chan streamEvent, streamSubscriberBuffer),
+		fields: fields,
+	}
 
 	hub.mu.Lock()
-	hub.subscribers[channel] = struct{}{}
+	hub.subscribers[subscriber] = struct{}{}
+	var replay []streamEvent
+	if sinceEventID > 0 {
+		for _, event := range hub.history {
+			if event.ID > sinceEventID {
+				replay = append(replay, event)
+			}
+		}
+	}
+	subscriberCount := len(hub.subscribers)
 	hub.mu.Unlock()
 
+	streamSubscribersGauge.Set(float64(subscriberCount))
+	hub.log.Debug("stream subscriber added", "subscribers", subscriberCount)
+
+	for _, event := range replay {
+		select {
+		case subscriber.events <- event:
+		default:
+		}
+	}
+
 	unsubscribe := func() {
 		hub.mu.Lock()
-		if _, exists := hub.subscribers[channel]; exists {
-			delete(hub.subscribers, channel)
-			close(channel)
+		if _, exists := hub.subscribers[subscriber]; exists {
+			delete(hub.subscribers, subscriber)
+			close(subscriber.events)
 		}
+		subscriberCount := len(hub.subscribers)
 		hub.mu.Unlock()
+
+		streamSubscribersGauge.Set(float64(subscriberCount))
+		hub.log.Debug("stream subscriber removed", "subscribers", subscriberCount)
 	}
 
-	return channel, unsubscribe
+	return subscriber, unsubscribe
 }
 
+// publish fans a reading out to every subscriber. A subscriber whose
+// buffer is full is treated as a slow consumer: it is evicted rather than
+// blocking the ingest path, and onSlowConsumerDropped is notified.
 func (hub *streamHub) publish(reading SensorReading) {
-	hub.mu.RLock()
-	subscribers := make([]chan SensorReading, 0, len(hub.subscribers))
+	hub.mu.Lock()
+	hub.nextEventID++
+	event := streamEvent{ID: hub.nextEventID, Reading: reading}
+	hub.history = append(hub.history, event)
+	if len(hub.history) > streamHistorySize {
+		hub.history = append([]streamEvent(nil), hub.history[len(hub.history)-streamHistorySize:]...)
+	}
+
+	subscribers := make([]*streamSubscriber, 0, len(hub.subscribers))
 	for subscriber := range hub.subscribers {
 		subscribers = append(subscribers, subscriber)
 	}
-	hub.mu.RUnlock()
+	hub.mu.Unlock()
 
 	for _, subscriber := range subscribers {
 		select {
-		case subscriber <- reading:
+		case subscriber.events <- event:
 		default:
+			hub.evict(subscriber)
+		}
+	}
+}
+
+// evict drops a subscriber that has overflowed its buffer or failed to
+// keep up with writes, closing its channel and reporting the drop.
+func (hub *streamHub) evict(subscriber *streamSubscriber) {
+	hub.mu.Lock()
+	_, exists := hub.subscribers[subscriber]
+	if exists {
+		delete(hub.subscribers, subscriber)
+		close(subscriber.events)
+	}
+	subscriberCount := len(hub.subscribers)
+	hub.mu.Unlock()
+
+	if exists {
+		streamSubscribersGauge.Set(float64(subscriberCount))
+		hub.log.Debug("stream subscriber evicted", "subscribers", subscriberCount)
+		hub.reportDropped()
+	}
+}
+
+func (hub *streamHub) reportDropped() {
+	if hub.onSlowConsumerDropped != nil {
+		hub.onSlowConsumerDropped()
+	}
+}
+
+func (hub *streamHub) subscriberCount() int {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	return len(hub.subscribers)
+}
+
+// parseStreamFields validates a comma-separated ?filter= value against the
+// known SensorReading field keys, silently dropping anything unrecognized.
+func parseStreamFields(raw string) map[string]struct{} {
+	if raw == "" {
+		return nil
+	}
+
+	fields := make(map[string]struct{})
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+		if _, known := queryMetricAccessors[field]; known {
+			fields[field] = struct{}{}
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// projectReading narrows a reading down to the requested fields (always
+// including the timestamp) for bandwidth-constrained subscribers.
+func projectReading(reading SensorReading, fields map[string]struct{}) any {
+	if len(fields) == 0 {
+		return reading
+	}
+
+	projected := map[string]any{"timestamp": reading.Timestamp}
+	for field := range fields {
+		switch field {
+		case "temperature":
+			projected["temperature"] = reading.Temperature
+		case "pressure":
+			projected["pressure"] = reading.Pressure
+		case "humidity":
+			projected["humidity"] = reading.Humidity
+		case "oxidised":
+			projected["oxidised"] = reading.Oxidised
+		case "reduced":
+			projected["reduced"] = reading.Reduced
+		case "nh3":
+			projected["nh3"] = reading.Nh3
+		case "pm1":
+			projected["pm1"] = reading.PM1
+		case "pm2":
+			projected["pm2"] = reading.PM2
+		case "pm10":
+			projected["pm10"] = reading.PM10
 		}
 	}
+	return projected
 }
@@ -7,18 +7,106 @@ import (
 
 func TestStreamHubPublishDeliversReading(t *testing.T) {
 	hub := newStreamHub()
-	channel, unsubscribe := hub.subscribe()
+	subscriber, unsubscribe := hub.subscribe(0, nil)
 	defer unsubscribe()
 
 	reading := SensorReading{Timestamp: 1738886400, Temperature: 22.4}
 	hub.publish(reading)
 
 	select {
-	case received := <-channel:
-		if received.Timestamp != reading.Timestamp {
-			t.Fatalf("expected timestamp %d, got %d", reading.Timestamp, received.Timestamp)
+	case event := <-subscriber.events:
+		if event.Reading.Timestamp != reading.Timestamp {
+			t.Fatalf("expected timestamp %d, got %d", reading.Timestamp, event.Reading.Timestamp)
+		}
+		if event.ID != 1 {
+			t.Fatalf("expected first event id 1, got %d", event.ID)
 		}
 	case <-time.After(time.Second):
 		t.Fatal("expected published reading")
 	}
 }
+
+func TestStreamHubSubscribeReplaysSinceEventID(t *testing.T) {
+	hub := newStreamHub()
+
+	hub.publish(SensorReading{Timestamp: 1738886400})
+	hub.publish(SensorReading{Timestamp: 1738886401})
+	hub.publish(SensorReading{Timestamp: 1738886402})
+
+	subscriber, unsubscribe := hub.subscribe(2, nil)
+	defer unsubscribe()
+
+	select {
+	case event := <-subscriber.events:
+		if event.ID != 3 {
+			t.Fatalf("expected replay to resume at event id 3, got %d", event.ID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected replayed event")
+	}
+
+	select {
+	case event := <-subscriber.events:
+		t.Fatalf("expected no further replayed events, got %+v", event)
+	default:
+	}
+}
+
+func TestStreamHubEvictsSlowConsumerOnBufferOverflow(t *testing.T) {
+	hub := newStreamHub()
+
+	dropped := make(chan struct{}, 1)
+	hub.onSlowConsumerDropped = func() {
+		select {
+		case dropped <- struct{}{}:
+		default:
+		}
+	}
+
+	subscriber, unsubscribe := hub.subscribe(0, nil)
+	defer unsubscribe()
+
+	for i := 0; i < streamSubscriberBuffer+1; i++ {
+		hub.publish(SensorReading{Timestamp: int64(i)})
+	}
+
+	select {
+	case <-dropped:
+	case <-time.After(time.Second):
+		t.Fatal("expected slow consumer to be evicted")
+	}
+
+	for {
+		if _, ok := <-subscriber.events; !ok {
+			break
+		}
+	}
+}
+
+func TestParseStreamFieldsFiltersUnknownFields(t *testing.T) {
+	fields := parseStreamFields("temperature,pm2,bogus")
+	if len(fields) != 2 {
+		t.Fatalf("expected 2 known fields, got %d", len(fields))
+	}
+	if _, ok := fields["temperature"]; !ok {
+		t.Fatal("expected temperature to be included")
+	}
+	if _, ok := fields["pm2"]; !ok {
+		t.Fatal("expected pm2 to be included")
+	}
+}
+
+func TestProjectReadingNarrowsToRequestedFields(t *testing.T) {
+	reading := SensorReading{Timestamp: 1738886400, Temperature: 22.4, PM2: 3.1}
+
+	projected, ok := projectReading(reading, map[string]struct{}{"temperature": {}}).(map[string]any)
+	if !ok {
+		t.Fatalf("expected a projected map, got %T", projected)
+	}
+	if _, hasPM2 := projected["pm2"]; hasPM2 {
+		t.Fatal("expected pm2 to be excluded from projection")
+	}
+	if projected["temperature"] != reading.Temperature {
+		t.Fatalf("expected temperature %v, got %v", reading.Temperature, projected["temperature"])
+	}
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"fmt"
+)
+
+// AddSubscription inserts definition into the subscriptions table and
+// starts delivering to it immediately via the in-process broker, so a
+// newly-registered subscription doesn't have to wait for a restart.
+func (store *PostgresStore) AddSubscription(ctx context.Context, definition SubscriptionDefinition) (SubscriptionDefinition, error) {
+	const query = `
+INSERT INTO subscriptions (name, url, mode, filter)
+VALUES ($1, $2, $3, $4)
+RETURNING id
+`
+
+	err := store.pool.QueryRow(ctx, query, definition.Name, definition.URL, definition.Mode, definition.Filter).Scan(&definition.ID)
+	if err != nil {
+		return SubscriptionDefinition{}, fmt.Errorf("insert subscription: %w", err)
+	}
+
+	if err := store.subscriptionBroker.add(definition); err != nil {
+		_ = store.DeleteSubscription(ctx, definition.ID)
+		return SubscriptionDefinition{}, err
+	}
+
+	return definition, nil
+}
+
+func (store *PostgresStore) ListSubscriptions(ctx context.Context) ([]SubscriptionDefinition, error) {
+	const query = `SELECT id, name, url, mode, filter FROM subscriptions ORDER BY id ASC`
+
+	rows, err := store.pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscriptions := make([]SubscriptionDefinition, 0)
+	for rows.Next() {
+		var definition SubscriptionDefinition
+		if err := rows.Scan(&definition.ID, &definition.Name, &definition.URL, &definition.Mode, &definition.Filter); err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, definition)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+func (store *PostgresStore) DeleteSubscription(ctx context.Context, id int64) error {
+	const query = `DELETE FROM subscriptions WHERE id = $1`
+
+	tag, err := store.pool.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("subscription %d not found", id)
+	}
+
+	store.subscriptionBroker.remove(id)
+	return nil
+}
+
+// loadSubscriptions populates the broker from every persisted subscription
+// so registrations made via POST /api/subscriptions survive a restart. A
+// subscription whose URL no longer parses (e.g. hand-edited in the
+// database) is logged and skipped rather than failing startup.
+func (store *PostgresStore) loadSubscriptions(ctx context.Context) error {
+	subscriptions, err := store.ListSubscriptions(ctx)
+	if err != nil {
+		return fmt.Errorf("list subscriptions: %w", err)
+	}
+
+	for _, definition := range subscriptions {
+		if err := store.subscriptionBroker.add(definition); err != nil {
+			logger.Warn("skipping subscription on startup",
+				"component", "subscriptions", "name", definition.Name, "error", err)
+		}
+	}
+
+	return nil
+}
+
+var _ SubscriptionStore = (*PostgresStore)(nil)
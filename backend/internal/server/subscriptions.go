@@ -0,0 +1,190 @@
+package server
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	subscriptionQueueSize         = 256
+	subscriptionSnapshotQueueSize = 8
+	subscriptionMaxRetries        = 5
+	subscriptionBaseBackoff       = 500 * time.Millisecond
+	subscriptionMaxBackoff        = 30 * time.Second
+)
+
+// Subscriber receives a copy of every ingested reading and every recomputed
+// InsightsSnapshot, used to forward telemetry to external sinks (webhooks,
+// MQTT brokers, InfluxDB) without blocking the ingest path.
+type Subscriber interface {
+	Name() string
+	Publish(ctx context.Context, reading SensorReading) error
+	PublishInsights(ctx context.Context, snapshot InsightsSnapshot) error
+}
+
+// subscriptionSink runs one Subscriber on its own goroutine, decoupling a
+// slow or failing sink from the ingest path and from every other sink.
+type subscriptionSink struct {
+	subscriber    Subscriber
+	queue         chan SensorReading
+	snapshotQueue chan InsightsSnapshot
+	dropped       int64
+	failed        int64
+}
+
+func newSubscriptionSink(ctx context.Context, subscriber Subscriber) *subscriptionSink {
+	sink := &subscriptionSink{
+		subscriber:    subscriber,
+		queue:         make(chan SensorReading, subscriptionQueueSize),
+		snapshotQueue: make(chan InsightsSnapshot, subscriptionSnapshotQueueSize),
+	}
+	go sink.run(ctx)
+	return sink
+}
+
+// publish enqueues reading, dropping the oldest queued reading to make room
+// when the sink can't keep up, so a stuck external sink can never block
+// ingest or grow without bound. It reports whether a drop occurred, so a
+// caller that wants to surface overflow beyond the dropped counter (e.g.
+// as an ops event) can do so.
+func (sink *subscriptionSink) publish(reading SensorReading) bool {
+	select {
+	case sink.queue <- reading:
+		return false
+	default:
+	}
+
+	dropped := false
+	select {
+	case <-sink.queue:
+		atomic.AddInt64(&sink.dropped, 1)
+		dropped = true
+	default:
+	}
+
+	select {
+	case sink.queue <- reading:
+	default:
+	}
+
+	return dropped
+}
+
+// publishSnapshot enqueues an InsightsSnapshot using the same drop-oldest
+// policy as publish.
+func (sink *subscriptionSink) publishSnapshot(snapshot InsightsSnapshot) {
+	select {
+	case sink.snapshotQueue <- snapshot:
+		return
+	default:
+	}
+
+	select {
+	case <-sink.snapshotQueue:
+		atomic.AddInt64(&sink.dropped, 1)
+	default:
+	}
+
+	select {
+	case sink.snapshotQueue <- snapshot:
+	default:
+	}
+}
+
+func (sink *subscriptionSink) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case reading := <-sink.queue:
+			sink.deliver(ctx, func(deliverCtx context.Context) error {
+				return sink.subscriber.Publish(deliverCtx, reading)
+			})
+		case snapshot := <-sink.snapshotQueue:
+			sink.deliver(ctx, func(deliverCtx context.Context) error {
+				return sink.subscriber.PublishInsights(deliverCtx, snapshot)
+			})
+		}
+	}
+}
+
+// deliver retries send with exponential backoff, giving up after
+// subscriptionMaxRetries so one unreachable sink can't wedge its goroutine
+// on a single item forever.
+func (sink *subscriptionSink) deliver(ctx context.Context, send func(context.Context) error) {
+	backoff := subscriptionBaseBackoff
+
+	for attempt := 1; attempt <= subscriptionMaxRetries; attempt++ {
+		err := send(ctx)
+		if err == nil {
+			return
+		}
+
+		if attempt == subscriptionMaxRetries {
+			atomic.AddInt64(&sink.failed, 1)
+			logger.Error("subscription sink giving up",
+				"component", "subscriptions", "sink", sink.subscriber.Name(), "attempt", attempt, "error", err)
+			return
+		}
+
+		logger.Warn("subscription sink attempt failed",
+			"component", "subscriptions", "sink", sink.subscriber.Name(), "attempt", attempt, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > subscriptionMaxBackoff {
+			backoff = subscriptionMaxBackoff
+		}
+	}
+}
+
+// DroppedCount reports how many queued items were discarded because the
+// sink fell behind.
+func (sink *subscriptionSink) DroppedCount() int64 { return atomic.LoadInt64(&sink.dropped) }
+
+// FailedCount reports how many items were given up on after exhausting
+// retries.
+func (sink *subscriptionSink) FailedCount() int64 { return atomic.LoadInt64(&sink.failed) }
+
+// subscriptionHub fans out every published reading and insights snapshot to
+// a fixed set of subscription sinks, mirroring streamHub's publish hook but
+// for external sinks instead of live HTTP subscribers.
+type subscriptionHub struct {
+	sinks []*subscriptionSink
+}
+
+func newSubscriptionHub(ctx context.Context, subscribers []Subscriber) *subscriptionHub {
+	hub := &subscriptionHub{sinks: make([]*subscriptionSink, 0, len(subscribers))}
+	for _, subscriber := range subscribers {
+		hub.sinks = append(hub.sinks, newSubscriptionSink(ctx, subscriber))
+	}
+	return hub
+}
+
+func (hub *subscriptionHub) publish(reading SensorReading) {
+	for _, sink := range hub.sinks {
+		sink.publish(reading)
+	}
+}
+
+func (hub *subscriptionHub) publishSnapshot(snapshot InsightsSnapshot) {
+	for _, sink := range hub.sinks {
+		sink.publishSnapshot(snapshot)
+	}
+}
+
+// subscriptionMetrics reports the per-sink dropped and failed counters for
+// Prometheus exposition, keyed by Subscriber.Name().
+func (hub *subscriptionHub) subscriptionMetrics() map[string][2]int64 {
+	metrics := make(map[string][2]int64, len(hub.sinks))
+	for _, sink := range hub.sinks {
+		metrics[sink.subscriber.Name()] = [2]int64{sink.DroppedCount(), sink.FailedCount()}
+	}
+	return metrics
+}
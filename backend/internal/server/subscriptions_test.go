@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeSubscriber struct {
+	name string
+
+	mu        sync.Mutex
+	readings  []SensorReading
+	snapshots []InsightsSnapshot
+	failUntil int
+	calls     int
+}
+
+func (subscriber *fakeSubscriber) Name() string { return subscriber.name }
+
+func (subscriber *fakeSubscriber) Publish(ctx context.Context, reading SensorReading) error {
+	subscriber.mu.Lock()
+	defer subscriber.mu.Unlock()
+
+	subscriber.calls++
+	if subscriber.calls <= subscriber.failUntil {
+		return errors.New("simulated failure")
+	}
+	subscriber.readings = append(subscriber.readings, reading)
+	return nil
+}
+
+func (subscriber *fakeSubscriber) PublishInsights(ctx context.Context, snapshot InsightsSnapshot) error {
+	subscriber.mu.Lock()
+	defer subscriber.mu.Unlock()
+
+	subscriber.snapshots = append(subscriber.snapshots, snapshot)
+	return nil
+}
+
+func TestSubscriptionHubDeliversReadingToEverySink(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sinkA := &fakeSubscriber{name: "a"}
+	sinkB := &fakeSubscriber{name: "b"}
+	hub := newSubscriptionHub(ctx, []Subscriber{sinkA, sinkB})
+
+	hub.publish(SensorReading{Timestamp: 1738886400, Temperature: 21.5})
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		sinkA.mu.Lock()
+		sinkB.mu.Lock()
+		done := len(sinkA.readings) == 1 && len(sinkB.readings) == 1
+		sinkA.mu.Unlock()
+		sinkB.mu.Unlock()
+		if done {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("expected both sinks to receive the reading")
+}
+
+func TestSubscriptionSinkRetriesBeforeSucceeding(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	subscriber := &fakeSubscriber{name: "flaky", failUntil: 2}
+	sink := newSubscriptionSink(ctx, subscriber)
+	sink.publish(SensorReading{Timestamp: 1738886400})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		subscriber.mu.Lock()
+		delivered := len(subscriber.readings) == 1
+		subscriber.mu.Unlock()
+		if delivered {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("expected the reading to eventually be delivered")
+}
+
+func TestSubscriptionSinkDropsOldestWhenQueueFull(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// A subscriber that blocks forever so the queue backs up.
+	block := make(chan struct{})
+	defer close(block)
+	subscriber := &blockingSubscriber{name: "blocked", block: block}
+	sink := newSubscriptionSink(ctx, subscriber)
+
+	for i := 0; i < subscriptionQueueSize+5; i++ {
+		sink.publish(SensorReading{Timestamp: int64(i)})
+	}
+
+	if sink.DroppedCount() == 0 {
+		t.Fatal("expected dropped count to be greater than zero once the queue overflows")
+	}
+}
+
+type blockingSubscriber struct {
+	name  string
+	block chan struct{}
+}
+
+func (subscriber *blockingSubscriber) Name() string { return subscriber.name }
+
+func (subscriber *blockingSubscriber) Publish(ctx context.Context, reading SensorReading) error {
+	<-subscriber.block
+	return nil
+}
+
+func (subscriber *blockingSubscriber) PublishInsights(ctx context.Context, snapshot InsightsSnapshot) error {
+	<-subscriber.block
+	return nil
+}
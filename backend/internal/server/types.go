@@ -18,6 +18,11 @@ type SensorReading struct {
 	PM1         float64 `json:"pm1"`
 	PM2         float64 `json:"pm2"`
 	PM10        float64 `json:"pm10"`
+	// DeviceID identifies which registered device (see device_registry.go)
+	// submitted this reading. It's never accepted from the request
+	// payload itself -- ingest handlers stamp it from the authenticated
+	// device resolved from X-API-Key, so it can't be spoofed.
+	DeviceID string `json:"device_id,omitempty"`
 }
 
 var allowedReadingKeys = map[string]struct{}{
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// UDPIngestFormatJSON decodes each datagram's body the same way
+	// DecodeReading does for /api/ingest.
+	UDPIngestFormatJSON = "json"
+	// UDPIngestFormatLine decodes each datagram's body as a single line
+	// protocol point, the same grammar /api/ingest/line accepts.
+	UDPIngestFormatLine = "line"
+
+	defaultUDPIngestCoalesceInterval = 250 * time.Millisecond
+	defaultUDPIngestCoalesceMaxBatch = 200
+	udpIngestReadBufferSize          = 64 * 1024
+)
+
+// UDPIngestConfig configures StartUDPIngestListener. Format picks the
+// datagram body's encoding; CoalesceInterval/CoalesceMaxBatch bound how
+// long (and how many) readings are buffered before a single AddBatch call,
+// so a burst of per-reading UDP pushes from a fleet of low-power sensors
+// doesn't turn into a flood of single-row inserts.
+type UDPIngestConfig struct {
+	Addr             string
+	Format           string
+	CoalesceInterval time.Duration
+	CoalesceMaxBatch int
+}
+
+// udpIngestServer listens for fire-and-forget sensor pushes over UDP, the
+// low-overhead input InfluxDB calls a UDP listener -- important for
+// battery-powered ESP32/Pi Pico sensors that can't afford a TCP+TLS
+// connection per reading. Each datagram carries the shared ingest secret
+// as its first field (terminated by a newline) followed by the reading
+// body, mirroring the X-API-Key credential the HTTP handlers check, and
+// accepted readings are coalesced into batches delivered to the store via
+// AddBatch rather than one Add call per packet.
+type udpIngestServer struct {
+	api    *API
+	config UDPIngestConfig
+	conn   *net.UDPConn
+
+	mu      sync.Mutex
+	pending []SensorReading
+}
+
+// StartUDPIngestListener starts a UDP listener on config.Addr and returns
+// it; the caller is responsible for calling Close (typically from the same
+// shutdown path that closes the HTTP listener) and for cancelling ctx to
+// stop its background goroutines. Readings that authorize and decode
+// successfully flow through the same stream/subscription/insights/rules
+// hooks handleIngestBatch does.
+func (api *API) StartUDPIngestListener(ctx context.Context, config UDPIngestConfig) (*udpIngestServer, error) {
+	switch config.Format {
+	case UDPIngestFormatJSON, UDPIngestFormatLine:
+	default:
+		return nil, fmt.Errorf("udp ingest format must be %q or %q", UDPIngestFormatJSON, UDPIngestFormatLine)
+	}
+	if config.CoalesceInterval <= 0 {
+		config.CoalesceInterval = defaultUDPIngestCoalesceInterval
+	}
+	if config.CoalesceMaxBatch <= 0 {
+		config.CoalesceMaxBatch = defaultUDPIngestCoalesceMaxBatch
+	}
+
+	addr, err := net.ResolveUDPAddr("udp", config.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("resolve udp address: %w", err)
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen udp: %w", err)
+	}
+
+	server := &udpIngestServer{api: api, config: config, conn: conn}
+	go server.readLoop(ctx)
+	go server.flushLoop(ctx)
+
+	return server, nil
+}
+
+func (server *udpIngestServer) Close() error {
+	return server.conn.Close()
+}
+
+func (server *udpIngestServer) readLoop(ctx context.Context) {
+	buffer := make([]byte, udpIngestReadBufferSize)
+
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		bytesRead, _, err := server.conn.ReadFromUDP(buffer)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logger.Warn("udp ingest read failed", "component", "udp_ingest", "error", err)
+			continue
+		}
+
+		reading, ok := server.decode(buffer[:bytesRead])
+		if !ok {
+			continue
+		}
+
+		server.enqueue(reading)
+	}
+}
+
+// decode splits datagram on its first newline into the shared-secret
+// field and the reading body, authorizes the secret the same way
+// authorizeIngestRequestForDevice's X-API-Key branch does, and decodes the
+// body per config.Format. It logs and drops anything that doesn't
+// authorize or parse, since there's no per-datagram response to send back
+// over a fire-and-forget transport.
+func (server *udpIngestServer) decode(datagram []byte) (SensorReading, bool) {
+	newlineIndex := bytes.IndexByte(datagram, '\n')
+	if newlineIndex < 0 {
+		logger.Warn("udp ingest datagram missing api key field", "component", "udp_ingest")
+		return SensorReading{}, false
+	}
+
+	apiKey := string(bytes.TrimSpace(datagram[:newlineIndex]))
+	body := bytes.TrimSpace(datagram[newlineIndex+1:])
+
+	deviceID, ok := server.api.authorizeIngestKey(context.Background(), apiKey)
+	if !ok {
+		logger.Warn("udp ingest datagram rejected: unauthorized", "component", "udp_ingest")
+		return SensorReading{}, false
+	}
+
+	var reading SensorReading
+	var err error
+	if server.config.Format == UDPIngestFormatLine {
+		var readings []SensorReading
+		readings, err = DecodeLineProtocolBatch(body, 1, DefaultLineProtocolPrecision)
+		if err == nil {
+			if len(readings) == 0 {
+				err = fmt.Errorf("no points in datagram")
+			} else {
+				reading = readings[0]
+			}
+		}
+	} else {
+		reading, err = DecodeReading(body)
+	}
+	if err != nil {
+		logger.Warn("udp ingest datagram decode failed", "component", "udp_ingest", "error", err)
+		return SensorReading{}, false
+	}
+
+	reading.DeviceID = deviceID
+	return reading, true
+}
+
+func (server *udpIngestServer) enqueue(reading SensorReading) {
+	server.mu.Lock()
+	server.pending = append(server.pending, reading)
+	shouldFlush := len(server.pending) >= server.config.CoalesceMaxBatch
+	server.mu.Unlock()
+
+	if shouldFlush {
+		server.flush()
+	}
+}
+
+func (server *udpIngestServer) flushLoop(ctx context.Context) {
+	ticker := time.NewTicker(server.config.CoalesceInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			server.flush()
+			return
+		case <-ticker.C:
+			server.flush()
+		}
+	}
+}
+
+// flush hands the coalesced batch to AddBatch and replays the same
+// stream/subscription/insights/rules fan-out handleIngestBatch performs
+// after a successful HTTP batch ingest.
+func (server *udpIngestServer) flush() {
+	server.mu.Lock()
+	readings := server.pending
+	server.pending = nil
+	server.mu.Unlock()
+
+	if len(readings) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := server.api.store.AddBatch(ctx, readings); err != nil {
+		logger.Error("udp ingest batch persist failed", "component", "udp_ingest", "error", err)
+		return
+	}
+
+	now := time.Now()
+	seenDevices := make(map[string]bool, len(readings))
+	for _, reading := range readings {
+		server.api.stream.publish(reading)
+		server.api.subscriptions.publish(reading)
+		if !seenDevices[reading.DeviceID] {
+			seenDevices[reading.DeviceID] = true
+			server.api.onTelemetryReceived(reading.DeviceID, now)
+		}
+	}
+
+	if server.api.insightsEngine != nil {
+		server.api.insightsEngine.OnBatch(readings)
+	}
+	if server.api.rulesEngine != nil {
+		server.api.rulesEngine.OnBatch(readings)
+	}
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// WebhookSubscriber POSTs each reading as JSON to a configured URL, signing
+// the body with HMAC-SHA256 so receivers can verify authenticity the way
+// GitHub/Stripe webhooks do.
+type WebhookSubscriber struct {
+	httpClient *http.Client
+	url        string
+	secret     []byte
+}
+
+// NewWebhookSubscriber returns a WebhookSubscriber posting to url. secret
+// may be empty, in which case requests are sent unsigned.
+func NewWebhookSubscriber(url string, secret string) *WebhookSubscriber {
+	return &WebhookSubscriber{
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		url:        url,
+		secret:     []byte(secret),
+	}
+}
+
+func (subscriber *WebhookSubscriber) Name() string {
+	return "webhook:" + subscriber.url
+}
+
+func (subscriber *WebhookSubscriber) Publish(ctx context.Context, reading SensorReading) error {
+	body, err := json.Marshal(reading)
+	if err != nil {
+		return fmt.Errorf("marshal reading: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, subscriber.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if len(subscriber.secret) > 0 {
+		request.Header.Set("X-Enviro-Signature", signHMAC(subscriber.secret, body))
+	}
+
+	response, err := subscriber.httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook status %d", response.StatusCode)
+	}
+	return nil
+}
+
+func (subscriber *WebhookSubscriber) PublishInsights(ctx context.Context, snapshot InsightsSnapshot) error {
+	body, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("marshal snapshot: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, subscriber.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Enviro-Event", "insights_snapshot")
+	if len(subscriber.secret) > 0 {
+		request.Header.Set("X-Enviro-Signature", signHMAC(subscriber.secret, body))
+	}
+
+	response, err := subscriber.httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook status %d", response.StatusCode)
+	}
+	return nil
+}
+
+func signHMAC(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
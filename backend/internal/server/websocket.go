@@ -0,0 +1,127 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// websocketGUID is the fixed handshake suffix defined by RFC 6455 section
+// 1.3, appended to the client's Sec-WebSocket-Key before hashing.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	wsOpcodeText  = 0x1
+	wsOpcodeClose = 0x8
+	wsOpcodePing  = 0x9
+)
+
+// isWebSocketUpgrade reports whether request is asking to upgrade to the
+// WebSocket protocol (RFC 6455 section 4.2.1), as opposed to a plain SSE
+// request to the same endpoint.
+func isWebSocketUpgrade(request *http.Request) bool {
+	return strings.EqualFold(request.Header.Get("Upgrade"), "websocket") &&
+		strings.Contains(strings.ToLower(request.Header.Get("Connection")), "upgrade")
+}
+
+// wsConn is a minimal, mostly write-only RFC 6455 connection: enough to
+// push server->client JSON text frames for /api/readings/stream's
+// WebSocket mode and notice when the client disconnects, without pulling
+// in a third-party dependency the rest of this snapshot doesn't have.
+type wsConn struct {
+	conn net.Conn
+	buf  *bufio.ReadWriter
+}
+
+// upgradeWebSocket performs the RFC 6455 handshake over a hijacked
+// connection, returning a wsConn ready for writeText/waitClosed/close.
+func upgradeWebSocket(response http.ResponseWriter, request *http.Request) (*wsConn, error) {
+	key := request.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return nil, errors.New("missing Sec-WebSocket-Key")
+	}
+
+	hijacker, ok := response.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("connection does not support hijacking")
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	acceptDigest := sha1.Sum([]byte(key + websocketGUID))
+	handshake := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(acceptDigest[:]) + "\r\n\r\n"
+	if _, err := buf.WriteString(handshake); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := buf.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &wsConn{conn: conn, buf: buf}, nil
+}
+
+// writeText sends payload as a single unmasked text frame; server-to-client
+// frames are never masked per RFC 6455 section 5.1.
+func (ws *wsConn) writeText(payload []byte) error {
+	return ws.writeFrame(wsOpcodeText, payload)
+}
+
+func (ws *wsConn) writeFrame(opcode byte, payload []byte) error {
+	finAndOpcode := byte(0x80) | opcode
+	length := len(payload)
+
+	var header []byte
+	switch {
+	case length <= 125:
+		header = []byte{finAndOpcode, byte(length)}
+	case length <= 0xFFFF:
+		header = make([]byte, 4)
+		header[0] = finAndOpcode
+		header[1] = 126
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header = make([]byte, 10)
+		header[0] = finAndOpcode
+		header[1] = 127
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+
+	if _, err := ws.buf.Write(header); err != nil {
+		return err
+	}
+	if _, err := ws.buf.Write(payload); err != nil {
+		return err
+	}
+	return ws.buf.Flush()
+}
+
+// waitClosed blocks until a read from the peer fails (typically because it
+// closed the connection), so the caller's select loop knows to unsubscribe
+// and stop writing. /api/readings/stream's WebSocket mode is push-only, so
+// incoming frames, close frames included, are read and discarded rather
+// than parsed.
+func (ws *wsConn) waitClosed() {
+	discard := make([]byte, 4096)
+	for {
+		if _, err := ws.buf.Read(discard); err != nil {
+			return
+		}
+	}
+}
+
+func (ws *wsConn) close() {
+	_ = ws.writeFrame(wsOpcodeClose, nil)
+	_ = ws.conn.Close()
+}